@@ -0,0 +1,261 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/makiuchi-d/gozxing"
+	"github.com/makiuchi-d/gozxing/datamatrix"
+	"github.com/makiuchi-d/gozxing/oned"
+	"github.com/makiuchi-d/gozxing/qrcode"
+)
+
+// Синтетический корпус: один и тот же штрих-код в типичных для фото
+// искажениях. Настоящие фото пользователей можно положить рядом.
+var corpusCodes = []string{"4600000000015", "5449000000996"}
+
+type distortion struct {
+	name  string
+	apply func(*image.Gray) *image.Gray
+}
+
+var distortions = []distortion{
+	{"clean", func(g *image.Gray) *image.Gray { return placeOnCanvas(g, 800, 600, 0.5, 0.5) }},
+	{"rotate90", func(g *image.Gray) *image.Gray { return rotate(placeOnCanvas(g, 800, 600, 0.5, 0.5), 90) }},
+	{"rotate180", func(g *image.Gray) *image.Gray { return rotate(placeOnCanvas(g, 800, 600, 0.5, 0.5), 180) }},
+	{"skew12", func(g *image.Gray) *image.Gray { return rotate(placeOnCanvas(g, 800, 600, 0.5, 0.5), 12) }},
+	{"skew20", func(g *image.Gray) *image.Gray { return rotate(placeOnCanvas(g, 800, 600, 0.5, 0.5), 20) }},
+	{"skew30", func(g *image.Gray) *image.Gray { return rotate(placeOnCanvas(g, 800, 600, 0.5, 0.5), 30) }},
+	{"thinskew", func(g *image.Gray) *image.Gray { return rotate(placeOnCanvas(crop(g, 0.3), 800, 600, 0.5, 0.5), 15) }},
+	{"skew45", func(g *image.Gray) *image.Gray { return rotate(placeOnCanvas(g, 800, 600, 0.5, 0.5), 45) }},
+	{"faded", func(g *image.Gray) *image.Gray {
+		return adjustContrast(placeOnCanvas(scale(g, 0.6), 800, 600, 0.5, 0.5), 150, 175)
+	}},
+	{"dark", func(g *image.Gray) *image.Gray { return adjustContrast(placeOnCanvas(g, 800, 600, 0.5, 0.5), 10, 40) }},
+	{"lowcontrast", func(g *image.Gray) *image.Gray {
+		return blur(adjustContrast(placeOnCanvas(g, 800, 600, 0.5, 0.5), 120, 150))
+	}},
+	{"tiny", func(g *image.Gray) *image.Gray { return placeOnCanvas(scale(g, 0.45), 1280, 960, 0.5, 0.5) }},
+	{"offcentre", func(g *image.Gray) *image.Gray { return placeOnCanvas(scale(g, 0.5), 1280, 960, 0.65, 0.4) }},
+	{"shadow", func(g *image.Gray) *image.Gray { return gradient(placeOnCanvas(g, 800, 600, 0.5, 0.5)) }},
+	{"blur", func(g *image.Gray) *image.Gray { return blur(placeOnCanvas(scale(g, 0.7), 800, 600, 0.5, 0.5)) }},
+}
+
+// shelves - несколько штрих-кодов корпуса на одном фото, как на снимке полки
+var shelves = []distortion{
+	{"shelf", func(g *image.Gray) *image.Gray { return g }},
+	{"shelfskew", func(g *image.Gray) *image.Gray { return rotate(g, 8) }},
+}
+
+// markings - коды маркировки с GTIN первого кода корпуса: DataMatrix
+// "Честного ЗНАКа" со сроком годности и партией и QR со ссылкой GS1 Digital Link
+var markings = []struct {
+	name    string
+	format  gozxing.BarcodeFormat
+	payload string
+	apply   func(*image.Gray) *image.Gray
+}{
+	{"datamatrix", gozxing.BarcodeFormat_DATA_MATRIX,
+		"\x1d010" + corpusCodes[0] + "215Ab3Xk9LmQ2pZ\x1d17271231" + "10L0423\x1d93dGVz",
+		func(g *image.Gray) *image.Gray { return placeOnCanvas(g, 800, 600, 0.5, 0.5) }},
+	{"datamatrixskew", gozxing.BarcodeFormat_DATA_MATRIX,
+		"\x1d010" + corpusCodes[0] + "215Ab3Xk9LmQ2pZ\x1d17271231" + "10L0423\x1d93dGVz",
+		func(g *image.Gray) *image.Gray { return rotate(placeOnCanvas(g, 800, 600, 0.5, 0.5), 20) }},
+	{"qr", gozxing.BarcodeFormat_QR_CODE,
+		"https://id.gs1.org/01/0" + corpusCodes[0] + "/10/L0423?17=271231",
+		func(g *image.Gray) *image.Gray { return placeOnCanvas(g, 800, 600, 0.5, 0.5) }},
+}
+
+func generateCorpus(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	writer := oned.NewEAN13Writer()
+	barcodes := make([]*image.Gray, len(corpusCodes))
+	for i, code := range corpusCodes {
+		matrix, err := writer.Encode(code, gozxing.BarcodeFormat_EAN_13, 380, 200, nil)
+		if err != nil {
+			return fmt.Errorf("штрих-код %s: %w", code, err)
+		}
+		barcode := matrixToGray(matrix)
+		barcodes[i] = barcode
+
+		for _, d := range distortions {
+			// Фото из Telegram всегда JPEG; PNG оставляем для чистого образца
+			name := fmt.Sprintf("%s_%s.jpg", code, d.name)
+			if d.name == "clean" {
+				name = strings.TrimSuffix(name, ".jpg") + ".png"
+			}
+			if err := saveImage(filepath.Join(dir, name), d.apply(barcode)); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Коды на полке перечисляются в имени через "+"
+	shelf := placeOnCanvas(image.NewGray(image.Rect(0, 0, 0, 0)), 1280, 960, 0.5, 0.5)
+	for i, barcode := range barcodes {
+		small := scale(barcode, 0.6)
+		cx := float64(i+1) / float64(len(barcodes)+1)
+		draw.Draw(shelf, small.Bounds().Add(image.Pt(int(1280*cx)-small.Bounds().Dx()/2, 300+i*250)), small, image.Point{}, draw.Src)
+	}
+	for _, d := range shelves {
+		name := fmt.Sprintf("%s_%s.jpg", strings.Join(corpusCodes, "+"), d.name)
+		if err := saveImage(filepath.Join(dir, name), d.apply(shelf)); err != nil {
+			return err
+		}
+	}
+
+	for _, m := range markings {
+		var writer gozxing.Writer = datamatrix.NewDataMatrixWriter()
+		if m.format == gozxing.BarcodeFormat_QR_CODE {
+			writer = qrcode.NewQRCodeWriter()
+		}
+		matrix, err := writer.Encode(m.payload, m.format, 240, 240, nil)
+		if err != nil {
+			return fmt.Errorf("код маркировки %s: %w", m.name, err)
+		}
+		name := fmt.Sprintf("%s_%s.jpg", corpusCodes[0], m.name)
+		if err := saveImage(filepath.Join(dir, name), m.apply(matrixToGray(matrix))); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("Корпус создан: %d изображений в %s\n",
+		len(corpusCodes)*len(distortions)+len(shelves)+len(markings), dir)
+	return nil
+}
+
+func matrixToGray(matrix *gozxing.BitMatrix) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, matrix.GetWidth(), matrix.GetHeight()))
+	for y := 0; y < matrix.GetHeight(); y++ {
+		for x := 0; x < matrix.GetWidth(); x++ {
+			if matrix.Get(x, y) {
+				img.SetGray(x, y, color.Gray{Y: 0})
+			} else {
+				img.SetGray(x, y, color.Gray{Y: 255})
+			}
+		}
+	}
+	return img
+}
+
+// placeOnCanvas кладет штрих-код на светлый фон; cx, cy - положение центра в долях
+func placeOnCanvas(g *image.Gray, w, h int, cx, cy float64) *image.Gray {
+	canvas := image.NewGray(image.Rect(0, 0, w, h))
+	draw.Draw(canvas, canvas.Bounds(), image.NewUniform(color.Gray{Y: 235}), image.Point{}, draw.Src)
+	x := int(float64(w)*cx) - g.Bounds().Dx()/2
+	y := int(float64(h)*cy) - g.Bounds().Dy()/2
+	draw.Draw(canvas, g.Bounds().Add(image.Pt(x, y)), g, image.Point{}, draw.Src)
+	return canvas
+}
+
+func scale(g *image.Gray, factor float64) *image.Gray {
+	w := int(float64(g.Bounds().Dx()) * factor)
+	h := int(float64(g.Bounds().Dy()) * factor)
+	out := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			out.SetGray(x, y, g.GrayAt(int(float64(x)/factor), int(float64(y)/factor)))
+		}
+	}
+	return out
+}
+
+// rotate поворачивает изображение вокруг центра, углы заполняются фоном
+func rotate(g *image.Gray, degrees float64) *image.Gray {
+	w, h := g.Bounds().Dx(), g.Bounds().Dy()
+	if degrees == 90 {
+		out := image.NewGray(image.Rect(0, 0, h, w))
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				out.SetGray(h-1-y, x, g.GrayAt(x, y))
+			}
+		}
+		return out
+	}
+
+	out := image.NewGray(image.Rect(0, 0, w, h))
+	sin, cos := math.Sincos(degrees * math.Pi / 180)
+	cx, cy := float64(w)/2, float64(h)/2
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			dx, dy := float64(x)-cx, float64(y)-cy
+			sx, sy := int(cos*dx+sin*dy+cx), int(-sin*dx+cos*dy+cy)
+			v := color.Gray{Y: 235}
+			if image.Pt(sx, sy).In(g.Bounds()) {
+				v = g.GrayAt(sx, sy)
+			}
+			out.SetGray(x, y, v)
+		}
+	}
+	return out
+}
+
+// crop оставляет полосу штрих-кода высотой fraction, как у тонких кодов на упаковке
+func crop(g *image.Gray, fraction float64) *image.Gray {
+	h := int(float64(g.Bounds().Dy()) * fraction)
+	return g.SubImage(image.Rect(0, 0, g.Bounds().Dx(), h)).(*image.Gray)
+}
+
+// blur - размытие 3x3, как от дрожания руки
+func blur(g *image.Gray) *image.Gray {
+	out := image.NewGray(g.Bounds())
+	w, h := g.Bounds().Dx(), g.Bounds().Dy()
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			sum, n := 0, 0
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					if px, py := x+dx, y+dy; px >= 0 && px < w && py >= 0 && py < h {
+						sum += int(g.GrayAt(px, py).Y)
+						n++
+					}
+				}
+			}
+			out.SetGray(x, y, color.Gray{Y: uint8(sum / n)})
+		}
+	}
+	return out
+}
+
+// adjustContrast сжимает яркость в диапазон [low, high]
+func adjustContrast(g *image.Gray, low, high uint8) *image.Gray {
+	out := image.NewGray(g.Bounds())
+	for i, v := range g.Pix {
+		out.Pix[i] = low + uint8(int(v)*int(high-low)/255)
+	}
+	return out
+}
+
+// gradient затемняет левую часть кадра, как тень от руки
+func gradient(g *image.Gray) *image.Gray {
+	out := image.NewGray(g.Bounds())
+	w := g.Bounds().Dx()
+	for y := 0; y < g.Bounds().Dy(); y++ {
+		for x := 0; x < w; x++ {
+			shade := 0.35 + 0.65*float64(x)/float64(w)
+			out.SetGray(x, y, color.Gray{Y: uint8(float64(g.GrayAt(x, y).Y) * shade)})
+		}
+	}
+	return out
+}
+
+func saveImage(path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if strings.HasSuffix(path, ".png") {
+		return png.Encode(f, img)
+	}
+	return jpeg.Encode(f, img, &jpeg.Options{Quality: 80})
+}
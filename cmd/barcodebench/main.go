@@ -0,0 +1,153 @@
+// barcodebench проверяет, какая доля фото из корпуса распознается
+// локальным декодером штрих-кодов и какой способ предобработки помог.
+//
+// Имя файла начинается с ожидаемого штрих-кода: 4600000000015_rotate90.jpg.
+// Если на фото несколько кодов, они перечисляются через "+". Для кодов
+// маркировки (DataMatrix, QR) ожидается GTIN из них.
+//
+//	go run ./cmd/barcodebench                      # корпус из cmd/barcodebench/testdata
+//	go run ./cmd/barcodebench -dir ~/photos        # свои фото
+//	go run ./cmd/barcodebench -generate -dir DIR   # пересоздать синтетический корпус
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"image"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ajeanett/telbot/internal/barcode"
+	"github.com/ajeanett/telbot/internal/services"
+	"github.com/makiuchi-d/gozxing"
+	"github.com/makiuchi-d/gozxing/oned"
+)
+
+func main() {
+	dir := flag.String("dir", "cmd/barcodebench/testdata", "каталог с фото штрих-кодов")
+	generate := flag.Bool("generate", false, "создать синтетический корпус в -dir и выйти")
+	flag.Parse()
+
+	if *generate {
+		if err := generateCorpus(*dir); err != nil {
+			log.Fatalf("Ошибка создания корпуса: %v", err)
+		}
+		return
+	}
+
+	if err := run(*dir); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil {
+		return err
+	}
+	slices.Sort(files)
+
+	detector := services.NewBarcodeDetector()
+	var total, recognized, legacy int
+	var elapsed time.Duration
+	strategies := make(map[string]int)
+
+	for _, path := range files {
+		ext := strings.ToLower(filepath.Ext(path))
+		if ext != ".jpg" && ext != ".jpeg" && ext != ".png" {
+			continue
+		}
+		expected, _, _ := strings.Cut(strings.TrimSuffix(filepath.Base(path), ext), "_")
+
+		img, err := loadImage(path)
+		if err != nil {
+			fmt.Printf("⚠️  %-40s %v\n", filepath.Base(path), err)
+			continue
+		}
+		total++
+		if codes := strings.Split(expected, "+"); len(codes) > 1 {
+			// На фото полки нужно найти все коды; старый декодер находил не больше одного
+			start := time.Now()
+			found, err := detector.DecodeAll(context.Background(), img)
+			took := time.Since(start)
+			elapsed += took
+			slices.Sort(codes)
+			slices.Sort(found)
+			if err != nil || !slices.Equal(found, codes) {
+				fmt.Printf("❌ %-40s найдено %v из %v\n", filepath.Base(path), found, codes)
+				continue
+			}
+			recognized++
+			strategies["multi"]++
+			fmt.Printf("✅ %-40s %-22s %v\n", filepath.Base(path), "multi", took.Round(time.Millisecond))
+			continue
+		}
+		if legacyDecode(img) == expected {
+			legacy++
+		}
+
+		start := time.Now()
+		code, strategy, err := detector.DecodeImage(context.Background(), img)
+		took := time.Since(start)
+		elapsed += took
+		if marking, gs1Err := barcode.ParseGS1(code); err == nil && gs1Err == nil {
+			code = marking.Code().LookupCode()
+		}
+
+		switch {
+		case err != nil:
+			fmt.Printf("❌ %-40s не распознан (%v)\n", filepath.Base(path), took.Round(time.Millisecond))
+		case code != expected:
+			fmt.Printf("❌ %-40s распознан неверно: %s (%s)\n", filepath.Base(path), code, strategy)
+		default:
+			recognized++
+			strategies[strategy]++
+			fmt.Printf("✅ %-40s %-22s %v\n", filepath.Base(path), strategy, took.Round(time.Millisecond))
+		}
+	}
+
+	if total == 0 {
+		return fmt.Errorf("в %s нет фото .jpg/.png", dir)
+	}
+	fmt.Printf("\nРаспознано: %d из %d (%.0f%%), одной попыткой без предобработки: %d (%.0f%%)\n",
+		recognized, total, percent(recognized, total), legacy, percent(legacy, total))
+	fmt.Printf("Среднее время: %v\n", (elapsed / time.Duration(total)).Round(time.Millisecond))
+	for _, strategy := range slices.Sorted(maps.Keys(strategies)) {
+		fmt.Printf("  %-22s %d\n", strategy, strategies[strategy])
+	}
+	return nil
+}
+
+// legacyDecode - прежнее распознавание: одна попытка на исходном фото,
+// для сравнения с конвейером предобработки
+func legacyDecode(img image.Image) string {
+	bmp, err := gozxing.NewBinaryBitmapFromImage(img)
+	if err != nil {
+		return ""
+	}
+	result, err := oned.NewMultiFormatUPCEANReader(nil).Decode(bmp, nil)
+	if err != nil {
+		return ""
+	}
+	return result.GetText()
+}
+
+func loadImage(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	return img, err
+}
+
+func percent(n, total int) float64 {
+	return float64(n) * 100 / float64(total)
+}
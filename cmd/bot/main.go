@@ -2,50 +2,373 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
+	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"slices"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/ajeanett/telbot/internal/bot"
 	"github.com/ajeanett/telbot/internal/config"
+	"github.com/ajeanett/telbot/internal/models"
 	"github.com/ajeanett/telbot/internal/services"
+	"github.com/ajeanett/telbot/internal/storage"
 )
 
 func main() {
-	services.StartHealthServer() 
+	if err := run(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run запускает бота и возвращается после остановки. Ошибка возвращается,
+// а не завершает процесс через log.Fatal, чтобы открытые ресурсы успели закрыться.
+func run() (err error) {
 	// Загрузка конфигурации
 	cfg := config.Load()
 
+	// Контекст отменяется по сигналу остановки
+	ctx, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+
+	health := services.NewHealthServer(cfg.Port)
+	health.Start()
+	var admin *services.HealthServer
+	if cfg.AdminAddr != "" {
+		admin = services.NewAdminServer(cfg.AdminAddr)
+		admin.Start()
+	}
+
 	if cfg.TelegramToken == "" {
-		log.Fatal("TELEGRAM_BOT_TOKEN не установлен")
+		return errors.New("TELEGRAM_BOT_TOKEN не установлен")
 	}
 
+	// Ресурсы закрываются явно после остановки обработчиков. Пока бот не
+	// запущен, обработчиков нет, и при ошибке запуска все закрывается сразу.
+	var opened resources
+	defer func() {
+		if err != nil {
+			opened.close()
+		}
+	}()
+
 	// Инициализация сервисов
-	barcodeService := services.NewBarcodeService(cfg.OpenFoodFactsAPI)
+	productCache, err := services.NewProductCache(cfg.RedisURL, cfg.CacheSize)
+	if err != nil {
+		return fmt.Errorf("ошибка инициализации кэша: %w", err)
+	}
+	opened.add("кэш продуктов", productCache.Close)
+
+	sources, err := buildProductSources(cfg, &opened)
+	if err != nil {
+		return fmt.Errorf("ошибка инициализации источников продуктов: %w", err)
+	}
+
+	barcodeService := services.NewBarcodeService(sources, productCache, cfg.CacheTTL, cfg.CacheNotFoundTTL)
 	analyzer := services.NewAnalyzer()
-	barcodeDetector := services.NewBarcodeDetector()
+	if cfg.RulesPath != "" {
+		rules, err := services.LoadRules(cfg.RulesPath)
+		if err != nil {
+			return fmt.Errorf("ошибка загрузки правил анализа: %w", err)
+		}
+		analyzer.SetRules(rules)
 
-	// Создание бота
-	bot, err := bot.NewBot(cfg.TelegramToken, barcodeService, analyzer, barcodeDetector)
+		watcher := services.WatchRules(analyzer, cfg.RulesPath, cfg.RulesReloadInterval)
+		opened.add("наблюдение за правилами", func() error {
+			watcher.Stop()
+			return nil
+		})
+	}
+	log.Printf("Правил анализа: %d", analyzer.Rules().Len())
+	if cfg.NutritionThresholdsPath != "" {
+		thresholds, err := services.LoadNutritionThresholds(cfg.NutritionThresholdsPath)
+		if err != nil {
+			return fmt.Errorf("ошибка загрузки порогов пищевой ценности: %w", err)
+		}
+		analyzer.SetNutritionThresholds(thresholds)
+	}
+	// Google Vision нужен для разбора этикеток и как запасной декодер штрих-кодов
+	var visionService *services.VisionService
+	if cfg.LabelOCR || slices.ContainsFunc(cfg.BarcodeDecoders, isVisionDecoder) {
+		visionService, err = newVisionService(ctx, cfg)
+		if err != nil {
+			log.Printf("⚠️ Google Vision недоступен: %v", err)
+			visionService = nil
+		} else {
+			opened.add("Google Vision", visionService.Close)
+		}
+	}
+
+	var textRecognizer services.TextRecognizer
+	if cfg.LabelOCR && visionService != nil {
+		textRecognizer = visionService
+	}
+
+	barcodeDecoder, err := buildBarcodeDecoder(cfg, visionService)
 	if err != nil {
-		log.Fatalf("Ошибка создания бота: %v", err)
+		return fmt.Errorf("ошибка настройки распознавания штрих-кодов: %w", err)
 	}
-	defer bot.Close() // Закрываем ресурсы при завершении
-	log.Printf("Бот авторизован: %s", bot.Api().Self.UserName)
 
-	// Канал для graceful shutdown
-	stop := make(chan os.Signal, 1)
-	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	store, err := storage.Open(cfg.StorageURL)
+	if err != nil {
+		return fmt.Errorf("ошибка инициализации хранилища: %w", err)
+	}
+	opened.add("хранилище", store.Close)
+
+	// Создание бота
+	bot, err := bot.NewBot(cfg.TelegramToken, barcodeService, analyzer, barcodeDecoder, store, bot.Options{
+		CallbackSecret: cfg.CallbackSecret,
+		RateLimit:      cfg.RateLimit,
+		RateBurst:      cfg.RateBurst,
+		AllowedUsers:   cfg.AllowedUsers,
+		AdminUsers:     cfg.AdminUsers,
+		Workers:        cfg.Workers,
+		QueueSize:      cfg.QueueSize,
+		TextRecognizer: textRecognizer,
+		Alternatives:   buildAlternativeFinder(cfg, analyzer),
+		Country:        cfg.Country,
+	})
+	if err != nil {
+		return fmt.Errorf("ошибка создания бота: %w", err)
+	}
+	opened.add("клиент Telegram", bot.Close)
+	log.Printf("Бот авторизован: %s", bot.Username())
 
-	// Запускаем бота в горутине
-	go func() {
-		log.Printf("Бот запущен: %s", bot.Api().Self.UserName)
+	// polling закрывается, когда long polling перестал получать обновления
+	polling := make(chan struct{})
+	if cfg.WebhookURL != "" {
+		close(polling)
+		// Обновления приходят на сервер здоровья
+		if err := startWebhook(cfg, bot, health); err != nil {
+			return fmt.Errorf("ошибка запуска вебхука: %w", err)
+		}
+		log.Printf("Бот запущен в режиме вебхука: %s", cfg.WebhookURL)
+	} else {
+		// Запускаем бота в горутине
+		go func() {
+			defer close(polling)
+			log.Printf("Бот запущен: %s", bot.Username())
 
-		// Запуск бота
-		bot.Start()
-	}()
-	<-stop
+			// Запуск бота
+			bot.Start(ctx)
+		}()
+	}
+	<-ctx.Done()
 	log.Println("🛑 Получен сигнал остановки...")
+
+	// Сначала перестаем принимать обновления, затем дожидаемся начатых
+	// обработчиков, и только после них закрываем хранилище и кэш
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if cfg.WebhookURL != "" {
+		if err := bot.DeleteWebhook(); err != nil {
+			log.Printf("⚠️ %v", err)
+		}
+	}
+	select {
+	case <-polling:
+	case <-shutdownCtx.Done():
+		log.Println("⚠️ Long polling не остановился за отведенное время")
+	}
+	drained := stopServing(shutdownCtx, health, bot)
+	if admin != nil {
+		if err := admin.Shutdown(shutdownCtx); err != nil {
+			log.Printf("⚠️ Ошибка остановки admin server: %v", err)
+		}
+	}
+
+	if drained {
+		opened.close()
+	} else {
+		// Закрыть хранилище и кэш под работающими обработчиками нельзя,
+		// их освободит завершение процесса
+		log.Println("⚠️ Обработчики не завершились, хранилище и кэш не закрываются")
+	}
 	log.Println("👋 Завершаем работу бота")
+	return nil
+}
+
+// stopper - сервер или бот, останавливающийся в пределах ctx
+type stopper interface {
+	Shutdown(ctx context.Context) error
+}
+
+// stopServing сначала останавливает HTTP-сервер с вебхуком, затем пул
+// воркеров бота. В обратном порядке вебхук отвечал бы 200 на обновления,
+// которые остановленный пул уже не примет, и Telegram не прислал бы их
+// повторно. Возвращает false, если начатые обработчики не успели завершиться.
+func stopServing(ctx context.Context, server, b stopper) bool {
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("⚠️ Ошибка остановки health server: %v", err)
+	}
+	if err := b.Shutdown(ctx); err != nil {
+		log.Printf("⚠️ %v", err)
+		return false
+	}
+	return true
+}
+
+// resources - открытые при запуске ресурсы, закрываются в обратном порядке
+type resources []resource
+
+type resource struct {
+	name  string
+	close func() error
+}
+
+func (r *resources) add(name string, close func() error) {
+	*r = append(*r, resource{name: name, close: close})
+}
+
+// close закрывает ресурсы, записывая ошибки в лог
+func (r resources) close() {
+	for i := len(r) - 1; i >= 0; i-- {
+		closeResource(r[i].name, r[i].close)
+	}
+}
+
+// closeResource закрывает ресурс при завершении, записывая ошибку в лог
+func closeResource(name string, close func() error) {
+	if err := close(); err != nil {
+		log.Printf("⚠️ Ошибка закрытия (%s): %v", name, err)
+	}
+}
+
+// startWebhook вешает обработчик вебхука на путь из WEBHOOK_URL и регистрирует его в Telegram
+func startWebhook(cfg *config.Config, b *bot.Bot, health *services.HealthServer) error {
+	webhookURL, err := url.Parse(cfg.WebhookURL)
+	if err != nil {
+		return fmt.Errorf("некорректный WEBHOOK_URL: %w", err)
+	}
+	path := webhookURL.Path
+	if path == "" || path == "/" {
+		path = "/webhook"
+		webhookURL.Path = path
+	}
+
+	secret := cfg.WebhookSecret
+	if secret == "" {
+		if secret, err = bot.NewWebhookSecret(); err != nil {
+			return err
+		}
+	}
+
+	health.Handle(path, b.WebhookHandler(secret))
+	return b.SetWebhook(webhookURL.String(), secret)
+}
+
+// newVisionService подключается к Google Vision с месячной квотой запросов
+func newVisionService(ctx context.Context, cfg *config.Config) (*services.VisionService, error) {
+	quota, err := services.NewMonthlyQuota(cfg.VisionMonthlyQuota, cfg.VisionQuotaPath)
+	if err != nil {
+		return nil, err
+	}
+	visionService, err := services.NewVisionService(ctx)
+	if err != nil {
+		return nil, err
+	}
+	visionService.SetQuota(quota)
+	used, limit := quota.Used()
+	log.Printf("Google Vision: использовано %d из %d запросов в этом месяце", used, limit)
+	return visionService, nil
+}
+
+func isVisionDecoder(decoder string) bool {
+	name, _, _ := strings.Cut(decoder, ":")
+	return name == "vision"
+}
+
+// buildBarcodeDecoder собирает цепочку декодеров штрих-кодов из BARCODE_DECODERS.
+// vision пропускается, если Google Vision недоступен.
+func buildBarcodeDecoder(cfg *config.Config, visionService *services.VisionService) (*services.CompositeDecoder, error) {
+	var steps []services.DecoderStep
+	for _, item := range cfg.BarcodeDecoders {
+		name, timeoutValue, hasTimeout := strings.Cut(item, ":")
+		timeout := cfg.DecoderTimeout
+		if hasTimeout {
+			var err error
+			if timeout, err = time.ParseDuration(timeoutValue); err != nil {
+				return nil, fmt.Errorf("некорректный таймаут декодера %q: %w", item, err)
+			}
+		}
+
+		switch name {
+		case "gozxing":
+			steps = append(steps, services.DecoderStep{Decoder: services.NewBarcodeDetector(), Timeout: timeout})
+		case "vision":
+			if visionService == nil {
+				log.Println("⚠️ Декодер vision пропущен: Google Vision недоступен")
+				continue
+			}
+			steps = append(steps, services.DecoderStep{Decoder: visionService, Timeout: timeout})
+		default:
+			return nil, fmt.Errorf("неизвестный декодер штрих-кодов: %q", name)
+		}
+	}
+
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("не задано ни одного декодера штрих-кодов")
+	}
+	return services.NewCompositeDecoder(steps...), nil
+}
+
+// openFactsClient - HTTP-клиент и политика повторов для баз Open Food Facts
+func openFactsClient(cfg *config.Config) (*http.Client, services.RetryPolicy) {
+	retryPolicy := services.DefaultRetryPolicy
+	retryPolicy.MaxAttempts = cfg.HTTPRetries
+	return services.NewHTTPClient(cfg.HTTPTimeout, cfg.UserAgent), retryPolicy
+}
+
+// buildAlternativeFinder включает поиск альтернатив, если Open Food Facts
+// есть среди источников продуктов
+func buildAlternativeFinder(cfg *config.Config, analyzer *services.Analyzer) *services.AlternativeFinder {
+	if !slices.Contains(cfg.ProductSources, "off") {
+		return nil
+	}
+	httpClient, retryPolicy := openFactsClient(cfg)
+	search := services.NewOpenFactsSource("Open Food Facts", models.ProductTypeFood,
+		cfg.OpenFoodFactsAPI, services.APIVersionV2, httpClient, retryPolicy)
+	return services.NewAlternativeFinder(search, analyzer)
+}
+
+// buildProductSources собирает цепочку баз продуктов в порядке из конфигурации
+func buildProductSources(cfg *config.Config, opened *resources) (*services.SourceChain, error) {
+	httpClient, retryPolicy := openFactsClient(cfg)
+
+	var sources []services.ProductSource
+	for _, name := range cfg.ProductSources {
+		switch name {
+		case "off":
+			sources = append(sources, services.NewOpenFactsSource("Open Food Facts", models.ProductTypeFood,
+				cfg.OpenFoodFactsAPI, cfg.OpenFoodFactsAPIVersion, httpClient, retryPolicy))
+		case "obf":
+			sources = append(sources, services.NewOpenFactsSource("Open Beauty Facts", models.ProductTypeBeauty,
+				services.OpenBeautyFactsURL, cfg.OpenFoodFactsAPIVersion, httpClient, retryPolicy))
+		case "opff":
+			sources = append(sources, services.NewOpenFactsSource("Open Pet Food Facts", models.ProductTypePetFood,
+				services.OpenPetFoodFactsURL, cfg.OpenFoodFactsAPIVersion, httpClient, retryPolicy))
+		case "local":
+			catalog, count, closeCatalog, err := services.OpenLocalCatalog(cfg.LocalCatalogPath)
+			if err != nil {
+				return nil, err
+			}
+			opened.add("локальный каталог", closeCatalog)
+			log.Printf("Локальный каталог: %d продуктов", count)
+			sources = append(sources, catalog)
+		default:
+			return nil, fmt.Errorf("неизвестный источник продуктов: %q", name)
+		}
+	}
+
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("не задано ни одного источника продуктов")
+	}
+	return services.NewSourceChain(sources...), nil
 }
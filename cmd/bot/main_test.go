@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+// fakeStopper записывает свою остановку в общий журнал
+type fakeStopper struct {
+	name string
+	log  *[]string
+	// block - остановка ждет, пока не истечет ctx
+	block bool
+}
+
+func (s *fakeStopper) Shutdown(ctx context.Context) error {
+	*s.log = append(*s.log, s.name)
+	if s.block {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return nil
+}
+
+func TestStopServing(t *testing.T) {
+	tests := []struct {
+		name        string
+		blockBot    bool
+		wantDrained bool
+	}{
+		{"обработчики завершились", false, true},
+		{"обработчики не успели", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var order []string
+			server := &fakeStopper{name: "сервер", log: &order}
+			b := &fakeStopper{name: "бот", log: &order, block: tt.blockBot}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			if drained := stopServing(ctx, server, b); drained != tt.wantDrained {
+				t.Errorf("stopServing = %v, ожидалось %v", drained, tt.wantDrained)
+			}
+			// Вебхук закрывается раньше пула, иначе Telegram получит 200 за потерянные обновления
+			if want := []string{"сервер", "бот"}; !slices.Equal(order, want) {
+				t.Errorf("порядок остановки %q, ожидался %q", order, want)
+			}
+		})
+	}
+}
+
+func TestResourcesCloseInReverseOrder(t *testing.T) {
+	var closed []string
+	var opened resources
+	for _, name := range []string{"кэш", "хранилище", "клиент Telegram"} {
+		opened.add(name, func() error {
+			closed = append(closed, name)
+			return nil
+		})
+	}
+	opened.close()
+	if want := []string{"клиент Telegram", "хранилище", "кэш"}; !slices.Equal(closed, want) {
+		t.Errorf("порядок закрытия %q, ожидался %q", closed, want)
+	}
+}
@@ -0,0 +1,257 @@
+// Package barcode проверяет и нормализует штрих-коды продуктов по правилам
+// GS1: EAN-8, UPC-E, UPC-A, EAN-13 и GTIN-14, а также разбирает коды маркировки.
+package barcode
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrInvalid - строка не похожа на штрих-код: не цифры или неподходящая длина
+	ErrInvalid = errors.New("некорректный штрих-код")
+	// ErrCheckDigit - длина верная, но контрольная цифра не сходится: опечатка или ошибка распознавания
+	ErrCheckDigit = errors.New("не сходится контрольная цифра штрих-кода")
+)
+
+// Format - символика, в которой записан штрих-код
+type Format string
+
+const (
+	EAN8   Format = "EAN-8"
+	UPCE   Format = "UPC-E"
+	UPCA   Format = "UPC-A"
+	EAN13  Format = "EAN-13"
+	GTIN14 Format = "GTIN-14"
+)
+
+// Kind - что обозначает штрих-код, по префиксу GS1
+type Kind int
+
+const (
+	KindProduct    Kind = iota // обычный товар
+	KindBook                   // ISBN: префиксы 978 и 979
+	KindPeriodical             // ISSN: префикс 977
+	KindInStore                // внутренний код магазина или весовой товар: префиксы 20-29, 02, 04
+)
+
+// Code - проверенный штрих-код
+type Code struct {
+	GTIN   string // 14 цифр, дополнен нулями слева
+	Format Format
+}
+
+// Normalize проверяет штрих-код любой длины GTIN и приводит его к GTIN-14.
+// Пробелы и дефисы, с которыми коды часто переписывают с упаковки, отбрасываются.
+// UPC-E разворачивается в UPC-A.
+func Normalize(s string) (Code, error) {
+	s = Clean(s)
+	if !Digits(s) {
+		return Code{}, ErrInvalid
+	}
+
+	switch len(s) {
+	case 8:
+		// Восьмизначный код бывает и EAN-8, и UPC-E. EAN-8 с нулем в начале -
+		// внутренние коды магазинов, поэтому такой код считается UPC-E.
+		upca, upceErr := ExpandUPCE(s)
+		switch {
+		case upceErr == nil && (s[0] == '0' || !hasValidCheckDigit(s)):
+			return Code{GTIN: pad(upca), Format: UPCE}, nil
+		case hasValidCheckDigit(s):
+			return Code{GTIN: pad(s), Format: EAN8}, nil
+		default:
+			return Code{}, ErrCheckDigit
+		}
+	case 12:
+		if !hasValidCheckDigit(s) {
+			return Code{}, ErrCheckDigit
+		}
+		// Двенадцать цифр печатают только под UPC-A, даже если код начинается
+		// с нулей и в GTIN-14 выглядит как EAN-8
+		return Code{GTIN: pad(s), Format: UPCA}, nil
+	case 13, 14:
+		if !hasValidCheckDigit(s) {
+			return Code{}, ErrCheckDigit
+		}
+		return Code{GTIN: pad(s), Format: formatOf(pad(s))}, nil
+	default:
+		return Code{}, fmt.Errorf("%w: нужно 8, 12, 13 или 14 цифр, а не %d", ErrInvalid, len(s))
+	}
+}
+
+// Valid - штрих-код с верной контрольной цифрой
+func Valid(s string) bool {
+	_, err := Normalize(s)
+	return err == nil
+}
+
+// Plausible - строка похожа на штрих-код, набранный вручную: 8-14 цифр,
+// возможно с пробелами. Контрольная цифра не проверяется.
+func Plausible(s string) bool {
+	s = Clean(s)
+	return len(s) >= 8 && len(s) <= 14 && Digits(s)
+}
+
+// Clean убирает пробелы, в том числе неразрывные, и дефисы
+func Clean(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' || r == '\u00a0' {
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(s))
+}
+
+// Digits - непустая строка только из цифр
+func Digits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// CheckDigit вычисляет контрольную цифру GS1 для кода без нее: справа
+// налево цифры умножаются попеременно на 3 и 1
+func CheckDigit(body string) byte {
+	sum := 0
+	for i := len(body) - 1; i >= 0; i-- {
+		digit := int(body[i] - '0')
+		if (len(body)-1-i)%2 == 0 {
+			digit *= 3
+		}
+		sum += digit
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+func hasValidCheckDigit(s string) bool {
+	return CheckDigit(s[:len(s)-1]) == s[len(s)-1]
+}
+
+// ExpandUPCE разворачивает восьмизначный UPC-E (система, 6 цифр, контрольная)
+// в двенадцатизначный UPC-A. Контрольная цифра у них общая.
+func ExpandUPCE(s string) (string, error) {
+	if len(s) != 8 || !Digits(s) || s[0] != '0' && s[0] != '1' {
+		return "", ErrInvalid
+	}
+
+	d := s[1:7]
+	var body string
+	switch d[5] {
+	case '0', '1', '2':
+		body = d[0:2] + d[5:6] + "0000" + d[2:5]
+	case '3':
+		body = d[0:3] + "00000" + d[3:5]
+	case '4':
+		body = d[0:4] + "00000" + d[4:5]
+	default:
+		body = d[0:5] + "0000" + d[5:6]
+	}
+
+	upca := s[0:1] + body + s[7:8]
+	if !hasValidCheckDigit(upca) {
+		return "", ErrCheckDigit
+	}
+	return upca, nil
+}
+
+// GTIN13 - код без ведущего нуля: EAN-13, UPC-A с нулем впереди. GTIN-14
+// упаковки с индикатором, отличным от нуля, возвращается целиком.
+func (c Code) GTIN13() string {
+	if c.GTIN[0] == '0' {
+		return c.GTIN[1:]
+	}
+	return c.GTIN
+}
+
+// LookupCode - код для поиска в базах продуктов. Open Food Facts хранит
+// EAN-8 восемью цифрами, а остальные коды - тринадцатью.
+func (c Code) LookupCode() string {
+	if c.Format == EAN8 {
+		return c.GTIN[6:]
+	}
+	return c.GTIN13()
+}
+
+// String - код в том виде, в каком он напечатан на упаковке
+func (c Code) String() string {
+	switch c.Format {
+	case EAN8:
+		return c.GTIN[6:]
+	case UPCA:
+		return c.GTIN[2:]
+	case UPCE:
+		// Сжатую форму не храним, показываем развернутую
+		return c.GTIN[2:]
+	case EAN13:
+		return c.GTIN[1:]
+	default:
+		return c.GTIN
+	}
+}
+
+// Kind определяет назначение кода по префиксу GS1
+func (c Code) Kind() Kind {
+	if c.Format == EAN8 {
+		// Восьмизначные коды с 0 и 2 в начале магазины назначают сами
+		if ean8 := c.GTIN[6:]; ean8[0] == '0' || ean8[0] == '2' {
+			return KindInStore
+		}
+		return KindProduct
+	}
+
+	// Префикс GS1 идет после индикатора упаковки GTIN-14
+	prefix := c.GTIN[1:4]
+	switch {
+	case prefix == "978" || prefix == "979":
+		return KindBook
+	case prefix == "977":
+		return KindPeriodical
+	case prefix[0] == '2', strings.HasPrefix(prefix, "02"), strings.HasPrefix(prefix, "04"):
+		return KindInStore
+	default:
+		return KindProduct
+	}
+}
+
+// ISSN возвращает номер ISSN журнала в виде 1234-5679; пусто, если код не ISSN
+func (c Code) ISSN() string {
+	if c.Kind() != KindPeriodical {
+		return ""
+	}
+	// 977 + 7 цифр ISSN без контрольной + 2 цифры выпуска + контрольная EAN
+	digits := c.GTIN[4:11]
+	sum := 0
+	for i := range digits {
+		sum += int(digits[i]-'0') * (8 - i)
+	}
+	check := byte('0' + (11-sum%11)%11)
+	if check == '0'+10 {
+		check = 'X'
+	}
+	return digits[:4] + "-" + digits[4:] + string(check)
+}
+
+// formatOf определяет символику по ведущим нулям GTIN-14: из кода
+// маркировки и набранного с нулями кода получается тот же EAN-13, что на упаковке
+func formatOf(gtin string) Format {
+	switch {
+	case strings.HasPrefix(gtin, "000000"):
+		return EAN8
+	case strings.HasPrefix(gtin, "00"):
+		return UPCA
+	case strings.HasPrefix(gtin, "0"):
+		return EAN13
+	default:
+		return GTIN14
+	}
+}
+
+// pad дополняет код нулями слева до GTIN-14
+func pad(s string) string {
+	return strings.Repeat("0", 14-len(s)) + s
+}
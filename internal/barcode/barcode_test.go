@@ -0,0 +1,205 @@
+package barcode
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		gtin   string
+		format Format
+		err    error
+	}{
+		// Восемь цифр: EAN-8 или UPC-E
+		{"EAN-8", "96385074", "00000096385074", EAN8, nil},
+		{"UPC-E", "01234565", "00012345000065", UPCE, nil},
+		{"UPC-E с нулем, хотя сходится и как EAN-8", "01234558", "00012345000058", UPCE, nil},
+		{"с 1: сходится только UPC-E", "10000016", "00100100000006", UPCE, nil},
+		{"с 1: сходится только EAN-8", "10000014", "00000010000014", EAN8, nil},
+		{"с 1: сходятся оба - EAN-8", "10000007", "00000010000007", EAN8, nil},
+		{"с 0: сходится только EAN-8", "00000017", "00000000000017", EAN8, nil},
+		{"восемь цифр без верной контрольной", "10000000", "", "", ErrCheckDigit},
+
+		{"UPC-A", "036000291452", "00036000291452", UPCA, nil},
+		{"UPC-A с четырьмя нулями - не EAN-8", "000012345670", "00000012345670", UPCA, nil},
+		{"UPC-A тринадцатью цифрами", "0036000291452", "00036000291452", UPCA, nil},
+		{"EAN-13", "4607001771234", "04607001771234", EAN13, nil},
+		{"EAN-8 тринадцатью цифрами", "0000096385074", "00000096385074", EAN8, nil},
+		{"GTIN-14 упаковки", "14607001771231", "14607001771231", GTIN14, nil},
+		{"EAN-13 из кода маркировки", "04607001771234", "04607001771234", EAN13, nil},
+		{"EAN-8 из кода маркировки", "00000096385074", "00000096385074", EAN8, nil},
+
+		{"пробелы и дефисы", " 4 607001-771234 ", "04607001771234", EAN13, nil},
+		{"неразрывный пробел", "4607001 771234", "04607001771234", EAN13, nil},
+
+		{"опечатка в EAN-13", "4607001771235", "", "", ErrCheckDigit},
+		{"опечатка в UPC-A", "036000291453", "", "", ErrCheckDigit},
+		{"опечатка в GTIN-14", "14607001771232", "", "", ErrCheckDigit},
+		{"7 цифр", "1234567", "", "", ErrInvalid},
+		{"9 цифр", "123456789", "", "", ErrInvalid},
+		{"15 цифр", "123456789012345", "", "", ErrInvalid},
+		{"буквы", "46070017712a4", "", "", ErrInvalid},
+		{"пусто", "", "", "", ErrInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := Normalize(tt.input)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Errorf("Normalize(%q) = %+v, %v; ожидалась ошибка %v", tt.input, code, err, tt.err)
+				}
+				return
+			}
+			if err != nil || code.GTIN != tt.gtin || code.Format != tt.format {
+				t.Errorf("Normalize(%q) = %+v, %v; ожидалось %s %s", tt.input, code, err, tt.gtin, tt.format)
+			}
+		})
+	}
+}
+
+func TestExpandUPCE(t *testing.T) {
+	// Последняя цифра из шести определяет, где в UPC-A стоят нули
+	tests := []struct {
+		upce string
+		upca string
+	}{
+		{"01234505", "012000003455"},
+		{"01234514", "012100003454"},
+		{"01234523", "012200003453"},
+		{"01234531", "012300000451"},
+		{"01234543", "012340000053"},
+		{"01234558", "012345000058"},
+		{"01234565", "012345000065"},
+		{"01234572", "012345000072"},
+		{"01234589", "012345000089"},
+		{"01234596", "012345000096"},
+		{"10000016", "100100000006"},
+	}
+	for _, tt := range tests {
+		got, err := ExpandUPCE(tt.upce)
+		if err != nil || got != tt.upca {
+			t.Errorf("ExpandUPCE(%q) = %q, %v; ожидалось %q", tt.upce, got, err, tt.upca)
+		}
+	}
+
+	for input, want := range map[string]error{
+		"01234506":  ErrCheckDigit,
+		"21234505":  ErrInvalid, // система только 0 или 1
+		"0123450":   ErrInvalid,
+		"012345050": ErrInvalid,
+		"0123450a":  ErrInvalid,
+	} {
+		if got, err := ExpandUPCE(input); !errors.Is(err, want) {
+			t.Errorf("ExpandUPCE(%q) = %q, %v; ожидалась ошибка %v", input, got, err, want)
+		}
+	}
+}
+
+func TestCheckDigit(t *testing.T) {
+	for _, code := range []string{"96385074", "036000291452", "4607001771234", "14607001771231", "9771234567003"} {
+		if got := CheckDigit(code[:len(code)-1]); got != code[len(code)-1] {
+			t.Errorf("CheckDigit(%q) = %c, ожидалось %c", code[:len(code)-1], got, code[len(code)-1])
+		}
+	}
+}
+
+func TestCodeForms(t *testing.T) {
+	tests := []struct {
+		input  string
+		str    string // как напечатан на упаковке
+		gtin13 string
+		lookup string
+	}{
+		{"96385074", "96385074", "0000096385074", "96385074"},
+		{"01234565", "012345000065", "0012345000065", "0012345000065"},
+		{"036000291452", "036000291452", "0036000291452", "0036000291452"},
+		{"4607001771234", "4607001771234", "4607001771234", "4607001771234"},
+		{"04607001771234", "4607001771234", "4607001771234", "4607001771234"},
+		{"14607001771231", "14607001771231", "14607001771231", "14607001771231"},
+	}
+	for _, tt := range tests {
+		code, err := Normalize(tt.input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code.String() != tt.str || code.GTIN13() != tt.gtin13 || code.LookupCode() != tt.lookup {
+			t.Errorf("%s: String %q, GTIN13 %q, LookupCode %q; ожидалось %q, %q, %q",
+				tt.input, code, code.GTIN13(), code.LookupCode(), tt.str, tt.gtin13, tt.lookup)
+		}
+	}
+}
+
+func TestKind(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Kind
+	}{
+		{"4607001771234", KindProduct},
+		{"036000291452", KindProduct},
+		{"96385074", KindProduct},
+		{"9780201379624", KindBook},
+		{"9791234567896", KindBook},
+		{"9770317847001", KindPeriodical},
+		{"2001234567893", KindInStore},
+		{"2900000123458", KindInStore},
+		{"234567890129", KindInStore},  // UPC-A с системой 2 - весовой товар
+		{"0234567890129", KindInStore}, // он же тринадцатью цифрами: префикс 02
+		{"434567890123", KindInStore},  // UPC-A с системой 4 - код магазина
+		{"20000011", KindInStore},
+		{"04000013", KindInStore},
+	}
+	for _, tt := range tests {
+		code, err := Normalize(tt.input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := code.Kind(); got != tt.want {
+			t.Errorf("Kind(%s) = %d, ожидалось %d", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestISSN(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"9770317847001", "0317-8471"},
+		{"9772434561006", "2434-561X"},
+		{"4607001771234", ""},
+		{"9780201379624", ""},
+	}
+	for _, tt := range tests {
+		code, err := Normalize(tt.input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := code.ISSN(); got != tt.want {
+			t.Errorf("ISSN(%s) = %q, ожидалось %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestPlausible(t *testing.T) {
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{"4607001771235", true}, // контрольная цифра не проверяется
+		{"4 607001 771234", true},
+		{"96385074", true},
+		{"14607001771231", true},
+		{"1234567", false},
+		{"123456789012345", false},
+		{"привет", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := Plausible(tt.input); got != tt.want {
+			t.Errorf("Plausible(%q) = %v, ожидалось %v", tt.input, got, tt.want)
+		}
+	}
+}
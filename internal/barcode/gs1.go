@@ -0,0 +1,235 @@
+// barcode/gs1.go
+package barcode
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ErrNotGS1 - в коде нет GTIN: это не код маркировки, а, например, ссылка на сайт
+var ErrNotGS1 = errors.New("код не содержит GTIN")
+
+// Разделитель полей переменной длины (FNC1 / ASCII 29)
+const gs1Separator = '\x1d'
+
+// Идентификаторы применения (AI), которые нужны боту
+const (
+	aiGTIN   = "01"
+	aiBatch  = "10"
+	aiExpiry = "17"
+	aiSerial = "21"
+)
+
+// gs1Length - длина AI и его данных
+type gs1Length struct {
+	ai, data int
+}
+
+// Элементы заранее известной длины по первым двум цифрам AI (таблица
+// предопределенных длин GS1 General Specifications): после них разделитель
+// не ставится. Остальные AI заканчиваются разделителем или концом кода.
+var gs1FixedLength = map[string]gs1Length{
+	"00": {2, 18}, "01": {2, 14}, "02": {2, 14}, "03": {2, 14}, "04": {2, 16},
+	"11": {2, 6}, "12": {2, 6}, "13": {2, 6}, "14": {2, 6}, "15": {2, 6},
+	"16": {2, 6}, "17": {2, 6}, "18": {2, 6}, "19": {2, 6},
+	"20": {2, 2},
+	// Вес и размеры 31xx-36xx - четырехзначные AI
+	"31": {4, 6}, "32": {4, 6}, "33": {4, 6}, "34": {4, 6}, "35": {4, 6}, "36": {4, 6},
+	// GLN места доставки, оплаты и т.п. 410-417
+	"41": {3, 13},
+}
+
+// Криптохвост "Честного ЗНАКа" после серийного номера: 91<ключ>92<подпись>
+// у большинства товаров, 93<код проверки> у молочной продукции и воды
+const (
+	cryptoKeyLength       = 4
+	cryptoSignatureLength = 44
+	cryptoCheckLength     = 4
+)
+
+// GS1Data - поля кода маркировки: DataMatrix "Честного ЗНАКа", GS1 QR,
+// GS1-128. Пустые поля в коде не указаны.
+type GS1Data struct {
+	GTIN   string // 14 цифр
+	Serial string
+	Batch  string
+	Expiry time.Time // нулевое время - срок не указан
+}
+
+// Code возвращает GTIN как обычный штрих-код: по нему ищется продукт
+func (d *GS1Data) Code() Code {
+	// GTIN проверен при разборе
+	code, _ := Normalize(d.GTIN)
+	return code
+}
+
+// Expired - срок годности указан и уже прошел
+func (d *GS1Data) Expired(now time.Time) bool {
+	return !d.Expiry.IsZero() && now.After(d.Expiry.AddDate(0, 0, 1))
+}
+
+// ParseGS1 разбирает содержимое кода в формате GS1: строку элементов
+// "01<GTIN>21<серия><GS>17<ГГММДД>..." или ссылку GS1 Digital Link.
+func ParseGS1(data string) (*GS1Data, error) {
+	if strings.HasPrefix(data, "http://") || strings.HasPrefix(data, "https://") {
+		return parseGS1DigitalLink(data)
+	}
+
+	// Префикс символики (]d2 - DataMatrix, ]Q3 - QR, ]C1 - GS1-128) и FNC1 в начале
+	for _, prefix := range []string{"]d2", "]Q3", "]C1", "]e0"} {
+		data = strings.TrimPrefix(data, prefix)
+	}
+	data = strings.TrimLeft(data, string(gs1Separator))
+
+	result := &GS1Data{}
+	for data != "" {
+		if len(data) < 2 || !Digits(data[:2]) {
+			return nil, fmt.Errorf("%w: некорректный идентификатор в %q", ErrNotGS1, data)
+		}
+		ai, rest := data[:2], data[2:]
+		length, fixed := gs1FixedLength[ai]
+		if fixed && length.ai > 2 {
+			if len(data) < length.ai || !Digits(data[:length.ai]) {
+				return nil, fmt.Errorf("%w: обрезанный AI %s", ErrNotGS1, data)
+			}
+			ai, rest = data[:length.ai], data[length.ai:]
+		}
+
+		var value string
+		if fixed {
+			if len(rest) < length.data {
+				return nil, fmt.Errorf("%w: в AI %s меньше %d символов", ErrNotGS1, ai, length.data)
+			}
+			value, data = rest[:length.data], rest[length.data:]
+		} else {
+			var found bool
+			value, data, found = strings.Cut(rest, string(gs1Separator))
+			if !found {
+				data = ""
+				// Сканер потерял разделители, и криптохвост слился с серийным номером
+				if ai == aiSerial {
+					value, data = splitCryptoTail(value)
+				}
+			}
+		}
+		data = strings.TrimPrefix(data, string(gs1Separator))
+
+		if err := result.set(ai, value); err != nil {
+			return nil, err
+		}
+	}
+
+	if result.GTIN == "" {
+		return nil, ErrNotGS1
+	}
+	return result, nil
+}
+
+// splitCryptoTail отделяет от серийного номера без разделителей криптохвост
+// "Честного ЗНАКа" известной длины. Боту он не нужен, но без отделения
+// попал бы в серийный номер.
+func splitCryptoTail(serial string) (value, tail string) {
+	if n := len(serial) - (2 + cryptoKeyLength + 2 + cryptoSignatureLength); n > 0 &&
+		serial[n:n+2] == "91" && serial[n+2+cryptoKeyLength:n+4+cryptoKeyLength] == "92" {
+		return serial[:n], serial[n:]
+	}
+	if n := len(serial) - (2 + cryptoCheckLength); n > 0 && serial[n:n+2] == "93" {
+		return serial[:n], serial[n:]
+	}
+	return serial, ""
+}
+
+// parseGS1DigitalLink разбирает ссылку вида https://id.gs1.org/01/<GTIN>/10/<партия>?17=<срок>
+func parseGS1DigitalLink(link string) (*GS1Data, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNotGS1, err)
+	}
+
+	result := &GS1Data{}
+	// Сегменты разбираются до раскодирования: в партии может быть %2F
+	segments := strings.Split(strings.Trim(u.EscapedPath(), "/"), "/")
+	// Перед /01/ может быть произвольный путь сайта производителя
+	if start := slices.Index(segments, aiGTIN); start >= 0 {
+		for i := start; i+1 < len(segments); i += 2 {
+			value, err := url.PathUnescape(segments[i+1])
+			if err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrNotGS1, err)
+			}
+			if err := result.set(segments[i], value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if expiry := u.Query().Get(aiExpiry); expiry != "" {
+		if err := result.set(aiExpiry, expiry); err != nil {
+			return nil, err
+		}
+	}
+
+	if result.GTIN == "" {
+		return nil, ErrNotGS1
+	}
+	return result, nil
+}
+
+// set запоминает значение AI; AI, не нужные боту, пропускаются
+func (d *GS1Data) set(ai, value string) error {
+	switch ai {
+	case aiGTIN:
+		// В Digital Link GTIN может быть короче 14 цифр
+		code, err := Normalize(value)
+		if err != nil {
+			return fmt.Errorf("%w: некорректный GTIN %q", ErrNotGS1, value)
+		}
+		d.GTIN = code.GTIN
+	case aiSerial:
+		d.Serial = value
+	case aiBatch:
+		d.Batch = value
+	case aiExpiry:
+		expiry, err := parseGS1Date(value)
+		if err != nil {
+			return fmt.Errorf("%w: некорректный срок годности %q", ErrNotGS1, value)
+		}
+		d.Expiry = expiry
+	}
+	return nil
+}
+
+// parseGS1Date разбирает дату ГГММДД. День 00 означает последний день месяца.
+func parseGS1Date(value string) (time.Time, error) {
+	if len(value) != 6 || !Digits(value) {
+		return time.Time{}, fmt.Errorf("дата должна быть в формате ГГММДД")
+	}
+	yy := int(value[0]-'0')*10 + int(value[1]-'0')
+	month := time.Month(int(value[2]-'0')*10 + int(value[3]-'0'))
+	day := int(value[4]-'0')*10 + int(value[5]-'0')
+	if month < time.January || month > time.December {
+		return time.Time{}, fmt.Errorf("некорректный месяц")
+	}
+
+	// По правилам GS1 год берется в пределах 49 лет назад и 50 вперед от текущего
+	current := time.Now().Year()
+	year := current/100*100 + yy
+	switch {
+	case year-current > 50:
+		year -= 100
+	case current-year > 49:
+		year += 100
+	}
+
+	// Нулевой день следующего месяца - последний день этого
+	lastDay := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC)
+	if day == 0 {
+		return lastDay, nil
+	}
+	if day > lastDay.Day() {
+		return time.Time{}, fmt.Errorf("в месяце %d дней", lastDay.Day())
+	}
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC), nil
+}
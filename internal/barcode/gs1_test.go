@@ -0,0 +1,233 @@
+package barcode
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+const (
+	gs = string(gs1Separator)
+	// Криптохвост кода маркировки обуви и одежды: ключ проверки и подпись
+	cryptoKey       = "FFD0"
+	cryptoSignature = "MEUCIQD6ARjDmTSZv4SA0FJt9TyoRV8p7TS9dSkoP8Ka"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestParseGS1(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want GS1Data
+	}{
+		{
+			name: "Честный ЗНАК: обувь, одежда",
+			data: "0104607001771234" + "215Ygd%q,XcAXGc" + gs + "91" + cryptoKey + gs + "92" + cryptoSignature,
+			want: GS1Data{GTIN: "04607001771234", Serial: "5Ygd%q,XcAXGc"},
+		},
+		{
+			name: "Честный ЗНАК без разделителей",
+			data: "0104607001771234" + "215Ygd%q,XcAXGc" + "91" + cryptoKey + "92" + cryptoSignature,
+			want: GS1Data{GTIN: "04607001771234", Serial: "5Ygd%q,XcAXGc"},
+		},
+		{
+			name: "Честный ЗНАК: молоко",
+			data: "]d2" + gs + "0104600000000015" + "21JgXJ5." + gs + "93dGsx",
+			want: GS1Data{GTIN: "04600000000015", Serial: "JgXJ5."},
+		},
+		{
+			name: "молоко без разделителей",
+			data: "0104600000000015" + "21JgXJ5." + "93dGsx",
+			want: GS1Data{GTIN: "04600000000015", Serial: "JgXJ5."},
+		},
+		{
+			name: "молоко со сроком и партией",
+			data: "0104600000000015" + "21JgXJ5." + gs + "17261231" + "10L-42" + gs + "93dGsx",
+			want: GS1Data{GTIN: "04600000000015", Serial: "JgXJ5.", Batch: "L-42", Expiry: date(2026, time.December, 31)},
+		},
+		{
+			name: "GS1-128: срок, партия и серия",
+			data: "]C1" + "0104607001771234" + "17261231" + "10L-42" + gs + "21S1",
+			want: GS1Data{GTIN: "04607001771234", Serial: "S1", Batch: "L-42", Expiry: date(2026, time.December, 31)},
+		},
+		{
+			name: "вес и дата производства без разделителей",
+			data: "0104607001771234" + "3103000250" + "11260101" + "17260301",
+			want: GS1Data{GTIN: "04607001771234", Expiry: date(2026, time.March, 1)},
+		},
+		{
+			name: "GLN 410-417 фиксированной длины",
+			data: "0104607001771234" + "4144600000000015" + "4104600000000015" + "17260630",
+			want: GS1Data{GTIN: "04607001771234", Expiry: date(2026, time.June, 30)},
+		},
+		{
+			name: "SSCC и количество перед GTIN",
+			data: "00046070017712340007" + "02" + "04607001771234" + "37" + "12" + gs + "0104607001771234",
+			want: GS1Data{GTIN: "04607001771234"},
+		},
+		{
+			name: "день 00 - последний день месяца",
+			data: "0104607001771234" + "17280200",
+			want: GS1Data{GTIN: "04607001771234", Expiry: date(2028, time.February, 29)},
+		},
+		{
+			name: "серия из цифр 93 без криптохвоста не обрезается",
+			data: "0104607001771234" + "2193",
+			want: GS1Data{GTIN: "04607001771234", Serial: "93"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseGS1(tt.data)
+			if err != nil {
+				t.Fatalf("ParseGS1(%q): %v", tt.data, err)
+			}
+			if *got != tt.want {
+				t.Errorf("ParseGS1(%q) = %+v, ожидалось %+v", tt.data, *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseGS1Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"нет GTIN", "21ABC" + gs + "10L1"},
+		{"ссылка на сайт", "https://example.com/about"},
+		{"просто текст", "привет"},
+		{"неверная контрольная цифра GTIN", "0104607001771235"},
+		{"обрезанный GTIN", "01046070017712"},
+		{"обрезанный AI веса", "01046070017712343"},
+		{"обрезанный GLN", "0104607001771234" + "41446000"},
+		{"29 февраля не високосного года", "0104607001771234" + "17270229"},
+		{"31 ноября", "0104607001771234" + "17261131"},
+		{"13-й месяц", "0104607001771234" + "17261301"},
+		{"буквы в дате", "0104607001771234" + "1726A231"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := ParseGS1(tt.data); !errors.Is(err, ErrNotGS1) {
+				t.Errorf("ParseGS1(%q) = %+v, %v; ожидалась ErrNotGS1", tt.data, got, err)
+			}
+		})
+	}
+}
+
+func TestParseGS1DigitalLink(t *testing.T) {
+	tests := []struct {
+		name string
+		link string
+		want GS1Data
+	}{
+		{
+			name: "GTIN, партия и серия",
+			link: "https://id.gs1.org/01/04607001771234/10/L-42/21/S1",
+			want: GS1Data{GTIN: "04607001771234", Batch: "L-42", Serial: "S1"},
+		},
+		{
+			name: "срок годности в параметрах",
+			link: "https://id.gs1.org/01/04607001771234?17=261231",
+			want: GS1Data{GTIN: "04607001771234", Expiry: date(2026, time.December, 31)},
+		},
+		{
+			name: "путь сайта производителя и короткий GTIN",
+			link: "http://example.com/products/01/4607001771234/10/A%2F1",
+			want: GS1Data{GTIN: "04607001771234", Batch: "A/1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseGS1(tt.link)
+			if err != nil {
+				t.Fatalf("ParseGS1(%q): %v", tt.link, err)
+			}
+			if *got != tt.want {
+				t.Errorf("ParseGS1(%q) = %+v, ожидалось %+v", tt.link, *got, tt.want)
+			}
+		})
+	}
+
+	for _, link := range []string{
+		"https://id.gs1.org/10/L-42",
+		"https://id.gs1.org/01/04607001771235",
+		"https://id.gs1.org/01/04607001771234?17=270229",
+		"https://id.gs1.org/01/%zz",
+	} {
+		if _, err := ParseGS1(link); !errors.Is(err, ErrNotGS1) {
+			t.Errorf("ParseGS1(%q): ошибка %v, ожидалась ErrNotGS1", link, err)
+		}
+	}
+}
+
+func TestParseGS1Date(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Time // нулевое - дата некорректна
+	}{
+		{"261231", date(2026, time.December, 31)},
+		{"260131", date(2026, time.January, 31)},
+		{"260430", date(2026, time.April, 30)},
+		{"260431", time.Time{}},
+		{"280229", date(2028, time.February, 29)},
+		{"270229", time.Time{}},
+		{"270228", date(2027, time.February, 28)},
+		{"270200", date(2027, time.February, 28)},
+		{"261100", date(2026, time.November, 30)},
+		{"261200", date(2026, time.December, 31)},
+		{"260001", time.Time{}},
+		{"261301", time.Time{}},
+		{"261232", time.Time{}},
+		{"2612", time.Time{}},
+		{"26-2-1", time.Time{}},
+	}
+	for _, tt := range tests {
+		got, err := parseGS1Date(tt.value)
+		if tt.want.IsZero() {
+			if err == nil {
+				t.Errorf("parseGS1Date(%q) = %v, ожидалась ошибка", tt.value, got)
+			}
+			continue
+		}
+		if err != nil || !got.Equal(tt.want) {
+			t.Errorf("parseGS1Date(%q) = %v, %v; ожидалось %v", tt.value, got, err, tt.want)
+		}
+	}
+}
+
+func TestParseGS1DateCentury(t *testing.T) {
+	// Год берется в окне от 49 лет назад до 50 вперед
+	current := time.Now().Year()
+	for _, offset := range []int{-49, -1, 0, 1, 50} {
+		year := current + offset
+		value := time.Date(year, time.June, 1, 0, 0, 0, 0, time.UTC).Format("060102")
+		got, err := parseGS1Date(value)
+		if err != nil || got.Year() != year {
+			t.Errorf("parseGS1Date(%q) = %v, %v; ожидался %d год", value, got, err, year)
+		}
+	}
+}
+
+func TestGS1DataExpired(t *testing.T) {
+	data := GS1Data{Expiry: date(2026, time.March, 10)}
+	tests := []struct {
+		now  time.Time
+		want bool
+	}{
+		{date(2026, time.March, 9), false},
+		{date(2026, time.March, 10).Add(23 * time.Hour), false}, // весь последний день продукт годен
+		{date(2026, time.March, 11).Add(time.Minute), true},
+	}
+	for _, tt := range tests {
+		if got := data.Expired(tt.now); got != tt.want {
+			t.Errorf("Expired(%v) = %v, ожидалось %v", tt.now, got, tt.want)
+		}
+	}
+	if (&GS1Data{}).Expired(time.Now()) {
+		t.Error("код без срока годности считается просроченным")
+	}
+}
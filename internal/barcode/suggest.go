@@ -0,0 +1,30 @@
+// barcode/suggest.go
+package barcode
+
+// Suggestions возвращает коды, отличающиеся от введенного одной цифрой и
+// проходящие проверку контрольной цифры. Для каждой позиции подходит ровно
+// одна замена, поэтому кандидатов столько же, сколько цифр; какой из них
+// настоящий, можно понять только по базе продуктов.
+func Suggestions(s string) []string {
+	s = Clean(s)
+	if !Plausible(s) || Valid(s) {
+		return nil
+	}
+
+	var suggestions []string
+	digits := []byte(s)
+	for i := range digits {
+		original := digits[i]
+		for d := byte('0'); d <= '9'; d++ {
+			if d == original {
+				continue
+			}
+			digits[i] = d
+			if Valid(string(digits)) {
+				suggestions = append(suggestions, string(digits))
+			}
+		}
+		digits[i] = original
+	}
+	return suggestions
+}
@@ -0,0 +1,54 @@
+package barcode
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSuggestions(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		original string // настоящий код, в котором сделали опечатку
+	}{
+		{"EAN-13", "4607001771264", "4607001771234"},
+		{"опечатка в контрольной цифре", "4607001771235", "4607001771234"},
+		{"EAN-8", "96305074", "96385074"},
+		{"UPC-A", "036000291462", "036000291452"},
+		{"с пробелами", "4607 0017 71264", "4607001771234"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := Clean(tt.input)
+			suggestions := Suggestions(tt.input)
+			if !slices.Contains(suggestions, tt.original) {
+				t.Errorf("среди подсказок %q нет %q", suggestions, tt.original)
+			}
+			for _, s := range suggestions {
+				if !Valid(s) || len(s) != len(input) || differentDigits(s, input) != 1 {
+					t.Errorf("подсказка %q к %q", s, input)
+				}
+			}
+			// Для каждой позиции верная замена одна, а UPC-E может дать вторую
+			if len(suggestions) > 2*len(input) {
+				t.Errorf("подсказок %d для %d цифр", len(suggestions), len(input))
+			}
+		})
+	}
+
+	for _, input := range []string{"4607001771234", "1234567", "привет", ""} {
+		if suggestions := Suggestions(input); suggestions != nil {
+			t.Errorf("Suggestions(%q) = %q, ожидалось без подсказок", input, suggestions)
+		}
+	}
+}
+
+func differentDigits(a, b string) int {
+	n := 0
+	for i := range a {
+		if a[i] != b[i] {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,167 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/ajeanett/telbot/internal/barcode"
+	"github.com/ajeanett/telbot/internal/models"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Действие кнопки "Найти альтернативы" под анализом; аргументы - штрих-код и analysisView
+const actionAlternatives = "al"
+
+const (
+	// Продуктам с оценкой ниже предлагается поиск альтернатив
+	alternativesScoreLimit = 70
+	// Сколько альтернатив показывается
+	maxAlternatives = 3
+	// Поиск анализирует десятки продуктов и дольше обычного поиска по штрих-коду
+	alternativesTimeout = 30 * time.Second
+)
+
+// languageCountries - страна пользователя по языку Telegram для языков,
+// на которых говорят в основном в одной стране
+var languageCountries = map[string]string{
+	"ru": "en:russia",
+	"be": "en:belarus",
+	"kk": "en:kazakhstan",
+	"uk": "en:ukraine",
+	"uz": "en:uzbekistan",
+	"ky": "en:kyrgyzstan",
+	"hy": "en:armenia",
+	"ka": "en:georgia",
+	"az": "en:azerbaijan",
+	"pl": "en:poland",
+	"it": "en:italy",
+}
+
+func (b *Bot) registerAlternativesCallbacks() {
+	b.callbacks.handle(actionAlternatives, b.withAnalysis(b.onAlternatives))
+}
+
+// offersAlternatives - показывать ли под анализом кнопку поиска альтернатив
+func (b *Bot) offersAlternatives(result *models.AnalysisResult) bool {
+	return b.alternatives != nil &&
+		result.Product.ProductType == models.ProductTypeFood &&
+		len(result.Product.CategoriesTags) > 0 &&
+		result.Score < alternativesScoreLimit
+}
+
+// userCountry - страна пользователя для поиска альтернатив
+func (b *Bot) userCountry(languageCode string) string {
+	language, _, _ := strings.Cut(strings.ToLower(languageCode), "-")
+	if country, ok := languageCountries[language]; ok {
+		return country
+	}
+	return b.country
+}
+
+// handleAlternatives ищет альтернативы продукту по штрих-коду из аргумента
+func (b *Bot) handleAlternatives(ctx context.Context, req *request) {
+	code, err := barcode.Normalize(req.args)
+	if err != nil {
+		b.api.Send(tgbotapi.NewMessage(req.chatID, "Укажите штрих-код продукта, например:\n/alternatives 4600000000015"))
+		return
+	}
+
+	lookupCtx, cancel := context.WithTimeout(ctx, lookupTimeout)
+	defer cancel()
+	result, err := b.analyzeBarcode(lookupCtx, req.userID, code.LookupCode())
+	if err != nil {
+		log.Printf("Ошибка поиска продукта %s: %v", code.LookupCode(), err)
+		b.sendLookupError(req.chatID, code.LookupCode(), err)
+		return
+	}
+	var languageCode string
+	if req.message.From != nil {
+		languageCode = req.message.From.LanguageCode
+	}
+	b.sendAlternatives(ctx, req.chatID, req.userID, languageCode, result)
+}
+
+func (b *Bot) onAlternatives(ctx context.Context, query *tgbotapi.CallbackQuery, result *models.AnalysisResult, view analysisView) {
+	b.answerCallback(query, "")
+	b.sendAlternatives(ctx, query.Message.Chat.ID, query.From.ID, query.From.LanguageCode, result)
+}
+
+// sendAlternatives присылает до трех продуктов той же категории с оценкой
+// выше, которые продаются в стране пользователя и подходят ему по профилю
+func (b *Bot) sendAlternatives(ctx context.Context, chatID, userID int64, languageCode string, result *models.AnalysisResult) {
+	if result.Product.ProductType != models.ProductTypeFood || len(result.Product.CategoriesTags) == 0 {
+		b.api.Send(tgbotapi.NewMessage(chatID, "🤷 У продукта не указана категория в базе, подобрать замену не получится."))
+		return
+	}
+	b.api.Send(tgbotapi.NewMessage(chatID, "🔍 Ищу продукты лучше в той же категории..."))
+
+	ctx, cancel := context.WithTimeout(ctx, alternativesTimeout)
+	defer cancel()
+	// Профиль читается один раз: кандидатов в категории десятки
+	profile, err := b.store.Profiles().Get(ctx, userID)
+	if err != nil {
+		log.Printf("Ошибка чтения профиля %d: %v", userID, err)
+	}
+	found, err := b.alternatives.Find(ctx, result, b.userCountry(languageCode), func(candidate *models.AnalysisResult) bool {
+		b.analyzer.Personalize(candidate, profile)
+		return len(candidate.Unsuitable) == 0
+	})
+	if err != nil {
+		log.Printf("Ошибка поиска альтернатив для %s: %v", result.Product.Barcode, err)
+		b.sendError(chatID, "Не удалось найти альтернативы. Попробуйте позже.")
+		return
+	}
+
+	alternatives := found.Results[:min(len(found.Results), maxAlternatives)]
+	if len(alternatives) == 0 {
+		b.api.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf(
+			"🤷 Продуктов с оценкой выше %d в той же категории не нашлось.", result.Score)))
+		return
+	}
+
+	msg := tgbotapi.NewMessage(chatID, formatAlternatives(result, found.Category, alternatives))
+	msg.ParseMode = "Markdown"
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, alternative := range alternatives {
+		label := fmt.Sprintf("%s %s", verdictIcon(alternative.Verdict()), truncate(alternative.Product.DisplayName(), multiNameLimit))
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(label, b.callbacks.data(actionPickProduct, alternative.Product.Barcode)),
+		))
+	}
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	b.api.Send(msg)
+}
+
+// formatAlternatives - альтернативы с оценкой и тем, чем каждая лучше продукта
+func formatAlternatives(result *models.AnalysisResult, category string, alternatives []*models.AnalysisResult) string {
+	var message strings.Builder
+	message.WriteString(fmt.Sprintf("🔄 *Чем заменить «%s»* (оценка %d из 100)\n",
+		markdownStripper.Replace(truncate(result.Product.DisplayName(), multiNameLimit)), result.Score))
+	message.WriteString(fmt.Sprintf("Категория: %s\n\n", categoryName(category)))
+
+	for i, alternative := range alternatives {
+		name := markdownStripper.Replace(truncate(alternative.Product.DisplayName(), multiNameLimit))
+		message.WriteString(fmt.Sprintf("%d. %s *%s* - %d из 100", i+1, verdictIcon(alternative.Verdict()), name, alternative.Score))
+		if alternative.NutriScore != "" {
+			message.WriteString(" · Nutri-Score " + strings.ToUpper(alternative.NutriScore))
+		}
+		message.WriteString("\n")
+		if reasons := comparisonReasons(alternative, result); len(reasons) > 0 {
+			message.WriteString("   " + strings.Join(reasons, "; ") + "\n")
+		}
+	}
+	message.WriteString("\nВыберите продукт, чтобы увидеть подробный анализ.")
+	return message.String()
+}
+
+// categoryName делает тег категории читаемым: en:plain-yogurts -> plain yogurts
+func categoryName(tag string) string {
+	if _, name, ok := strings.Cut(tag, ":"); ok {
+		tag = name
+	}
+	return markdownStripper.Replace(strings.ReplaceAll(tag, "-", " "))
+}
@@ -0,0 +1,77 @@
+package bot
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/ajeanett/telbot/internal/models"
+	"github.com/ajeanett/telbot/internal/services"
+	"github.com/ajeanett/telbot/internal/testutil"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func yogurtProduct(code, name, nutriScore string) models.Product {
+	return models.Product{
+		Barcode:         code,
+		Name:            name,
+		CompositionRu:   "молоко, закваска",
+		ProductType:     models.ProductTypeFood,
+		NutriScoreGrade: nutriScore,
+		CategoriesTags:  []string{"en:dairies", "en:yogurts"},
+		CountriesTags:   []string{"en:russia"},
+	}
+}
+
+func newAlternativesBot(t *testing.T, products ...models.Product) *testBot {
+	t.Helper()
+	db := testutil.NewFakeProductDB(products...)
+	t.Cleanup(db.Close)
+	finder := services.NewAlternativeFinder(testSource(db), services.NewAnalyzer())
+	return newTestBotWithDB(t, db, Options{Alternatives: finder})
+}
+
+func TestAlternativesKeepsBestThree(t *testing.T) {
+	tb := newAlternativesBot(t,
+		yogurtProduct(testBarcode, "Йогурт сладкий", "d"),
+		yogurtProduct("2000000000015", "Йогурт C1", "c"),
+		yogurtProduct("2000000000022", "Йогурт A1", "a"),
+		yogurtProduct("2000000000039", "Йогурт B1", "b"),
+		yogurtProduct("2000000000046", "Йогурт C2", "c"),
+		yogurtProduct("2000000000053", "Йогурт A2", "a"),
+		yogurtProduct("2000000000060", "Йогурт E1", "e"),
+	)
+	tb.handle(testMessage("/alternatives " + testBarcode))
+
+	call := tb.waitForText(t, "Чем заменить")
+	text := call.Params.Get("text")
+	for _, name := range []string{"Йогурт A1", "Йогурт A2", "Йогурт B1"} {
+		if !strings.Contains(text, name) {
+			t.Errorf("в альтернативах нет %s:\n%s", name, text)
+		}
+	}
+	for _, name := range []string{"Йогурт C1", "Йогурт C2", "Йогурт E1"} {
+		if strings.Contains(text, name) {
+			t.Errorf("в альтернативах лишний %s:\n%s", name, text)
+		}
+	}
+
+	var markup tgbotapi.InlineKeyboardMarkup
+	if err := json.Unmarshal([]byte(call.Params.Get("reply_markup")), &markup); err != nil {
+		t.Fatal(err)
+	}
+	if len(markup.InlineKeyboard) != maxAlternatives {
+		t.Errorf("кнопок %d, ожидалось %d", len(markup.InlineKeyboard), maxAlternatives)
+	}
+}
+
+func TestAlternativesNotFound(t *testing.T) {
+	tb := newAlternativesBot(t,
+		yogurtProduct(testBarcode, "Йогурт сладкий", "d"),
+		yogurtProduct("2000000000060", "Йогурт E1", "e"),
+	)
+	tb.handle(testMessage("/alternatives " + testBarcode))
+
+	tb.waitForText(t, "не нашлось")
+}
@@ -0,0 +1,382 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"github.com/ajeanett/telbot/internal/models"
+	"github.com/ajeanett/telbot/internal/services"
+	"log"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// analysisView - какие разделы раскрыты в сообщении с анализом.
+// Передается в данных кнопок, чтобы при следующем нажатии не свернуть уже раскрытое.
+type analysisView uint8
+
+const (
+	viewFullComposition analysisView = 1 << iota
+	viewAdditives
+	viewScore
+)
+
+const (
+	// Сколько символов состава показывать до нажатия "Показать полный состав"
+	compositionPreviewLimit = 400
+	// Telegram не принимает сообщения длиннее 4096 символов
+	messageLimit = 4096
+)
+
+// Действия кнопок под анализом; аргументы - штрих-код и analysisView
+const (
+	actionComposition = "ac"
+	actionAdditives   = "aa"
+	actionScore       = "as"
+	actionFavorite    = "af"
+	actionCompare     = "am"
+	actionReport      = "ar"
+)
+
+func (b *Bot) registerAnalysisCallbacks() {
+	b.callbacks.handle(actionComposition, b.withAnalysis(b.onShowComposition))
+	b.callbacks.handle(actionAdditives, b.withAnalysis(b.onShowAdditives))
+	b.callbacks.handle(actionScore, b.withAnalysis(b.onShowScore))
+	b.callbacks.handle(actionFavorite, b.withAnalysis(b.onToggleFavorite))
+	b.callbacks.handle(actionCompare, b.withAnalysis(b.onCompare))
+	b.callbacks.handle(actionReport, b.withAnalysis(b.onReport))
+}
+
+// analysisAction - обработчик кнопки под анализом продукта
+type analysisAction func(ctx context.Context, query *tgbotapi.CallbackQuery, result *models.AnalysisResult, view analysisView)
+
+// withAnalysis разбирает аргументы кнопки и заново анализирует продукт:
+// он почти всегда в кэше, а правила и профиль могли измениться
+func (b *Bot) withAnalysis(action analysisAction) callbackHandler {
+	return func(ctx context.Context, query *tgbotapi.CallbackQuery, args []string) {
+		if query.Message == nil || len(args) != 2 {
+			b.answerCallback(query, "")
+			return
+		}
+		view, err := strconv.ParseUint(args[1], 10, 8)
+		if err != nil {
+			b.answerCallback(query, "")
+			return
+		}
+
+		lookupCtx, cancel := context.WithTimeout(ctx, lookupTimeout)
+		defer cancel()
+		result, err := b.analyzeBarcode(lookupCtx, query.From.ID, args[0])
+		if err != nil {
+			log.Printf("Ошибка поиска продукта %s: %v", args[0], err)
+			b.answerCallback(query, "Не удалось загрузить продукт, попробуйте позже")
+			return
+		}
+		action(ctx, query, result, analysisView(view))
+	}
+}
+
+// analysisKeyboard - кнопки под анализом. Уже раскрытые разделы не предлагаются.
+func (b *Bot) analysisKeyboard(ctx context.Context, userID int64, result *models.AnalysisResult, view analysisView) tgbotapi.InlineKeyboardMarkup {
+	barcode := result.Product.Barcode
+	data := func(action string) string {
+		return b.callbacks.data(action, barcode, strconv.Itoa(int(view)))
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	var details []tgbotapi.InlineKeyboardButton
+	if view&viewFullComposition == 0 && len([]rune(result.Product.DisplayComposition())) > compositionPreviewLimit {
+		details = append(details, tgbotapi.NewInlineKeyboardButtonData("📜 Показать полный состав", data(actionComposition)))
+	}
+	if view&viewAdditives == 0 && len(b.analyzer.MatchedRules(result.Product)) > 0 {
+		details = append(details, tgbotapi.NewInlineKeyboardButtonData("🧪 Подробнее о добавках", data(actionAdditives)))
+	}
+	if view&viewScore == 0 && len(result.Findings) > 0 {
+		details = append(details, tgbotapi.NewInlineKeyboardButtonData("🎯 Почему такая оценка", data(actionScore)))
+	}
+	if len(details) > 0 {
+		rows = append(rows, details)
+	}
+
+	favoriteLabel := "⭐ В избранное"
+	if ok, err := b.store.Favorites().Has(ctx, userID, barcode); err == nil && ok {
+		favoriteLabel = "🌟 В избранном"
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(favoriteLabel, data(actionFavorite)),
+		tgbotapi.NewInlineKeyboardButtonData("⚖️ Сравнить", data(actionCompare)),
+	))
+	if b.offersAlternatives(result) {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔄 Найти альтернативы", data(actionAlternatives)),
+		))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("✏️ Сообщить об ошибке", data(actionReport)),
+	))
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// editAnalysis перерисовывает сообщение с анализом вместо отправки нового
+func (b *Bot) editAnalysis(ctx context.Context, query *tgbotapi.CallbackQuery, result *models.AnalysisResult, view analysisView, extra string) {
+	chatID := query.Message.Chat.ID
+	edit := tgbotapi.NewEditMessageTextAndMarkup(chatID, query.Message.MessageID,
+		b.formatAnalysis(result, view, extra), b.analysisKeyboard(ctx, query.From.ID, result, view))
+	edit.ParseMode = "Markdown"
+	if _, err := b.api.Send(edit); err != nil {
+		log.Printf("Ошибка обновления сообщения в чате %d: %v", chatID, err)
+	}
+}
+
+func (b *Bot) onShowComposition(ctx context.Context, query *tgbotapi.CallbackQuery, result *models.AnalysisResult, view analysisView) {
+	b.answerCallback(query, "")
+	b.editAnalysis(ctx, query, result, view|viewFullComposition, "")
+}
+
+func (b *Bot) onShowAdditives(ctx context.Context, query *tgbotapi.CallbackQuery, result *models.AnalysisResult, view analysisView) {
+	b.answerCallback(query, "")
+	b.editAnalysis(ctx, query, result, view|viewAdditives, "")
+}
+
+func (b *Bot) onShowScore(ctx context.Context, query *tgbotapi.CallbackQuery, result *models.AnalysisResult, view analysisView) {
+	b.answerCallback(query, "")
+	b.editAnalysis(ctx, query, result, view|viewScore, "")
+}
+
+func (b *Bot) onToggleFavorite(ctx context.Context, query *tgbotapi.CallbackQuery, result *models.AnalysisResult, view analysisView) {
+	userID := query.From.ID
+	barcode := result.Product.Barcode
+
+	favorites := b.store.Favorites()
+	isFavorite, err := favorites.Has(ctx, userID, barcode)
+	if err == nil {
+		if isFavorite {
+			err = favorites.Remove(ctx, userID, barcode)
+		} else {
+			err = favorites.Add(ctx, &models.Favorite{UserID: userID, Barcode: barcode, ProductName: result.Product.DisplayName()})
+		}
+	}
+	if err != nil {
+		log.Printf("Ошибка изменения избранного пользователя %d: %v", userID, err)
+		b.answerCallback(query, "Не удалось изменить избранное")
+		return
+	}
+
+	if isFavorite {
+		b.answerCallback(query, "Удалено из избранного")
+	} else {
+		b.answerCallback(query, "⭐ Добавлено в избранное")
+	}
+	markup := b.analysisKeyboard(ctx, userID, result, view)
+	b.api.Send(tgbotapi.NewEditMessageReplyMarkup(query.Message.Chat.ID, query.Message.MessageID, markup))
+}
+
+// onCompare запоминает первый продукт, а на втором дописывает сравнение
+func (b *Bot) onCompare(ctx context.Context, query *tgbotapi.CallbackQuery, result *models.AnalysisResult, view analysisView) {
+	userID := query.From.ID
+	barcode := result.Product.Barcode
+
+	b.pendingMu.Lock()
+	first, ok := b.pendingCompare[userID]
+	if !ok || first == barcode {
+		b.pendingCompare[userID] = barcode
+	} else {
+		delete(b.pendingCompare, userID)
+	}
+	b.pendingMu.Unlock()
+
+	if !ok || first == barcode {
+		b.answerCallback(query, "Продукт выбран. Откройте второй и нажмите «Сравнить» под ним.")
+		b.editAnalysis(ctx, query, result, view, "⚖️ _Продукт выбран для сравнения. Откройте второй продукт и нажмите «Сравнить»._")
+		return
+	}
+
+	lookupCtx, cancel := context.WithTimeout(ctx, lookupTimeout)
+	defer cancel()
+	other, err := b.analyzeBarcode(lookupCtx, userID, first)
+	if err != nil {
+		log.Printf("Ошибка поиска продукта %s: %v", first, err)
+		b.answerCallback(query, "Не удалось загрузить первый продукт")
+		return
+	}
+	b.answerCallback(query, "")
+	b.editAnalysis(ctx, query, result, view, formatComparison([]*models.AnalysisResult{other, result}))
+}
+
+// onReport ждет от пользователя следующее сообщение с описанием ошибки
+func (b *Bot) onReport(ctx context.Context, query *tgbotapi.CallbackQuery, result *models.AnalysisResult, view analysisView) {
+	b.pendingMu.Lock()
+	b.pendingFeedback[query.From.ID] = result.Product.Barcode
+	b.pendingMu.Unlock()
+
+	b.answerCallback(query, "Опишите ошибку следующим сообщением")
+	b.editAnalysis(ctx, query, result, view,
+		"✏️ _Напишите одним сообщением, что не так с данными о продукте. Любая команда отменит отправку._")
+}
+
+// takeFeedback сохраняет сообщение как отзыв, если пользователь нажал
+// "Сообщить об ошибке". Возвращает true, если сообщение обработано.
+func (b *Bot) takeFeedback(ctx context.Context, message *tgbotapi.Message, text string) bool {
+	userID := userIDOf(message)
+
+	b.pendingMu.Lock()
+	barcode, ok := b.pendingFeedback[userID]
+	if ok {
+		delete(b.pendingFeedback, userID)
+	}
+	b.pendingMu.Unlock()
+
+	// Команда или новый штрих-код отменяют отзыв
+	if !ok || text == "" || strings.HasPrefix(text, "/") || isNumeric(text) {
+		return false
+	}
+
+	feedback := &models.Feedback{
+		UserID:  userID,
+		ChatID:  message.Chat.ID,
+		Barcode: barcode,
+		Message: text,
+	}
+	if err := b.store.Feedback().Add(ctx, feedback); err != nil {
+		log.Printf("Ошибка сохранения отзыва: %v", err)
+		b.sendError(message.Chat.ID, "Не удалось сохранить сообщение. Попробуйте позже.")
+		return true
+	}
+	log.Printf("📝 Сообщение об ошибке в продукте %s от %d", barcode, userID)
+
+	reply := tgbotapi.NewMessage(message.Chat.ID, "🙏 Спасибо! Мы проверим данные о продукте.")
+	reply.ReplyToMessageID = message.MessageID
+	b.api.Send(reply)
+	return true
+}
+
+// writeAdditiveDetails объясняет каждое сработавшее правило
+func writeAdditiveDetails(message *strings.Builder, rules []*services.Rule, productType string) {
+	if len(rules) == 0 {
+		return
+	}
+	message.WriteString("🧪 *Подробнее о добавках:*\n")
+	for _, rule := range rules {
+		title := rule.Title
+		if rule.Code != "" {
+			title = strings.ToUpper(rule.Code) + " " + title
+		}
+		message.WriteString(fmt.Sprintf("%s *%s*", severityIcon(rule.SeverityFor(productType)), markdownStripper.Replace(title)))
+		if rule.Explanation != "" {
+			message.WriteString(" - " + markdownStripper.Replace(rule.Explanation))
+		}
+		message.WriteString("\n")
+	}
+	message.WriteString("\n")
+}
+
+// Названия категорий находок в разборе оценки
+var findingCategoryNames = map[string]string{
+	models.FindingAdditives:  "Добавки и ингредиенты",
+	models.FindingNutrition:  "Пищевая ценность",
+	models.FindingProcessing: "Обработка",
+	models.FindingAllergens:  "Аллергены",
+}
+
+// writeScoreBreakdown объясняет оценку: сколько баллов сняла каждая категория
+// и каждая находка в ней
+func writeScoreBreakdown(message *strings.Builder, result *models.AnalysisResult) {
+	message.WriteString(fmt.Sprintf("🎯 *Почему %d из 100:*\n", result.Score))
+	penalty := 0
+	for _, category := range models.FindingCategories {
+		findings := result.FindingsIn(category)
+		total := 0
+		for _, f := range findings {
+			total += f.Points
+		}
+		if total == 0 {
+			continue
+		}
+		penalty += total
+		message.WriteString(fmt.Sprintf("*%s:* −%d\n", findingCategoryNames[category], total))
+		for _, f := range findings {
+			line := fmt.Sprintf("%s %s", findingIcon(f), markdownStripper.Replace(f.Title))
+			// Показываем найденное в составе, если оно записано не так, как в правиле
+			if f.Source == models.SourceComposition && !strings.Contains(strings.ToLower(f.Title), strings.ToLower(f.Text)) {
+				line += fmt.Sprintf(" («%s»)", markdownStripper.Replace(f.Text))
+			}
+			message.WriteString(fmt.Sprintf("%s −%d\n", line, f.Points))
+		}
+	}
+	if penalty > 100 {
+		message.WriteString(fmt.Sprintf("_Штрафы в сумме: %d. Ниже нуля оценка не опускается._\n", penalty))
+	}
+	message.WriteString("\n")
+}
+
+// findingIcon - значок добавки по опасности, а пищевой ценности и обработки - по "светофору"
+func findingIcon(f models.Finding) string {
+	if f.Category == models.FindingAdditives || f.Severity == services.SeverityLow {
+		return severityIcon(f.Severity)
+	}
+	return levelIcon(models.NutrientLevel(f.Severity))
+}
+
+func severityIcon(severity string) string {
+	switch severity {
+	case services.SeverityHigh:
+		return "🚫"
+	case services.SeverityMedium:
+		return "⚠️"
+	default:
+		return "▫️"
+	}
+}
+
+// Граница блока моноширинного текста в Markdown
+const codeFence = "```\n"
+
+// limitMessage обрезает текст до лимита Telegram по границе строки: разметка
+// в ответах бота закрывается в той же строке, где открыта. Исключение -
+// блоки ```, например таблица сравнения: оборванный блок закрывается,
+// иначе Telegram не разберет разметку и не отправит сообщение.
+func limitMessage(text string) string {
+	if utf8.RuneCountInString(text) <= messageLimit {
+		return text
+	}
+	cut := string([]rune(text)[:messageLimit-1-len(codeFence)])
+	if i := strings.LastIndexByte(cut, '\n'); i > 0 {
+		cut = cut[:i+1]
+	}
+	if strings.Count(cut, "```")%2 == 1 {
+		if strings.HasSuffix(cut, codeFence) {
+			// От блока осталось только открытие
+			cut = strings.TrimSuffix(cut, codeFence)
+		} else {
+			cut += codeFence
+		}
+	}
+	return cut + "…"
+}
+
+// Сколько последних сообщений об ошибках показывает /reports
+const reportsLimit = 20
+
+// handleReports показывает администратору последние сообщения об ошибках
+func (b *Bot) handleReports(ctx context.Context, req *request) {
+	reports, err := b.store.Feedback().List(ctx, reportsLimit)
+	if err != nil {
+		log.Printf("Ошибка чтения сообщений об ошибках: %v", err)
+		b.sendError(req.chatID, "Не удалось загрузить сообщения")
+		return
+	}
+	if len(reports) == 0 {
+		b.api.Send(tgbotapi.NewMessage(req.chatID, "📭 Сообщений об ошибках нет"))
+		return
+	}
+
+	var message strings.Builder
+	message.WriteString("📝 Последние сообщения об ошибках:\n\n")
+	for _, report := range reports {
+		message.WriteString(fmt.Sprintf("%s · %s · пользователь %d\n%s\n\n",
+			report.CreatedAt.Format("02.01 15:04"), report.Barcode, report.UserID, report.Message))
+	}
+	b.api.Send(tgbotapi.NewMessage(req.chatID, limitMessage(message.String())))
+}
@@ -0,0 +1,31 @@
+package bot
+
+import tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+// Sender отправляет запросы к Bot API
+type Sender interface {
+	Send(c tgbotapi.Chattable) (tgbotapi.Message, error)
+	Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error)
+	// Методы, для которых в библиотеке нет готового Chattable (setWebhook с secret_token)
+	MakeRequest(endpoint string, params tgbotapi.Params) (*tgbotapi.APIResponse, error)
+}
+
+// FileGetter возвращает ссылку для скачивания файла, присланного пользователем
+type FileGetter interface {
+	GetFileDirectURL(fileID string) (string, error)
+}
+
+// Updater - источник обновлений для long polling
+type Updater interface {
+	GetUpdatesChan(config tgbotapi.UpdateConfig) tgbotapi.UpdatesChannel
+	StopReceivingUpdates()
+}
+
+// API - методы Telegram, от которых зависит бот. *tgbotapi.BotAPI ему
+// удовлетворяет; в тестах вместо него подставляется клиент поддельного
+// сервера из internal/testutil.
+type API interface {
+	Sender
+	FileGetter
+	Updater
+}
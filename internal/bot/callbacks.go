@@ -0,0 +1,99 @@
+package bot
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"log"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Telegram ограничивает callback_data 64 байтами, поэтому данные кнопок
+// компактные: "действие:арг1:арг2.подпись". Подпись - обрезанный HMAC-SHA256,
+// чтобы нельзя было подделать кнопку и, например, открыть чужую запись истории.
+const (
+	callbackDataLimit = 64
+	callbackSigLen    = 8 // символов base64 - 48 бит подписи
+	callbackArgSep    = ":"
+	callbackSigSep    = "."
+)
+
+// callbackHandler обрабатывает нажатие кнопки с проверенными аргументами.
+// Обработчик сам отвечает на query через answerCallback.
+type callbackHandler func(ctx context.Context, query *tgbotapi.CallbackQuery, args []string)
+
+// callbackRouter подписывает данные кнопок и направляет нажатия обработчикам
+type callbackRouter struct {
+	secret   []byte
+	handlers map[string]callbackHandler
+}
+
+func newCallbackRouter(secret []byte) *callbackRouter {
+	return &callbackRouter{
+		secret:   secret,
+		handlers: make(map[string]callbackHandler),
+	}
+}
+
+// handle регистрирует обработчик действия. Имена действий короткие,
+// они занимают место в 64 байтах callback_data.
+func (r *callbackRouter) handle(action string, handler callbackHandler) {
+	if _, ok := r.handlers[action]; ok {
+		panic("callback уже зарегистрирован: " + action)
+	}
+	r.handlers[action] = handler
+}
+
+// data собирает подписанные данные кнопки. Аргументы не должны содержать ":" и ".".
+func (r *callbackRouter) data(action string, args ...string) string {
+	payload := strings.Join(append([]string{action}, args...), callbackArgSep)
+	data := payload + callbackSigSep + r.sign(payload)
+	if len(data) > callbackDataLimit {
+		// Ошибка в коде кнопки, а не во вводе пользователя
+		log.Printf("⚠️ callback_data длиннее %d байт: %s", callbackDataLimit, data)
+	}
+	return data
+}
+
+// dispatch проверяет подпись и вызывает обработчик действия
+func (r *callbackRouter) dispatch(ctx context.Context, b *Bot, query *tgbotapi.CallbackQuery) {
+	payload, sig, ok := strings.Cut(query.Data, callbackSigSep)
+	if !ok || !hmac.Equal([]byte(sig), []byte(r.sign(payload))) {
+		b.answerCallback(query, "Кнопка устарела. Отправьте штрих-код еще раз.")
+		return
+	}
+
+	parts := strings.Split(payload, callbackArgSep)
+	handler, ok := r.handlers[parts[0]]
+	if !ok {
+		b.answerCallback(query, "")
+		return
+	}
+	handler(ctx, query, parts[1:])
+}
+
+func (r *callbackRouter) sign(payload string) string {
+	mac := hmac.New(sha256.New, r.secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))[:callbackSigLen]
+}
+
+// callbackSecret возвращает ключ подписи кнопок. Если он не задан,
+// ключ выводится из токена бота - так кнопки переживают перезапуск.
+func callbackSecret(secret, token string) []byte {
+	if secret != "" {
+		return []byte(secret)
+	}
+	sum := sha256.Sum256([]byte("telbot-callback:" + token))
+	return sum[:]
+}
+
+// answerCallback убирает "часики" на кнопке; непустой текст показывается всплывающим уведомлением
+func (b *Bot) answerCallback(query *tgbotapi.CallbackQuery, text string) {
+	if _, err := b.api.Request(tgbotapi.NewCallback(query.ID, text)); err != nil {
+		log.Printf("Ошибка ответа на callback: %v", err)
+	}
+}
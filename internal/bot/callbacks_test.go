@@ -0,0 +1,147 @@
+package bot
+
+import (
+	"context"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const staleButton = "Кнопка устарела. Отправьте штрих-код еще раз."
+
+// recordCallbacks регистрирует действие "t", запоминающее аргументы нажатий
+func recordCallbacks(tb *testBot) *[][]string {
+	var calls [][]string
+	tb.callbacks.handle("t", func(ctx context.Context, query *tgbotapi.CallbackQuery, args []string) {
+		calls = append(calls, args)
+		tb.answerCallback(query, "")
+	})
+	return &calls
+}
+
+func TestCallbackRoundTrip(t *testing.T) {
+	tb := newTestBot(t, Options{})
+	calls := recordCallbacks(tb)
+
+	for _, args := range [][]string{nil, {testBarcode}, {testBarcode, "3"}, {"", "x"}} {
+		*calls = nil
+		data := tb.callbacks.data("t", args...)
+		payload, sig, ok := strings.Cut(data, callbackSigSep)
+		if !ok || len(sig) != callbackSigLen || payload != strings.Join(append([]string{"t"}, args...), callbackArgSep) {
+			t.Errorf("данные кнопки %q", data)
+		}
+		tb.callback(data)
+		if len(*calls) != 1 || !slices.Equal((*calls)[0], args) {
+			t.Errorf("для %q обработчик получил %q, ожидалось %q", data, *calls, args)
+		}
+	}
+}
+
+func TestCallbackRejectsForgedData(t *testing.T) {
+	tb := newTestBot(t, Options{})
+	calls := recordCallbacks(tb)
+	valid := tb.callbacks.data("t", testBarcode)
+	payload, sig, _ := strings.Cut(valid, callbackSigSep)
+
+	// Кнопка, подписанная другим ключом: сменили CALLBACK_SECRET или токен
+	other := newCallbackRouter([]byte("old-secret"))
+
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"подменен аргумент", strings.Replace(payload, testBarcode, "4600000000015", 1) + callbackSigSep + sig},
+		{"подменено действие", "ho" + strings.TrimPrefix(payload, "t") + callbackSigSep + sig},
+		{"испорчена подпись", payload + callbackSigSep + strings.Repeat("A", callbackSigLen)},
+		{"обрезана подпись", payload + callbackSigSep + sig[:callbackSigLen-1]},
+		{"нет подписи", payload},
+		{"пустые данные", ""},
+		{"устаревший ключ", other.data("t", testBarcode)},
+		{"старый формат без подписи", "history:open:1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tb.callback(tt.data)
+			if answer := tb.lastAnswer(t); answer != staleButton {
+				t.Errorf("на %q ответ %q, ожидалось %q", tt.data, answer, staleButton)
+			}
+		})
+	}
+	if len(*calls) != 0 {
+		t.Errorf("обработчик вызван с поддельными данными: %q", *calls)
+	}
+}
+
+func TestCallbackUnknownAction(t *testing.T) {
+	tb := newTestBot(t, Options{})
+	// Подпись верна, но действие убрали из новой версии бота
+	tb.callback(tb.callbacks.data("zz", "1"))
+	if answer := tb.lastAnswer(t); answer != "" {
+		t.Errorf("на неизвестное действие ответ %q, ожидалось просто убрать часики", answer)
+	}
+}
+
+func TestCallbackRouterRejectsDuplicateAction(t *testing.T) {
+	router := newCallbackRouter([]byte("secret"))
+	router.handle("t", nil)
+	defer func() {
+		if recover() == nil {
+			t.Error("повторная регистрация действия не вызвала панику")
+		}
+	}()
+	router.handle("t", nil)
+}
+
+// TestCallbackDataLimit проверяет самые длинные данные каждой кнопки бота
+func TestCallbackDataLimit(t *testing.T) {
+	tb := newTestBot(t, Options{})
+	const gtin14 = "12345678901231"
+	longest := map[string][]string{
+		actionComposition:     {gtin14, strconv.Itoa(math.MaxUint8)},
+		actionAdditives:       {gtin14, strconv.Itoa(math.MaxUint8)},
+		actionScore:           {gtin14, strconv.Itoa(math.MaxUint8)},
+		actionFavorite:        {gtin14, strconv.Itoa(math.MaxUint8)},
+		actionCompare:         {gtin14, strconv.Itoa(math.MaxUint8)},
+		actionReport:          {gtin14, strconv.Itoa(math.MaxUint8)},
+		actionAlternatives:    {gtin14, strconv.Itoa(math.MaxUint8)},
+		actionHistoryPage:     {strconv.Itoa(math.MaxInt32)},
+		actionHistoryOpen:     {strconv.FormatInt(math.MaxInt64, 10)},
+		actionHistoryNoop:     nil,
+		actionPickProduct:     {gtin14},
+		actionCompareToggle:   {gtin14},
+		actionCompareRun:      nil,
+		actionLabel:           {gtin14},
+		actionProfileSection:  {profileSectionAllergens},
+		actionProfileAllergen: {"sulphur-dioxide-and-sulphites"},
+		actionProfileDiet:     {"lactose_free"},
+		actionProfileClear:    nil,
+	}
+
+	for action := range tb.callbacks.handlers {
+		if _, ok := longest[action]; !ok {
+			t.Errorf("для действия %q не указаны самые длинные аргументы", action)
+		}
+	}
+	for action, args := range longest {
+		if data := tb.callbacks.data(action, args...); len(data) > callbackDataLimit {
+			t.Errorf("callback_data %q: %d байт, Telegram принимает до %d", data, len(data), callbackDataLimit)
+		}
+	}
+}
+
+func TestCallbackSecret(t *testing.T) {
+	if got := string(callbackSecret("explicit", "token")); got != "explicit" {
+		t.Errorf("заданный ключ заменен: %q", got)
+	}
+	derived := callbackSecret("", "token")
+	if !slices.Equal(derived, callbackSecret("", "token")) {
+		t.Error("ключ из токена меняется между запусками")
+	}
+	if slices.Equal(derived, callbackSecret("", "other-token")) || slices.Equal(derived, []byte("token")) {
+		t.Error("ключ из токена не зависит от токена или совпадает с ним")
+	}
+}
@@ -0,0 +1,143 @@
+package bot
+
+import (
+	"context"
+	"strings"
+	"unicode"
+
+	"github.com/ajeanett/telbot/internal/services"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// command - команда бота вида /name аргументы
+type command struct {
+	name    string
+	aliases []string // можно набрать вместо name, в меню не показываются
+	// Описание для меню Telegram и /help; пустое - команда скрыта из меню
+	description string
+	usage       string // подсказка по аргументам для /help, например "[clear]"
+	adminOnly   bool
+	handler     handlerFunc
+}
+
+// commandRegistry хранит команды и разбирает текст сообщений
+type commandRegistry struct {
+	commands []*command
+	byName   map[string]*command
+}
+
+func newCommandRegistry() *commandRegistry {
+	return &commandRegistry{byName: make(map[string]*command)}
+}
+
+func (r *commandRegistry) register(cmd *command) {
+	for _, name := range append([]string{cmd.name}, cmd.aliases...) {
+		if _, ok := r.byName[name]; ok {
+			panic("команда уже зарегистрирована: " + name)
+		}
+		r.byName[name] = cmd
+	}
+	r.commands = append(r.commands, cmd)
+}
+
+// parse разбирает "/name@bot аргументы". Упоминание чужого бота в группе
+// означает, что команда адресована не нам.
+func (r *commandRegistry) parse(text, botName string) (cmd *command, args string, ok bool) {
+	if !strings.HasPrefix(text, "/") {
+		return nil, "", false
+	}
+	// Аргументы отделяют пробелом или переводом строки
+	head, args := text[1:], ""
+	if i := strings.IndexFunc(head, unicode.IsSpace); i >= 0 {
+		head, args = head[:i], head[i:]
+	}
+	name, mention, hasMention := strings.Cut(head, "@")
+	if hasMention && !strings.EqualFold(mention, botName) {
+		return nil, "", false
+	}
+	cmd, ok = r.byName[strings.ToLower(name)]
+	return cmd, strings.TrimSpace(args), ok
+}
+
+// menu возвращает команды для setMyCommands
+func (r *commandRegistry) menu() []tgbotapi.BotCommand {
+	var menu []tgbotapi.BotCommand
+	for _, cmd := range r.commands {
+		if cmd.description != "" && !cmd.adminOnly {
+			menu = append(menu, tgbotapi.BotCommand{Command: cmd.name, Description: cmd.description})
+		}
+	}
+	return menu
+}
+
+// registerCommands описывает все команды бота
+func (b *Bot) registerCommands() {
+	b.commands.register(&command{
+		name:    "start",
+		handler: func(ctx context.Context, req *request) { b.sendWelcomeMessage(req.chatID) },
+	})
+	b.commands.register(&command{
+		name:        "help",
+		aliases:     []string{"помощь"},
+		description: "Как пользоваться ботом",
+		handler:     func(ctx context.Context, req *request) { b.sendHelpMessage(req.chatID) },
+	})
+	b.commands.register(&command{
+		name:        "history",
+		aliases:     []string{"история"},
+		description: "Ранее проверенные продукты",
+		usage:       "[clear]",
+		handler:     func(ctx context.Context, req *request) { b.handleHistory(ctx, req.message, req.args) },
+	})
+	b.commands.register(&command{
+		name:        "compare",
+		aliases:     []string{"сравнить"},
+		description: "Сравнить продукты",
+		usage:       "[штрих-коды]",
+		handler:     b.handleCompare,
+	})
+	if _, ok := b.barcodeDetector.(services.MultiBarcodeDecoder); ok {
+		b.commands.register(&command{
+			name:        "shelf",
+			aliases:     []string{"полка"},
+			description: "Несколько продуктов на одном фото",
+			handler:     b.handleShelf,
+		})
+	}
+	if b.alternatives != nil {
+		b.commands.register(&command{
+			name:        "alternatives",
+			aliases:     []string{"замена"},
+			description: "Продукты лучше в той же категории",
+			usage:       "<штрих-код>",
+			handler:     b.handleAlternatives,
+		})
+	}
+	b.commands.register(&command{
+		name:        "profile",
+		aliases:     []string{"профиль"},
+		description: "Аллергии, диета и исключения",
+		usage:       "[allergens|diet|avoid|clear]",
+		handler:     func(ctx context.Context, req *request) { b.handleProfile(ctx, req.message, req.args) },
+	})
+	if b.textRecognizer != nil {
+		b.commands.register(&command{
+			name:        "label",
+			aliases:     []string{"состав"},
+			description: "Проверить продукт по фото состава",
+			handler:     b.handleLabel,
+		})
+	}
+	b.commands.register(&command{
+		name:      "reports",
+		adminOnly: true,
+		handler:   func(ctx context.Context, req *request) { b.handleReports(ctx, req) },
+	})
+}
+
+// publishCommands регистрирует меню команд в Telegram
+func (b *Bot) publishCommands() error {
+	_, err := b.api.Request(tgbotapi.NewSetMyCommands(b.commands.menu()...))
+	return err
+}
@@ -0,0 +1,115 @@
+package bot
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/ajeanett/telbot/internal/testutil"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestCommandParse(t *testing.T) {
+	registry := newCommandRegistry()
+	for _, name := range []string{"start", "history", "compare"} {
+		registry.register(&command{name: name})
+	}
+	registry.register(&command{name: "profile", aliases: []string{"профиль"}})
+
+	tests := []struct {
+		text     string
+		wantName string // пусто - не команда для этого бота
+		wantArgs string
+	}{
+		{"/start", "start", ""},
+		{"/START", "start", ""},
+		{"/history clear", "history", "clear"},
+		{"/history   clear  ", "history", "clear"},
+		{"/compare 4607001771234 4600000000015", "compare", "4607001771234 4600000000015"},
+		{"/profile\nallergens глютен", "profile", "allergens глютен"},
+		{"/профиль diet веган", "profile", "diet веган"},
+		{"/start@" + testutil.BotUsername, "start", ""},
+		{"/history@Test_Bot clear", "history", "clear"},
+		{"/start@other_bot", "", ""},
+		{"/history@other_bot clear", "", ""},
+		{"/unknown", "", ""},
+		{"/", "", ""},
+		{"start", "", ""},
+		{"4607001771234", "", ""},
+		{"", "", ""},
+	}
+	for _, tt := range tests {
+		cmd, args, ok := registry.parse(tt.text, testutil.BotUsername)
+		if tt.wantName == "" {
+			if ok {
+				t.Errorf("parse(%q) = /%s, ожидалось не команда", tt.text, cmd.name)
+			}
+			continue
+		}
+		if !ok || cmd.name != tt.wantName || args != tt.wantArgs {
+			t.Errorf("parse(%q) = %v, %q, %v; ожидалось /%s %q", tt.text, cmd, args, ok, tt.wantName, tt.wantArgs)
+		}
+	}
+}
+
+func TestCommandRegisterDuplicate(t *testing.T) {
+	registry := newCommandRegistry()
+	registry.register(&command{name: "history", aliases: []string{"история"}})
+	defer func() {
+		if recover() == nil {
+			t.Error("повторное имя команды не вызвало панику")
+		}
+	}()
+	// Алиас совпадает с уже занятым именем
+	registry.register(&command{name: "journal", aliases: []string{"история"}})
+}
+
+func TestCommandMenu(t *testing.T) {
+	tb := newTestBot(t, Options{})
+
+	var names []string
+	for _, cmd := range tb.commands.menu() {
+		names = append(names, cmd.Command)
+		if cmd.Description == "" {
+			t.Errorf("у /%s в меню нет описания", cmd.Command)
+		}
+	}
+	// /start скрыта, /reports служебная, а /alternatives и /label без своих сервисов не регистрируются
+	if want := []string{"help", "history", "compare", "shelf", "profile"}; !slices.Equal(names, want) {
+		t.Errorf("меню %q, ожидалось %q", names, want)
+	}
+
+	calls := tb.tg.Calls("setMyCommands")
+	if len(calls) != 1 {
+		t.Fatalf("setMyCommands вызван %d раз", len(calls))
+	}
+}
+
+func TestMessageRequestKind(t *testing.T) {
+	tb := newTestBot(t, Options{})
+	tests := []struct {
+		text  string
+		photo bool
+		want  string
+	}{
+		{"/history", false, "/history"},
+		{"/история", false, "/history"},
+		{"/start@other_bot", false, "text"},
+		{testBarcode, false, "barcode"},
+		{"  " + testBarcode + " ", false, "barcode"},
+		{"", true, "photo"},
+		{"привет", false, "text"},
+	}
+	for _, tt := range tests {
+		message := testMessage(tt.text)
+		if tt.photo {
+			message.Photo = []tgbotapi.PhotoSize{{FileID: "photo"}}
+		}
+		if req := tb.newMessageRequest(message); req.kind != tt.want {
+			t.Errorf("%q: вид запроса %q, ожидался %q", tt.text, req.kind, tt.want)
+		}
+	}
+	if req := newCallbackRequest(&tgbotapi.CallbackQuery{From: &tgbotapi.User{ID: 7}}); req.kind != "callback" || req.userID != 7 || req.chatID != 0 {
+		t.Errorf("запрос кнопки без сообщения %+v", req)
+	}
+}
@@ -0,0 +1,392 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ajeanett/telbot/internal/barcode"
+	"github.com/ajeanett/telbot/internal/models"
+	"github.com/ajeanett/telbot/internal/services"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Действия кнопок выбора продуктов из истории для /compare:
+// отметить продукт (аргумент - штрих-код) и сравнить отмеченные
+const (
+	actionCompareToggle = "ct"
+	actionCompareRun    = "cr"
+)
+
+const (
+	// Больше четырех столбцов не помещаются в ширину экрана телефона
+	maxCompareProducts = 4
+	// Сколько последних продуктов из истории предлагается для сравнения
+	comparePickerSize = 10
+	// Ширина подписи строки и столбца в таблице сравнения
+	compareLabelWidth  = 12
+	compareColumnWidth = 6
+)
+
+func (b *Bot) registerCompareCallbacks() {
+	b.callbacks.handle(actionCompareToggle, b.onCompareToggle)
+	b.callbacks.handle(actionCompareRun, b.onCompareRun)
+}
+
+// handleCompare сравнивает продукты по штрих-кодам из аргументов,
+// а без аргументов предлагает выбрать продукты из истории
+func (b *Bot) handleCompare(ctx context.Context, req *request) {
+	fields := strings.FieldsFunc(req.args, func(r rune) bool {
+		return r == ',' || r == ';' || r == ' ' || r == '\n'
+	})
+	if len(fields) == 0 {
+		b.sendComparePicker(ctx, req.chatID, req.userID)
+		return
+	}
+
+	var codes []string
+	for _, field := range fields {
+		code, err := barcode.Normalize(field)
+		if err != nil {
+			b.api.Send(tgbotapi.NewMessage(req.chatID, fmt.Sprintf("❌ «%s» - некорректный штрих-код: %v", field, err)))
+			return
+		}
+		if lookup := code.LookupCode(); !slices.Contains(codes, lookup) {
+			codes = append(codes, lookup)
+		}
+	}
+	switch {
+	case len(codes) < 2:
+		b.api.Send(tgbotapi.NewMessage(req.chatID, "Для сравнения нужно хотя бы два разных штрих-кода, например:\n/compare 4600000000015 5449000000996"))
+	case len(codes) > maxCompareProducts:
+		b.api.Send(tgbotapi.NewMessage(req.chatID, fmt.Sprintf("Можно сравнить не больше %d продуктов за раз.", maxCompareProducts)))
+	default:
+		b.compareProducts(ctx, req.chatID, req.userID, codes)
+	}
+}
+
+// compareProducts анализирует продукты одновременно и присылает таблицу сравнения
+func (b *Bot) compareProducts(ctx context.Context, chatID, userID int64, codes []string) {
+	ctx, cancel := context.WithTimeout(ctx, lookupTimeout)
+	defer cancel()
+
+	products := make([]scannedProduct, len(codes))
+	var wg sync.WaitGroup
+	for i, code := range codes {
+		products[i].barcode = code
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p := &products[i]
+			p.result, p.err = b.analyzeBarcode(ctx, userID, p.barcode)
+			if p.err != nil {
+				log.Printf("Ошибка поиска продукта %s: %v", p.barcode, p.err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	var results []*models.AnalysisResult
+	var missing []string
+	for _, p := range products {
+		switch {
+		case p.err == nil:
+			results = append(results, p.result)
+		case errors.Is(p.err, services.ErrProductNotFound):
+			missing = append(missing, fmt.Sprintf("`%s` - нет в базе", p.barcode))
+		default:
+			missing = append(missing, fmt.Sprintf("`%s` - не удалось загрузить", p.barcode))
+		}
+	}
+
+	var text string
+	if len(results) < 2 {
+		text = "❌ Для сравнения не хватает продуктов:\n" + strings.Join(missing, "\n")
+	} else {
+		text = formatComparison(results)
+		if len(missing) > 0 {
+			text += "\nНе вошли в сравнение:\n" + strings.Join(missing, "\n") + "\n"
+		}
+	}
+	msg := tgbotapi.NewMessage(chatID, limitMessage(text))
+	msg.ParseMode = "Markdown"
+	b.api.Send(msg)
+}
+
+// sendComparePicker предлагает отметить продукты из истории чата
+func (b *Bot) sendComparePicker(ctx context.Context, chatID, userID int64) {
+	b.pendingMu.Lock()
+	delete(b.compareSelection, userID)
+	b.pendingMu.Unlock()
+
+	markup, err := b.comparePickerKeyboard(ctx, chatID, nil)
+	if err != nil {
+		log.Printf("Ошибка чтения истории чата %d: %v", chatID, err)
+		b.sendError(chatID, "Не удалось загрузить историю. Попробуйте позже.")
+		return
+	}
+	if markup == nil {
+		b.api.Send(tgbotapi.NewMessage(chatID, "🕘 История пуста. Проверьте несколько продуктов или укажите штрих-коды:\n/compare 4600000000015 5449000000996"))
+		return
+	}
+
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf(
+		"⚖️ Отметьте от 2 до %d продуктов из истории и нажмите «Сравнить».\nМожно и указать штрих-коды: /compare 4600000000015 5449000000996",
+		maxCompareProducts))
+	msg.ReplyMarkup = *markup
+	b.api.Send(msg)
+}
+
+// comparePickerKeyboard - последние проверенные продукты с отметками и кнопка
+// сравнения; nil, если история пуста
+func (b *Bot) comparePickerKeyboard(ctx context.Context, chatID int64, selected []string) (*tgbotapi.InlineKeyboardMarkup, error) {
+	// Один продукт мог проверяться несколько раз, поэтому записей читается с запасом
+	scans, err := b.store.Scans().ListByChat(ctx, chatID, 0, comparePickerSize*3)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	var seen []string
+	for _, scan := range scans {
+		if slices.Contains(seen, scan.Barcode) || !barcode.Valid(scan.Barcode) {
+			continue
+		}
+		seen = append(seen, scan.Barcode)
+		mark := "⬜"
+		if slices.Contains(selected, scan.Barcode) {
+			mark = "☑️"
+		}
+		label := fmt.Sprintf("%s %s %s", mark, verdictIcon(scan.Verdict), truncate(scan.ProductName, multiNameLimit))
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(label, b.callbacks.data(actionCompareToggle, scan.Barcode)),
+		))
+		if len(seen) == comparePickerSize {
+			break
+		}
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("⚖️ Сравнить (%d)", len(selected)), b.callbacks.data(actionCompareRun)),
+	))
+	markup := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	return &markup, nil
+}
+
+// onCompareToggle отмечает продукт или снимает отметку
+func (b *Bot) onCompareToggle(ctx context.Context, query *tgbotapi.CallbackQuery, args []string) {
+	if query.Message == nil || len(args) != 1 {
+		b.answerCallback(query, "")
+		return
+	}
+	userID := query.From.ID
+	code := args[0]
+
+	b.pendingMu.Lock()
+	selected := b.compareSelection[userID]
+	i := slices.Index(selected, code)
+	full := i < 0 && len(selected) >= maxCompareProducts
+	switch {
+	case i >= 0:
+		selected = slices.Delete(selected, i, i+1)
+	case !full:
+		selected = append(selected, code)
+	}
+	b.compareSelection[userID] = selected
+	selected = slices.Clone(selected)
+	b.pendingMu.Unlock()
+
+	if full {
+		b.answerCallback(query, fmt.Sprintf("Можно выбрать не больше %d продуктов", maxCompareProducts))
+		return
+	}
+	b.answerCallback(query, "")
+
+	chatID := query.Message.Chat.ID
+	markup, err := b.comparePickerKeyboard(ctx, chatID, selected)
+	if err != nil || markup == nil {
+		log.Printf("Ошибка чтения истории чата %d: %v", chatID, err)
+		return
+	}
+	b.api.Send(tgbotapi.NewEditMessageReplyMarkup(chatID, query.Message.MessageID, *markup))
+}
+
+// onCompareRun сравнивает отмеченные продукты
+func (b *Bot) onCompareRun(ctx context.Context, query *tgbotapi.CallbackQuery, args []string) {
+	if query.Message == nil {
+		b.answerCallback(query, "")
+		return
+	}
+	userID := query.From.ID
+
+	b.pendingMu.Lock()
+	selected := b.compareSelection[userID]
+	if len(selected) >= 2 {
+		delete(b.compareSelection, userID)
+	}
+	b.pendingMu.Unlock()
+
+	if len(selected) < 2 {
+		b.answerCallback(query, "Отметьте хотя бы два продукта")
+		return
+	}
+	b.answerCallback(query, "")
+	b.compareProducts(ctx, query.Message.Chat.ID, userID, selected)
+}
+
+// formatComparison - таблица сравнения продуктов и лучший из них с причинами
+func formatComparison(results []*models.AnalysisResult) string {
+	var message strings.Builder
+	message.WriteString("⚖️ *Сравнение продуктов:*\n\n")
+	for i, result := range results {
+		name := markdownStripper.Replace(truncate(result.Product.DisplayName(), multiNameLimit))
+		message.WriteString(fmt.Sprintf("%d. %s %s `%s`\n", i+1, verdictIcon(result.Verdict()), name, result.Product.Barcode))
+	}
+
+	message.WriteString("```\n")
+	row := func(label string, cell func(result *models.AnalysisResult) string) {
+		line := fmt.Sprintf("%-*s", compareLabelWidth, label)
+		for _, result := range results {
+			line += fmt.Sprintf("%*s", compareColumnWidth, cell(result))
+		}
+		message.WriteString(strings.TrimRight(line, " ") + "\n")
+	}
+	row("", func(result *models.AnalysisResult) string {
+		return strconv.Itoa(slices.Index(results, result) + 1)
+	})
+	row("Оценка", func(result *models.AnalysisResult) string { return strconv.Itoa(result.Score) })
+	row("Nutri-Score", func(result *models.AnalysisResult) string { return gradeOrDash(result.NutriScore) })
+	row("NOVA", func(result *models.AnalysisResult) string { return novaOrDash(result.NovaGroup) })
+	row("Сахар, г", func(result *models.AnalysisResult) string { return nutrientOrDash(result, "sugars") })
+	row("Соль, г", func(result *models.AnalysisResult) string { return nutrientOrDash(result, "salt") })
+	row("Жиры, г", func(result *models.AnalysisResult) string { return nutrientOrDash(result, "fat") })
+	row("Опасные", func(result *models.AnalysisResult) string { return strconv.Itoa(len(result.Dangerous)) })
+	row("Сомнит.", func(result *models.AnalysisResult) string { return strconv.Itoa(len(result.Warnings)) })
+	row("Аллергены", func(result *models.AnalysisResult) string { return strconv.Itoa(len(declaredAllergens(result))) })
+	message.WriteString("```\n")
+
+	for i, result := range results {
+		if names := declaredAllergens(result); len(names) > 0 {
+			message.WriteString(fmt.Sprintf("🥜 %d: %s\n", i+1, strings.Join(names, ", ")))
+		}
+		if len(result.Unsuitable) > 0 {
+			message.WriteString(fmt.Sprintf("🙅 %d не подходит вам: %s\n", i+1, markdownStripper.Replace(strings.Join(result.Unsuitable, "; "))))
+		}
+	}
+	message.WriteString("\n")
+
+	ranked := slices.Clone(results)
+	slices.SortStableFunc(ranked, func(x, y *models.AnalysisResult) int {
+		if ux, uy := len(x.Unsuitable) > 0, len(y.Unsuitable) > 0; ux != uy {
+			if ux {
+				return 1
+			}
+			return -1
+		}
+		return y.Score - x.Score
+	})
+	best, runnerUp := ranked[0], ranked[1]
+	if best.Score == runnerUp.Score && len(best.Unsuitable) > 0 == (len(runnerUp.Unsuitable) > 0) {
+		message.WriteString("🤝 Явного лидера нет: у лучших продуктов одинаковая оценка.\n")
+		return message.String()
+	}
+
+	message.WriteString(fmt.Sprintf("🏆 *Лучший выбор: %d. %s* (оценка %d из 100)\n",
+		slices.Index(results, best)+1,
+		markdownStripper.Replace(truncate(best.Product.DisplayName(), multiNameLimit)), best.Score))
+	if reasons := comparisonReasons(best, runnerUp); len(reasons) > 0 {
+		message.WriteString(fmt.Sprintf("По сравнению с продуктом %d:\n", slices.Index(results, runnerUp)+1))
+		for _, reason := range reasons {
+			message.WriteString("• " + reason + "\n")
+		}
+	}
+	return message.String()
+}
+
+// comparisonReasons - чем продукт лучше другого
+func comparisonReasons(best, other *models.AnalysisResult) []string {
+	var reasons []string
+	if len(best.Unsuitable) == 0 && len(other.Unsuitable) > 0 {
+		reasons = append(reasons, "подходит вам по профилю")
+	}
+	if len(best.Dangerous) < len(other.Dangerous) {
+		reasons = append(reasons, fmt.Sprintf("меньше опасных добавок: %d против %d", len(best.Dangerous), len(other.Dangerous)))
+	}
+	if len(best.Warnings) < len(other.Warnings) {
+		reasons = append(reasons, fmt.Sprintf("меньше сомнительных добавок: %d против %d", len(best.Warnings), len(other.Warnings)))
+	}
+	if best.NutriScore != "" && other.NutriScore != "" && best.NutriScore < other.NutriScore {
+		reasons = append(reasons, fmt.Sprintf("Nutri-Score %s против %s", gradeOrDash(best.NutriScore), gradeOrDash(other.NutriScore)))
+	}
+	if best.NovaGroup != 0 && other.NovaGroup != 0 && best.NovaGroup < other.NovaGroup {
+		reasons = append(reasons, fmt.Sprintf("меньше обработан: NOVA %d против %d", best.NovaGroup, other.NovaGroup))
+	}
+	for _, nutrient := range []struct{ key, name string }{{"sugars", "сахара"}, {"salt", "соли"}, {"fat", "жиров"}} {
+		x, okX := nutrientValue(best, nutrient.key)
+		y, okY := nutrientValue(other, nutrient.key)
+		// Разница в пределах погрешности этикетки не считается
+		if okX && okY && x < y*0.8 && y-x >= 0.1 {
+			reasons = append(reasons, fmt.Sprintf("меньше %s: %s г против %s г", nutrient.name, formatGrams(x), formatGrams(y)))
+		}
+	}
+	if x, y := len(declaredAllergens(best)), len(declaredAllergens(other)); x < y {
+		reasons = append(reasons, fmt.Sprintf("меньше аллергенов: %d против %d", x, y))
+	}
+	return reasons
+}
+
+// declaredAllergens - аллергены, заявленные в составе продукта
+func declaredAllergens(result *models.AnalysisResult) []string {
+	var names []string
+	for _, f := range result.FindingsIn(models.FindingAllergens) {
+		if allergen, ok := services.AllergenByTag(f.Code); ok {
+			names = append(names, strings.ToLower(allergen.Name))
+		}
+	}
+	return names
+}
+
+func nutrientValue(result *models.AnalysisResult, key string) (float64, bool) {
+	for _, n := range result.Nutrition {
+		if n.Key == key {
+			return n.Per100g, true
+		}
+	}
+	return 0, false
+}
+
+func nutrientOrDash(result *models.AnalysisResult, key string) string {
+	value, ok := nutrientValue(result, key)
+	if !ok {
+		return "-"
+	}
+	return formatGrams(value)
+}
+
+// formatGrams - количество на 100 г с одним знаком после запятой
+func formatGrams(value float64) string {
+	return strings.Replace(strconv.FormatFloat(value, 'f', 1, 64), ".", ",", 1)
+}
+
+func gradeOrDash(grade string) string {
+	if grade == "" {
+		return "-"
+	}
+	return strings.ToUpper(grade)
+}
+
+func novaOrDash(group int) string {
+	if group == 0 {
+		return "-"
+	}
+	return strconv.Itoa(group)
+}
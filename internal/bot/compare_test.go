@@ -0,0 +1,70 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/ajeanett/telbot/internal/models"
+)
+
+func TestLimitMessage(t *testing.T) {
+	line := strings.Repeat("я", 99) + "\n" // 100 символов
+	tests := []struct {
+		name string
+		text string
+	}{
+		{"обычный текст", strings.Repeat(line, 50)},
+		{"обрыв внутри блока", "Таблица:\n```\n" + strings.Repeat(line, 50) + "```\n"},
+		{"блок закрыт до обрыва", "```\n" + line + "```\n" + strings.Repeat(line, 50)},
+		{"обрыв сразу после открытия блока", strings.Repeat(line, 40) + strings.Repeat("x", 86) + "\n```\n" + strings.Repeat(line, 10) + "```\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := limitMessage(tt.text)
+			if n := utf8.RuneCountInString(got); n > messageLimit {
+				t.Errorf("после обрезки %d символов, лимит %d", n, messageLimit)
+			}
+			if !strings.HasSuffix(got, "\n…") {
+				t.Errorf("обрезано не по границе строки: ...%q", got[len(got)-20:])
+			}
+			if strings.Count(got, "```")%2 != 0 {
+				t.Errorf("блок ``` не закрыт: ...%q", got[len(got)-40:])
+			}
+			if strings.Contains(got, "```\n```") {
+				t.Error("после обрезки остался пустой блок ```")
+			}
+		})
+	}
+
+	short := "```\nкороткий\n```\n"
+	if got := limitMessage(short); got != short {
+		t.Errorf("короткий текст изменен: %q", got)
+	}
+}
+
+func TestFormatComparisonLongMessage(t *testing.T) {
+	// Длинные списки исключений из профиля у каждого из четырех продуктов
+	var results []*models.AnalysisResult
+	for i := range maxCompareProducts {
+		product := testProduct
+		product.Barcode = strings.Repeat(string(rune('1'+i)), 13)
+		var unsuitable []string
+		for range 40 {
+			unsuitable = append(unsuitable, "содержит ингредиент из вашего списка исключений")
+		}
+		results = append(results, &models.AnalysisResult{Product: &product, Score: 50 + i, Unsuitable: unsuitable})
+	}
+
+	text := formatComparison(results)
+	if utf8.RuneCountInString(text) <= messageLimit {
+		t.Fatalf("сравнение %d символов, тест должен превышать лимит", utf8.RuneCountInString(text))
+	}
+	got := limitMessage(text)
+	if n := utf8.RuneCountInString(got); n > messageLimit {
+		t.Errorf("после обрезки %d символов", n)
+	}
+	if strings.Count(got, "```") != 2 || !strings.Contains(got, "Оценка") {
+		t.Errorf("таблица сравнения повреждена обрезкой:\n%s", got[:min(len(got), 600)])
+	}
+}
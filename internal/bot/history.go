@@ -0,0 +1,206 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ajeanett/telbot/internal/models"
+	"github.com/ajeanett/telbot/internal/storage"
+	"log"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	historyPageSize = 5
+	// Длина названия продукта на кнопке истории
+	historyNameLimit = 40
+
+	// Действия кнопок: страница истории, повтор анализа, номер страницы
+	actionHistoryPage = "hp"
+	actionHistoryOpen = "ho"
+	actionHistoryNoop = "hn"
+)
+
+func (b *Bot) registerHistoryCallbacks() {
+	b.callbacks.handle(actionHistoryPage, b.onHistoryPage)
+	b.callbacks.handle(actionHistoryOpen, b.onHistoryOpen)
+	b.callbacks.handle(actionHistoryNoop, func(ctx context.Context, query *tgbotapi.CallbackQuery, args []string) {
+		b.answerCallback(query, "")
+	})
+}
+
+// recordScan сохраняет успешную проверку в историю чата
+func (b *Bot) recordScan(ctx context.Context, chatID, userID int64, result *models.AnalysisResult) {
+	scan := &models.Scan{
+		UserID:      userID,
+		ChatID:      chatID,
+		Barcode:     result.Product.Barcode,
+		ProductName: result.Product.DisplayName(),
+		Verdict:     result.Verdict(),
+	}
+	if err := b.store.Scans().Add(ctx, scan); err != nil {
+		log.Printf("Ошибка сохранения истории чата %d: %v", chatID, err)
+	}
+}
+
+// handleHistory обрабатывает /history и /history clear
+func (b *Bot) handleHistory(ctx context.Context, message *tgbotapi.Message, args string) {
+	chatID := message.Chat.ID
+
+	switch strings.ToLower(strings.TrimSpace(args)) {
+	case "":
+		text, markup, err := b.historyPage(ctx, chatID, 0)
+		if err != nil {
+			log.Printf("Ошибка чтения истории чата %d: %v", chatID, err)
+			b.sendError(chatID, "Не удалось загрузить историю. Попробуйте позже.")
+			return
+		}
+		msg := tgbotapi.NewMessage(chatID, text)
+		if markup != nil {
+			msg.ReplyMarkup = *markup
+		}
+		b.api.Send(msg)
+	case "clear", "очистить":
+		if err := b.store.Scans().DeleteByChat(ctx, chatID); err != nil {
+			log.Printf("Ошибка удаления истории чата %d: %v", chatID, err)
+			b.sendError(chatID, "Не удалось очистить историю. Попробуйте позже.")
+			return
+		}
+		b.api.Send(tgbotapi.NewMessage(chatID, "🗑 История проверок очищена"))
+	default:
+		b.api.Send(tgbotapi.NewMessage(chatID, "🕘 /history - ранее проверенные продукты\n🗑 /history clear - очистить историю"))
+	}
+}
+
+// historyPage строит текст и клавиатуру страницы истории; page считается с нуля
+func (b *Bot) historyPage(ctx context.Context, chatID int64, page int) (string, *tgbotapi.InlineKeyboardMarkup, error) {
+	total, err := b.store.Scans().CountByChat(ctx, chatID)
+	if err != nil {
+		return "", nil, err
+	}
+	if total == 0 {
+		return "🕘 История пуста. Отправьте штрих-код или его фото, и проверенные продукты появятся здесь.", nil, nil
+	}
+
+	pages := (total + historyPageSize - 1) / historyPageSize
+	page = max(0, min(page, pages-1))
+
+	scans, err := b.store.Scans().ListByChat(ctx, chatID, page*historyPageSize, historyPageSize)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, scan := range scans {
+		label := fmt.Sprintf("%s %s · %s", verdictIcon(scan.Verdict),
+			truncate(scan.ProductName, historyNameLimit), scan.CreatedAt.Format("02.01 15:04"))
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(label, b.callbacks.data(actionHistoryOpen, strconv.FormatInt(scan.ID, 10))),
+		))
+	}
+
+	if pages > 1 {
+		var nav []tgbotapi.InlineKeyboardButton
+		if page > 0 {
+			nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("◀️", b.callbacks.data(actionHistoryPage, strconv.Itoa(page-1))))
+		}
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData(
+			fmt.Sprintf("%d/%d", page+1, pages), b.callbacks.data(actionHistoryNoop)))
+		if page < pages-1 {
+			nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("▶️", b.callbacks.data(actionHistoryPage, strconv.Itoa(page+1))))
+		}
+		rows = append(rows, nav)
+	}
+
+	markup := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	text := fmt.Sprintf("🕘 История проверок (%d). Нажмите на продукт, чтобы снова открыть анализ.", total)
+	return text, &markup, nil
+}
+
+// onHistoryPage листает историю в том же сообщении
+func (b *Bot) onHistoryPage(ctx context.Context, query *tgbotapi.CallbackQuery, args []string) {
+	b.answerCallback(query, "")
+	if query.Message == nil || len(args) != 1 {
+		return
+	}
+	page, err := strconv.Atoi(args[0])
+	if err != nil {
+		return
+	}
+	chatID := query.Message.Chat.ID
+
+	text, markup, err := b.historyPage(ctx, chatID, page)
+	if err != nil {
+		log.Printf("Ошибка чтения истории чата %d: %v", chatID, err)
+		return
+	}
+	var edit tgbotapi.EditMessageTextConfig
+	if markup != nil {
+		edit = tgbotapi.NewEditMessageTextAndMarkup(chatID, query.Message.MessageID, text, *markup)
+	} else {
+		edit = tgbotapi.NewEditMessageText(chatID, query.Message.MessageID, text)
+	}
+	b.api.Send(edit)
+}
+
+// onHistoryOpen повторно присылает анализ продукта из истории
+func (b *Bot) onHistoryOpen(ctx context.Context, query *tgbotapi.CallbackQuery, args []string) {
+	if query.Message == nil || len(args) != 1 {
+		b.answerCallback(query, "")
+		return
+	}
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		b.answerCallback(query, "")
+		return
+	}
+	chatID := query.Message.Chat.ID
+
+	scan, err := b.store.Scans().Get(ctx, id)
+	// Запись из чужого чата не показываем: кнопку могли переслать
+	if errors.Is(err, storage.ErrNotFound) || err == nil && scan.ChatID != chatID {
+		b.answerCallback(query, "Запись удалена из истории")
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка чтения истории чата %d: %v", chatID, err)
+		b.answerCallback(query, "Не удалось открыть запись")
+		return
+	}
+	b.answerCallback(query, "🔍 Открываю анализ...")
+
+	lookupCtx, cancel := context.WithTimeout(ctx, lookupTimeout)
+	defer cancel()
+	result, err := b.analyzeBarcode(lookupCtx, query.From.ID, scan.Barcode)
+	if err != nil {
+		log.Printf("Ошибка поиска продукта %s: %v", scan.Barcode, err)
+		b.sendLookupError(chatID, scan.Barcode, err)
+		return
+	}
+	b.sendAnalysisResult(ctx, chatID, query.From.ID, result)
+}
+
+func verdictIcon(verdict string) string {
+	switch verdict {
+	case models.VerdictHealthy:
+		return "✅"
+	case models.VerdictCaution:
+		return "⚠️"
+	case models.VerdictDangerous:
+		return "🚫"
+	default:
+		return "▫️"
+	}
+}
+
+// truncate обрезает строку до limit символов с многоточием
+func truncate(s string, limit int) string {
+	runes := []rune(s)
+	if len(runes) <= limit {
+		return s
+	}
+	return strings.TrimSpace(string(runes[:limit-1])) + "…"
+}
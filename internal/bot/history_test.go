@@ -0,0 +1,152 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ajeanett/telbot/internal/models"
+)
+
+// addScans добавляет в историю чата n проверок "Продукт 1".."Продукт n"
+func (tb *testBot) addScans(t *testing.T, chatID int64, n int) []int64 {
+	t.Helper()
+	var ids []int64
+	for i := 1; i <= n; i++ {
+		scan := &models.Scan{UserID: chatID, ChatID: chatID, Barcode: testBarcode, ProductName: fmt.Sprintf("Продукт %d", i), Verdict: models.VerdictHealthy}
+		if err := tb.store.Scans().Add(context.Background(), scan); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, scan.ID)
+	}
+	return ids
+}
+
+// historyProducts - названия продуктов на кнопках страницы истории
+func historyProducts(texts []string) []string {
+	var names []string
+	for _, text := range texts {
+		if _, rest, ok := strings.Cut(text, "Продукт "); ok {
+			number, _, _ := strings.Cut(rest, " ")
+			names = append(names, number)
+		}
+	}
+	return names
+}
+
+func TestHistoryEmpty(t *testing.T) {
+	tb := newTestBot(t, Options{})
+	tb.handle(testMessage("/history"))
+	if text := tb.lastText(t); !strings.Contains(text, "История пуста") {
+		t.Errorf("на пустую историю отправлено %q", text)
+	}
+}
+
+func TestHistoryPaging(t *testing.T) {
+	tb := newTestBot(t, Options{})
+	tb.addScans(t, testChatID, 12)
+	tb.addScans(t, testChatID+1, 3)
+
+	tb.handle(testMessage("/history"))
+	if text := tb.lastText(t); !strings.Contains(text, "История проверок (12)") {
+		t.Errorf("заголовок истории %q", text)
+	}
+
+	pages := []struct {
+		press    string
+		products []string
+		nav      []string
+	}{
+		{"", []string{"12", "11", "10", "9", "8"}, []string{"1/3", "▶️"}},
+		{"▶️", []string{"7", "6", "5", "4", "3"}, []string{"◀️", "2/3", "▶️"}},
+		{"▶️", []string{"2", "1"}, []string{"◀️", "3/3"}},
+		{"◀️", []string{"7", "6", "5", "4", "3"}, []string{"◀️", "2/3", "▶️"}},
+	}
+	for i, page := range pages {
+		if page.press != "" {
+			tb.press(t, page.press)
+		}
+		texts := tb.buttonTexts(t)
+		if got := historyProducts(texts); !slices.Equal(got, page.products) {
+			t.Errorf("страница %d: продукты %q, ожидались %q", i+1, got, page.products)
+		}
+		if nav := texts[len(texts)-len(page.nav):]; !slices.Equal(nav, page.nav) {
+			t.Errorf("страница %d: навигация %q, ожидалась %q", i+1, nav, page.nav)
+		}
+	}
+
+	// Номер страницы за пределами истории приводится к последней
+	tb.callback(tb.callbacks.data(actionHistoryPage, "99"))
+	if got := historyProducts(tb.buttonTexts(t)); !slices.Equal(got, []string{"2", "1"}) {
+		t.Errorf("страница 99: продукты %q, ожидалась последняя страница", got)
+	}
+}
+
+func TestHistorySinglePageHasNoNavigation(t *testing.T) {
+	tb := newTestBot(t, Options{})
+	tb.addScans(t, testChatID, historyPageSize)
+
+	tb.handle(testMessage("/history"))
+	texts := tb.buttonTexts(t)
+	if len(texts) != historyPageSize || len(historyProducts(texts)) != historyPageSize {
+		t.Errorf("кнопки одной страницы %q", texts)
+	}
+}
+
+func TestHistoryRecordsScans(t *testing.T) {
+	tb := newTestBot(t, Options{}, testProduct)
+	tb.handle(testMessage(testBarcode))
+
+	scans, err := tb.store.Scans().ListByChat(context.Background(), testChatID, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scans) != 1 || scans[0].Barcode != testBarcode || scans[0].ProductName != testProduct.Name || scans[0].UserID != testChatID {
+		t.Errorf("в истории %+v", scans)
+	}
+}
+
+func TestHistoryOpen(t *testing.T) {
+	tb := newTestBot(t, Options{}, testProduct)
+	own := tb.addScans(t, testChatID, 1)[0]
+	foreign := tb.addScans(t, testChatID+1, 1)[0]
+
+	// Кнопку из чужого чата могли переслать: подпись верная, но запись не этого чата
+	tb.callback(tb.callbacks.data(actionHistoryOpen, strconv.FormatInt(foreign, 10)))
+	if answer := tb.lastAnswer(t); answer != "Запись удалена из истории" {
+		t.Errorf("на чужую запись ответ %q", answer)
+	}
+	if tb.db.Requests() != 0 {
+		t.Errorf("по чужой записи бот обратился к базе %d раз", tb.db.Requests())
+	}
+
+	tb.callback(tb.callbacks.data(actionHistoryOpen, "9999"))
+	if answer := tb.lastAnswer(t); answer != "Запись удалена из истории" {
+		t.Errorf("на удаленную запись ответ %q", answer)
+	}
+
+	tb.callback(tb.callbacks.data(actionHistoryOpen, strconv.FormatInt(own, 10)))
+	if answer := tb.lastAnswer(t); !strings.Contains(answer, "Открываю анализ") {
+		t.Errorf("на свою запись ответ %q", answer)
+	}
+	tb.waitForText(t, testProduct.Name)
+}
+
+func TestHistoryClear(t *testing.T) {
+	tb := newTestBot(t, Options{})
+	tb.addScans(t, testChatID, 3)
+	tb.addScans(t, testChatID+1, 2)
+
+	tb.handle(testMessage("/history clear"))
+	if text := tb.lastText(t); !strings.Contains(text, "История проверок очищена") {
+		t.Errorf("на очистку отправлено %q", text)
+	}
+	for chatID, want := range map[int64]int{testChatID: 0, testChatID + 1: 2} {
+		if count, _ := tb.store.Scans().CountByChat(context.Background(), chatID); count != want {
+			t.Errorf("в чате %d осталось %d проверок, ожидалось %d", chatID, count, want)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ajeanett/telbot/internal/services"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Действие кнопки "проанализировать по фото состава"; аргумент - штрих-код, может быть пустым
+const actionLabel = "lo"
+
+// Короче этого распознанный состав считается мусором: блик, размытое фото
+const minCompositionLength = 10
+
+// Символы разметки, которые OCR может принять за текст и сломать Markdown
+var markdownStripper = strings.NewReplacer("*", "", "_", " ", "`", "'", "[", "(", "]", ")")
+
+func (b *Bot) registerLabelCallbacks() {
+	b.callbacks.handle(actionLabel, b.onLabelRequest)
+}
+
+// labelKeyboard - кнопка для случаев, когда продукт не нашелся по штрих-коду
+func (b *Bot) labelKeyboard(barcode string) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("📸 Проверить по фото состава", b.callbacks.data(actionLabel, barcode)),
+	))
+}
+
+// handleLabel обрабатывает /label: следующее фото будет разобрано как этикетка
+func (b *Bot) handleLabel(ctx context.Context, req *request) {
+	b.expectLabel(req.userID, "")
+	b.sendLabelHint(req.chatID)
+}
+
+func (b *Bot) onLabelRequest(ctx context.Context, query *tgbotapi.CallbackQuery, args []string) {
+	if query.Message == nil || len(args) != 1 {
+		b.answerCallback(query, "")
+		return
+	}
+	b.expectLabel(query.From.ID, args[0])
+	b.answerCallback(query, "Пришлите фото состава")
+	b.sendLabelHint(query.Message.Chat.ID)
+}
+
+func (b *Bot) sendLabelHint(chatID int64) {
+	b.api.Send(tgbotapi.NewMessage(chatID, `📸 Сфотографируйте список ингредиентов на упаковке и пришлите фото.
+
+• Состав должен быть целиком в кадре
+• Текст - четким, без бликов
+
+Любое текстовое сообщение отменит ожидание.`))
+}
+
+// expectLabel запоминает, что следующее фото пользователя - этикетка
+func (b *Bot) expectLabel(userID int64, barcode string) {
+	b.pendingMu.Lock()
+	b.pendingLabel[userID] = barcode
+	b.pendingMu.Unlock()
+}
+
+// takeLabel забирает ожидание фото этикетки; false - пользователь его не просил
+func (b *Bot) takeLabel(userID int64) (barcode string, ok bool) {
+	b.pendingMu.Lock()
+	defer b.pendingMu.Unlock()
+	barcode, ok = b.pendingLabel[userID]
+	delete(b.pendingLabel, userID)
+	return barcode, ok
+}
+
+// isLabelCaption - подпись к фото просит разобрать состав, а не искать штрих-код
+func isLabelCaption(caption string) bool {
+	caption = strings.ToLower(strings.TrimSpace(caption))
+	return caption == "состав" || caption == "label" || caption == "/label"
+}
+
+// handleLabelPhoto распознает состав на фото и анализирует его как продукт без базы
+func (b *Bot) handleLabelPhoto(ctx context.Context, message *tgbotapi.Message, barcode string) {
+	chatID := message.Chat.ID
+	userID := userIDOf(message)
+
+	b.api.Send(tgbotapi.NewMessage(chatID, "🔎 Читаю состав на фото..."))
+
+	ctx, cancel := context.WithTimeout(ctx, lookupTimeout)
+	defer cancel()
+
+	imageData, err := b.downloadImage(ctx, message.Photo[len(message.Photo)-1].FileID)
+	if err != nil {
+		log.Printf("Ошибка загрузки изображения: %v", err)
+		b.sendError(chatID, "Не удалось загрузить изображение. Попробуйте еще раз.")
+		return
+	}
+
+	text, err := b.textRecognizer.RecognizeText(ctx, imageData)
+	if errors.Is(err, services.ErrQuotaExceeded) {
+		b.sendError(chatID, "Лимит распознавания текста на этот месяц исчерпан. Введите цифры штрих-кода вручную.")
+		return
+	}
+	if err != nil && !errors.Is(err, services.ErrNoText) {
+		log.Printf("Ошибка распознавания текста: %v", err)
+		b.sendError(chatID, "Распознавание текста сейчас недоступно. Попробуйте позже.")
+		return
+	}
+
+	text = markdownStripper.Replace(text)
+	if utf8.RuneCountInString(services.ExtractComposition(text)) < minCompositionLength {
+		b.api.Send(tgbotapi.NewMessage(chatID,
+			"❌ Не удалось прочитать состав. Сфотографируйте список ингредиентов ближе и при хорошем освещении."))
+		return
+	}
+
+	result := b.analyzer.AnalyzeLabel(barcode, text)
+	b.personalize(ctx, userID, result)
+
+	msg := tgbotapi.NewMessage(chatID, b.formatAnalysis(result, viewFullComposition,
+		"_Состав распознан автоматически и может содержать ошибки._"))
+	msg.ParseMode = "Markdown"
+	b.api.Send(msg)
+}
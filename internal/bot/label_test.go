@@ -0,0 +1,164 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ajeanett/telbot/internal/services"
+	"github.com/ajeanett/telbot/internal/testutil"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const labelText = "Колбаса вареная\nСостав: свинина, вода, соль, нитрит натрия.\nПищевая ценность на 100 г: белки 12 г"
+
+// quotaRecognizer расходует квоту перед распознаванием, как VisionService
+type quotaRecognizer struct {
+	quota *services.MonthlyQuota
+	next  services.TextRecognizer
+}
+
+func (r quotaRecognizer) RecognizeText(ctx context.Context, imageData []byte) (string, error) {
+	if err := r.quota.Take(); err != nil {
+		return "", err
+	}
+	return r.next.RecognizeText(ctx, imageData)
+}
+
+// labelPhoto - фото этикетки, доступное на поддельном сервере Telegram
+func labelPhoto(tb *testBot, caption string) *tgbotapi.Message {
+	tb.tg.AddFile("label", []byte("jpeg"))
+	message := testMessage("")
+	message.Caption = caption
+	message.Photo = []tgbotapi.PhotoSize{{FileID: "label"}}
+	return message
+}
+
+func TestLabelPhoto(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		err  error
+		want string
+	}{
+		{"состав распознан", labelText, nil, "распознан автоматически"},
+		{"текста мало", "Состав: соль", nil, "Не удалось прочитать состав"},
+		{"текста нет", "", services.ErrNoText, "Не удалось прочитать состав"},
+		{"квота исчерпана", "", fmt.Errorf("vision: %w", services.ErrQuotaExceeded), "Лимит распознавания текста"},
+		{"сервис недоступен", "", errors.New("vision: unavailable"), "Распознавание текста сейчас недоступно"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recognizer := &testutil.FakeTextRecognizer{Text: tt.text, Err: tt.err}
+			tb := newTestBot(t, Options{TextRecognizer: recognizer})
+
+			tb.handle(labelPhoto(tb, "Состав"))
+
+			if got := tb.lastText(t); !strings.Contains(got, tt.want) {
+				t.Errorf("ответ %q, ожидался текст с %q", got, tt.want)
+			}
+			if recognizer.Calls() != 1 {
+				t.Errorf("распознаваний %d, ожидалось 1", recognizer.Calls())
+			}
+			if tb.db.Requests() != 0 {
+				t.Errorf("по фото этикетки бот обратился к базе %d раз", tb.db.Requests())
+			}
+		})
+	}
+}
+
+func TestLabelPhotoAnalysis(t *testing.T) {
+	tb := newTestBot(t, Options{TextRecognizer: &testutil.FakeTextRecognizer{Text: labelText}})
+	tb.handle(labelPhoto(tb, "label"))
+
+	call := tb.waitForText(t, "распознан автоматически")
+	text := call.Params.Get("text")
+	for _, want := range []string{"свинина, вода, соль, нитрит натрия", services.LabelSource} {
+		if !strings.Contains(text, want) {
+			t.Errorf("в анализе нет %q:\n%s", want, text)
+		}
+	}
+	if strings.Contains(text, "Пищевая ценность") {
+		t.Errorf("в состав попала пищевая ценность:\n%s", text)
+	}
+	if call.Params.Get("parse_mode") != "Markdown" {
+		t.Errorf("parse_mode %q, ожидался Markdown", call.Params.Get("parse_mode"))
+	}
+}
+
+func TestLabelQuotaExhausted(t *testing.T) {
+	quota, err := services.NewMonthlyQuota(1, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	recognizer := &testutil.FakeTextRecognizer{Text: labelText}
+	tb := newTestBot(t, Options{TextRecognizer: quotaRecognizer{quota: quota, next: recognizer}})
+
+	tb.handle(labelPhoto(tb, "состав"))
+	if got := tb.lastText(t); !strings.Contains(got, "распознан автоматически") {
+		t.Fatalf("первое фото: ответ %q, ожидался анализ", got)
+	}
+
+	tb.handle(labelPhoto(tb, "состав"))
+	if got := tb.lastText(t); !strings.Contains(got, "Лимит распознавания текста") {
+		t.Errorf("сверх квоты: ответ %q, ожидалось сообщение о лимите", got)
+	}
+	if recognizer.Calls() != 1 {
+		t.Errorf("распознаваний %d, сверх квоты сервис не должен вызываться", recognizer.Calls())
+	}
+}
+
+func TestLabelCommandWaitsForOnePhoto(t *testing.T) {
+	recognizer := &testutil.FakeTextRecognizer{Text: labelText}
+	tb := newTestBot(t, Options{TextRecognizer: recognizer})
+
+	tb.handle(testMessage("/label"))
+	tb.handle(labelPhoto(tb, ""))
+	if got := tb.lastText(t); !strings.Contains(got, "распознан автоматически") {
+		t.Fatalf("фото после /label: ответ %q, ожидался анализ состава", got)
+	}
+
+	// Следующее фото без подписи снова ищет штрих-код
+	tb.handle(labelPhoto(tb, ""))
+	if recognizer.Calls() != 1 {
+		t.Errorf("распознаваний %d, ожидалось 1: ожидание этикетки не сброшено", recognizer.Calls())
+	}
+	if got := tb.lastText(t); !strings.Contains(got, "Не удалось распознать штрих-код") {
+		t.Errorf("второе фото: ответ %q, ожидался поиск штрих-кода", got)
+	}
+}
+
+func TestLabelButtonAfterUnknownBarcode(t *testing.T) {
+	const unknown = "4600000000015"
+	tb := newTestBot(t, Options{TextRecognizer: &testutil.FakeTextRecognizer{Text: labelText}})
+
+	tb.handle(testMessage(unknown))
+	call := tb.waitForText(t, "нет в базе данных")
+	var markup tgbotapi.InlineKeyboardMarkup
+	if err := json.Unmarshal([]byte(call.Params.Get("reply_markup")), &markup); err != nil {
+		t.Fatalf("под ответом нет кнопки проверки по фото: %v", err)
+	}
+	data := markup.InlineKeyboard[0][0].CallbackData
+	if data == nil {
+		t.Fatal("у кнопки нет данных")
+	}
+
+	query := &tgbotapi.CallbackQuery{
+		ID:      "1",
+		From:    testMessage("").From,
+		Message: &tgbotapi.Message{MessageID: 2, Chat: &tgbotapi.Chat{ID: testChatID}},
+		Data:    *data,
+	}
+	tb.route(context.Background(), newCallbackRequest(query))
+	tb.waitForText(t, "Сфотографируйте список ингредиентов")
+
+	tb.handle(labelPhoto(tb, ""))
+	text := tb.waitForText(t, "распознан автоматически").Params.Get("text")
+	if !strings.Contains(text, unknown) {
+		t.Errorf("в анализе этикетки нет штрих-кода %s:\n%s", unknown, text)
+	}
+}
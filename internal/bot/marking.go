@@ -0,0 +1,72 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ajeanett/telbot/internal/barcode"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Формат срока годности в ответах
+const expiryLayout = "02.01.2006"
+
+// scannedCode разбирает код с фото: обычный штрих-код или код маркировки
+// ("Честный ЗНАК", GS1 QR), из которого берется GTIN и поля для пользователя
+func scannedCode(raw string) (barcode.Code, *barcode.GS1Data, error) {
+	if code, err := barcode.Normalize(raw); err == nil {
+		return code, nil, nil
+	}
+	marking, err := barcode.ParseGS1(raw)
+	if err != nil {
+		return barcode.Code{}, nil, err
+	}
+	return marking.Code(), marking, nil
+}
+
+// formatMarking - срок годности и партия из кода маркировки; пусто, если их нет
+func formatMarking(marking *barcode.GS1Data, now time.Time) string {
+	if marking == nil || marking.Expiry.IsZero() && marking.Batch == "" {
+		return ""
+	}
+
+	var message strings.Builder
+	message.WriteString("🏷️ *Данные из кода маркировки:*\n")
+	if !marking.Expiry.IsZero() {
+		if marking.Expired(now) {
+			message.WriteString(fmt.Sprintf("⛔ *Срок годности истек* %s\n", marking.Expiry.Format(expiryLayout)))
+		} else {
+			message.WriteString(fmt.Sprintf("📅 Годен до: %s\n", marking.Expiry.Format(expiryLayout)))
+		}
+	}
+	if marking.Batch != "" {
+		message.WriteString(fmt.Sprintf("📦 Партия: `%s`\n", strings.ReplaceAll(marking.Batch, "`", "'")))
+	}
+	return message.String()
+}
+
+// expiryNote - короткая отметка о сроке годности для сводки по нескольким продуктам
+func expiryNote(marking *barcode.GS1Data, now time.Time) string {
+	switch {
+	case marking == nil || marking.Expiry.IsZero():
+		return ""
+	case marking.Expired(now):
+		return " · ⛔ просрочен"
+	default:
+		return " · до " + marking.Expiry.Format(expiryLayout)
+	}
+}
+
+// sendMarking присылает срок годности и партию отдельным сообщением,
+// чтобы они не пропадали при переключении разделов анализа кнопками
+func (b *Bot) sendMarking(chatID int64, marking *barcode.GS1Data) {
+	text := formatMarking(marking, time.Now())
+	if text == "" {
+		return
+	}
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = "Markdown"
+	b.api.Send(msg)
+}
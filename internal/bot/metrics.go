@@ -0,0 +1,31 @@
+package bot
+
+import (
+	"expvar"
+	"log"
+	"strings"
+)
+
+// Метрики бота публикуются через expvar на /debug/vars служебного сервера (ADMIN_ADDR),
+// который слушает отдельно от сервера здоровья
+var (
+	metricRequests        = expvar.NewMap("bot_requests")       // число обработанных обновлений по виду
+	metricRequestMillis   = expvar.NewMap("bot_request_millis") // суммарное время обработки по виду
+	metricPanics          = expvar.NewInt("bot_panics")
+	metricRateLimited     = expvar.NewInt("bot_rate_limited")
+	metricDenied          = expvar.NewInt("bot_access_denied")
+	metricWebhookRejected = expvar.NewInt("bot_webhook_rejected")  // запросы к вебхуку с неверным секретом
+	metricQueueDepth      = expvar.NewInt("bot_queue_depth")       // обновлений в очередях воркеров сейчас
+	metricQueueWaitMillis = expvar.NewInt("bot_queue_wait_millis") // суммарное ожидание в очереди
+	metricQueueRejected   = expvar.NewInt("bot_queue_rejected")    // отклонено из-за переполнения
+)
+
+// logMetrics записывает итоговые значения метрик в лог: expvar живет
+// только в памяти и пропадает вместе с процессом
+func logMetrics() {
+	expvar.Do(func(kv expvar.KeyValue) {
+		if strings.HasPrefix(kv.Key, "bot_") {
+			log.Printf("📈 %s = %s", kv.Key, kv.Value)
+		}
+	})
+}
@@ -0,0 +1,197 @@
+package bot
+
+import (
+	"context"
+	"log"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ajeanett/telbot/internal/barcode"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"golang.org/x/time/rate"
+)
+
+// request - входящее сообщение или нажатие кнопки
+type request struct {
+	message  *tgbotapi.Message       // для сообщений
+	callback *tgbotapi.CallbackQuery // для кнопок
+	chatID   int64
+	userID   int64
+	command  *command // распознанная команда, если это она
+	args     string   // аргументы команды
+	kind     string   // для логов и метрик: /команда, barcode, photo, text, callback
+}
+
+// handlerFunc обрабатывает одно обновление
+type handlerFunc func(ctx context.Context, req *request)
+
+// middleware оборачивает обработчик общей логикой
+type middleware func(next handlerFunc) handlerFunc
+
+// chain оборачивает handler в middlewares; первый в списке - внешний
+func chain(handler handlerFunc, middlewares ...middleware) handlerFunc {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+func (b *Bot) newMessageRequest(message *tgbotapi.Message) *request {
+	req := &request{
+		message: message,
+		chatID:  message.Chat.ID,
+		userID:  userIDOf(message),
+	}
+
+	text := strings.TrimSpace(message.Text)
+	switch cmd, args, ok := b.commands.parse(text, b.username); {
+	case ok:
+		req.command, req.args, req.kind = cmd, args, "/"+cmd.name
+	case message.Photo != nil:
+		req.kind = "photo"
+	case barcode.Plausible(text):
+		req.kind = "barcode"
+	default:
+		req.kind = "text"
+	}
+	return req
+}
+
+func newCallbackRequest(query *tgbotapi.CallbackQuery) *request {
+	req := &request{
+		callback: query,
+		userID:   query.From.ID,
+		kind:     "callback",
+	}
+	if query.Message != nil {
+		req.chatID = query.Message.Chat.ID
+	}
+	return req
+}
+
+// reply отвечает пользователю коротким текстом: на кнопку - уведомлением, на сообщение - сообщением
+func (b *Bot) reply(req *request, text string) {
+	if req.callback != nil {
+		b.answerCallback(req.callback, text)
+		return
+	}
+	b.api.Send(tgbotapi.NewMessage(req.chatID, text))
+}
+
+// recoverMiddleware не дает панике в обработчике уронить бота
+func (b *Bot) recoverMiddleware(next handlerFunc) handlerFunc {
+	return func(ctx context.Context, req *request) {
+		defer func() {
+			if r := recover(); r != nil {
+				metricPanics.Add(1)
+				log.Printf("🔥 Паника при обработке %s в чате %d: %v\n%s", req.kind, req.chatID, r, debug.Stack())
+				b.reply(req, "❌ Что-то пошло не так. Попробуйте еще раз.")
+			}
+		}()
+		next(ctx, req)
+	}
+}
+
+func loggingMiddleware(next handlerFunc) handlerFunc {
+	return func(ctx context.Context, req *request) {
+		start := time.Now()
+		next(ctx, req)
+		log.Printf("%s: чат %d, пользователь %d, %v", req.kind, req.chatID, req.userID, time.Since(start).Round(time.Millisecond))
+	}
+}
+
+func metricsMiddleware(next handlerFunc) handlerFunc {
+	return func(ctx context.Context, req *request) {
+		start := time.Now()
+		next(ctx, req)
+		metricRequests.Add(req.kind, 1)
+		metricRequestMillis.Add(req.kind, time.Since(start).Milliseconds())
+	}
+}
+
+// authMiddleware пускает только разрешенных пользователей, если список задан,
+// и скрывает служебные команды от всех, кроме администраторов
+func (b *Bot) authMiddleware(next handlerFunc) handlerFunc {
+	return func(ctx context.Context, req *request) {
+		if len(b.allowedUsers) > 0 && !slices.Contains(b.allowedUsers, req.userID) && !slices.Contains(b.adminUsers, req.userID) {
+			metricDenied.Add(1)
+			b.reply(req, "⛔ Бот доступен только ограниченному кругу пользователей.")
+			return
+		}
+		if req.command != nil && req.command.adminOnly && !slices.Contains(b.adminUsers, req.userID) {
+			// Для остальных команды как будто нет
+			req.command, req.kind = nil, "text"
+		}
+		next(ctx, req)
+	}
+}
+
+// rateLimitMiddleware ограничивает частоту запросов одного пользователя
+func (b *Bot) rateLimitMiddleware(next handlerFunc) handlerFunc {
+	return func(ctx context.Context, req *request) {
+		if b.limiter != nil && !b.limiter.allow(req.userID) {
+			metricRateLimited.Add(1)
+			b.reply(req, "⏳ Слишком много запросов. Подождите немного.")
+			return
+		}
+		next(ctx, req)
+	}
+}
+
+// Лимитер пользователя удаляется, если тот молчит дольше этого времени
+const limiterIdleTTL = 10 * time.Minute
+
+// userLimiter - token bucket на каждого пользователя
+type userLimiter struct {
+	limit rate.Limit
+	burst int
+
+	mu        sync.Mutex
+	limiters  map[int64]*limiterEntry
+	lastPrune time.Time
+}
+
+type limiterEntry struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// newUserLimiter разрешает perMinute запросов в минуту и до burst подряд; 0 - без ограничений
+func newUserLimiter(perMinute, burst int) *userLimiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &userLimiter{
+		limit:     rate.Limit(float64(perMinute) / 60),
+		burst:     max(burst, 1),
+		limiters:  make(map[int64]*limiterEntry),
+		lastPrune: time.Now(),
+	}
+}
+
+func (l *userLimiter) allow(userID int64) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.lastPrune) > limiterIdleTTL {
+		for id, entry := range l.limiters {
+			if now.Sub(entry.lastSeen) > limiterIdleTTL {
+				delete(l.limiters, id)
+			}
+		}
+		l.lastPrune = now
+	}
+
+	entry, ok := l.limiters[userID]
+	if !ok {
+		entry = &limiterEntry{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.limiters[userID] = entry
+	}
+	entry.lastSeen = now
+	return entry.limiter.AllowN(now, 1)
+}
@@ -0,0 +1,172 @@
+package bot
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	adminID   = 900
+	allowedID = 500
+	strangeID = 700
+)
+
+// addPing регистрирует команду /ping, отвечающую "pong"
+func (tb *testBot) addPing() {
+	tb.commands.register(&command{name: "ping", handler: func(ctx context.Context, req *request) {
+		tb.api.Send(tgbotapi.NewMessage(req.chatID, "pong"))
+	}})
+}
+
+// send пропускает сообщение пользователя userID через всю цепочку middleware
+func (tb *testBot) send(userID int64, text string) {
+	message := testMessage(text)
+	message.From.ID = userID
+	tb.handler(context.Background(), tb.newMessageRequest(message))
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed []int64
+		userID  int64
+		want    string
+	}{
+		{"список не задан", nil, strangeID, "pong"},
+		{"разрешенный пользователь", []int64{allowedID}, allowedID, "pong"},
+		{"администратор вне списка", []int64{allowedID}, adminID, "pong"},
+		{"посторонний", []int64{allowedID}, strangeID, "⛔ Бот доступен только ограниченному кругу пользователей."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tb := newTestBot(t, Options{AllowedUsers: tt.allowed, AdminUsers: []int64{adminID}})
+			tb.addPing()
+			tb.send(tt.userID, "/ping")
+			if text := tb.lastText(t); text != tt.want {
+				t.Errorf("ответ %q, ожидался %q", text, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthMiddlewareCallback(t *testing.T) {
+	tb := newTestBot(t, Options{AllowedUsers: []int64{allowedID}})
+	query := &tgbotapi.CallbackQuery{
+		ID:      "1",
+		From:    &tgbotapi.User{ID: strangeID},
+		Message: &tgbotapi.Message{MessageID: 2, Chat: &tgbotapi.Chat{ID: testChatID}},
+		Data:    tb.callbacks.data(actionHistoryPage, "0"),
+	}
+	tb.handler(context.Background(), newCallbackRequest(query))
+	if answer := tb.lastAnswer(t); !strings.HasPrefix(answer, "⛔") {
+		t.Errorf("постороннему на кнопку ответ %q", answer)
+	}
+	if len(tb.tg.Calls("editMessageText")) != 0 {
+		t.Error("кнопка постороннего обработана")
+	}
+}
+
+func TestAdminOnlyCommands(t *testing.T) {
+	tests := []struct {
+		name   string
+		userID int64
+		want   string
+	}{
+		{"администратор", adminID, "Сообщений об ошибках нет"},
+		// Для остальных команды как будто нет: бот отвечает справкой, как на любой текст
+		{"обычный пользователь", strangeID, "*Помощь*"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tb := newTestBot(t, Options{AdminUsers: []int64{adminID}})
+			tb.send(tt.userID, "/reports")
+			if text := tb.lastText(t); !strings.Contains(text, tt.want) {
+				t.Errorf("ответ %q, ожидалось %q", text, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	const limited = "⏳ Слишком много запросов. Подождите немного."
+	tb := newTestBot(t, Options{RateLimit: 1, RateBurst: 2})
+	tb.addPing()
+
+	for i, want := range []string{"pong", "pong", limited, limited} {
+		tb.send(allowedID, "/ping")
+		if text := tb.lastText(t); text != want {
+			t.Errorf("запрос %d: ответ %q, ожидался %q", i+1, text, want)
+		}
+	}
+	// У другого пользователя свой лимит
+	tb.send(strangeID, "/ping")
+	if text := tb.lastText(t); text != "pong" {
+		t.Errorf("другому пользователю ответ %q", text)
+	}
+
+	// Нажатия кнопок считаются вместе с сообщениями, отказ приходит уведомлением
+	query := &tgbotapi.CallbackQuery{ID: "1", From: &tgbotapi.User{ID: allowedID}, Data: "x"}
+	tb.handler(context.Background(), newCallbackRequest(query))
+	if answer := tb.lastAnswer(t); answer != limited {
+		t.Errorf("на кнопку сверх лимита ответ %q", answer)
+	}
+}
+
+func TestUserLimiter(t *testing.T) {
+	if newUserLimiter(0, 5) != nil {
+		t.Error("при нулевом лимите создан лимитер")
+	}
+
+	limiter := newUserLimiter(60, 0)
+	if !limiter.allow(1) || limiter.allow(1) {
+		t.Error("burst 0 должен пропускать ровно один запрос подряд")
+	}
+
+	// Давно молчавшие пользователи удаляются при очередной проверке
+	limiter.allow(2)
+	idle := time.Now().Add(-2 * limiterIdleTTL)
+	limiter.limiters[1].lastSeen = idle
+	limiter.lastPrune = idle
+	limiter.allow(2)
+	if _, ok := limiter.limiters[1]; ok {
+		t.Error("лимитер молчащего пользователя не удален")
+	}
+	if _, ok := limiter.limiters[2]; !ok {
+		t.Error("удален лимитер активного пользователя")
+	}
+}
+
+func TestRecoverMiddleware(t *testing.T) {
+	tb := newTestBot(t, Options{})
+	tb.commands.register(&command{name: "boom", handler: func(ctx context.Context, req *request) {
+		panic("сбой обработчика")
+	}})
+
+	tb.send(testChatID, "/boom")
+	if text := tb.lastText(t); text != "❌ Что-то пошло не так. Попробуйте еще раз." {
+		t.Errorf("после паники ответ %q", text)
+	}
+}
+
+func TestChainOrder(t *testing.T) {
+	var order []string
+	wrap := func(name string) middleware {
+		return func(next handlerFunc) handlerFunc {
+			return func(ctx context.Context, req *request) {
+				order = append(order, name+">")
+				next(ctx, req)
+				order = append(order, "<"+name)
+			}
+		}
+	}
+	handler := chain(func(ctx context.Context, req *request) { order = append(order, "handler") }, wrap("a"), wrap("b"))
+	handler(context.Background(), &request{})
+	if want := []string{"a>", "b>", "handler", "<b", "<a"}; !slices.Equal(order, want) {
+		t.Errorf("порядок %q, ожидался %q", order, want)
+	}
+}
@@ -0,0 +1,171 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ajeanett/telbot/internal/barcode"
+	"github.com/ajeanett/telbot/internal/models"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Действие кнопки выбора одного из нескольких продуктов: с фото полки или
+// из подсказок к штрих-коду с опечаткой; аргумент - штрих-код
+const actionPickProduct = "po"
+
+// Длина названия продукта в сводке и на кнопке выбора
+const multiNameLimit = 32
+
+// scannedProduct - один штрих-код с фото и результат его анализа
+type scannedProduct struct {
+	code    barcode.Code
+	barcode string
+	marking *barcode.GS1Data
+	result  *models.AnalysisResult
+	err     error
+}
+
+func (b *Bot) registerMultiCallbacks() {
+	b.callbacks.handle(actionPickProduct, b.onPickProduct)
+}
+
+// handleShelf обрабатывает /shelf: на следующем фото ищем все штрих-коды
+func (b *Bot) handleShelf(ctx context.Context, req *request) {
+	b.pendingMu.Lock()
+	b.pendingShelf[req.userID] = true
+	b.pendingMu.Unlock()
+	b.api.Send(tgbotapi.NewMessage(req.chatID, `🛒 Пришлите фото полки или нескольких продуктов рядом - я найду все штрих-коды.
+
+Чтобы не отправлять команду каждый раз, подпишите фото словом "полка".`))
+}
+
+// takeShelf забирает ожидание фото полки; false - пользователь его не просил
+func (b *Bot) takeShelf(userID int64) bool {
+	b.pendingMu.Lock()
+	defer b.pendingMu.Unlock()
+	shelf := b.pendingShelf[userID]
+	delete(b.pendingShelf, userID)
+	return shelf
+}
+
+// isShelfCaption - подпись к фото просит найти все продукты на нем
+func isShelfCaption(caption string) bool {
+	caption = strings.ToLower(strings.TrimSpace(caption))
+	return caption == "полка" || caption == "shelf" || caption == "/shelf"
+}
+
+// handleMultipleBarcodes ищет все продукты с фото одновременно и присылает
+// короткую сводку с вердиктами и кнопками для подробного анализа
+func (b *Bot) handleMultipleBarcodes(ctx context.Context, chatID, userID int64, codes []string) {
+	// Штрих-код и код маркировки одной упаковки дают один продукт
+	var products []scannedProduct
+	for _, raw := range codes {
+		code, marking, err := scannedCode(raw)
+		if err != nil {
+			log.Printf("Нераспознанный код на фото %q: %v", raw, err)
+			continue
+		}
+		lookup := code.LookupCode()
+		i := slices.IndexFunc(products, func(p scannedProduct) bool { return p.barcode == lookup })
+		switch {
+		case i < 0:
+			products = append(products, scannedProduct{code: code, barcode: lookup, marking: marking})
+		case products[i].marking == nil:
+			products[i].marking = marking
+		}
+	}
+	switch len(products) {
+	case 0:
+		b.sendBarcodeNotFound(chatID)
+		return
+	case 1:
+		// Один код обрабатывается так же, как на фото с одним штрих-кодом
+		b.handleProductCode(ctx, chatID, userID, products[0].code)
+		b.sendMarking(chatID, products[0].marking)
+		return
+	}
+
+	b.api.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("🔍 Штрих-кодов на фото: %d. Ищу продукты...", len(products))))
+
+	ctx, cancel := context.WithTimeout(ctx, lookupTimeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for i := range products {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p := &products[i]
+			p.result, p.err = b.analyzeBarcode(ctx, userID, p.barcode)
+			if p.err != nil {
+				log.Printf("Ошибка поиска продукта %s: %v", p.barcode, p.err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	msg := tgbotapi.NewMessage(chatID, formatMultiSummary(products))
+	msg.ParseMode = "Markdown"
+	if markup := b.pickProductKeyboard(products); len(markup.InlineKeyboard) > 0 {
+		msg.ReplyMarkup = markup
+	}
+	b.api.Send(msg)
+}
+
+// formatMultiSummary - сводка по продуктам: вердикт, название и штрих-код
+func formatMultiSummary(products []scannedProduct) string {
+	var message strings.Builder
+	message.WriteString("📋 *Продукты на фото:*\n\n")
+
+	now := time.Now()
+	var found int
+	for _, p := range products {
+		if p.err != nil {
+			message.WriteString(fmt.Sprintf("▫️ `%s` - нет в базе%s\n", p.barcode, expiryNote(p.marking, now)))
+			continue
+		}
+		found++
+		name := markdownStripper.Replace(truncate(p.result.Product.DisplayName(), multiNameLimit))
+		line := fmt.Sprintf("%s %s `%s`", verdictIcon(p.result.Verdict()), name, p.barcode)
+		if p.result.NutriScore != "" {
+			line += " · Nutri-Score " + strings.ToUpper(p.result.NutriScore)
+		}
+		message.WriteString(line + expiryNote(p.marking, now) + "\n")
+	}
+
+	message.WriteString("\n✅ полезный · ⚠️ с осторожностью · 🚫 опасный\n")
+	if found > 0 {
+		message.WriteString("Выберите продукт, чтобы увидеть подробный анализ.")
+	}
+	return message.String()
+}
+
+// pickProductKeyboard - по кнопке на каждый найденный в базе продукт
+func (b *Bot) pickProductKeyboard(products []scannedProduct) tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, p := range products {
+		if p.err != nil {
+			continue
+		}
+		label := fmt.Sprintf("%s %s", verdictIcon(p.result.Verdict()), truncate(p.result.Product.DisplayName(), multiNameLimit))
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(label, b.callbacks.data(actionPickProduct, p.barcode)),
+		))
+	}
+	return tgbotapi.InlineKeyboardMarkup{InlineKeyboard: rows}
+}
+
+func (b *Bot) onPickProduct(ctx context.Context, query *tgbotapi.CallbackQuery, args []string) {
+	if query.Message == nil || len(args) != 1 || !barcode.Valid(args[0]) {
+		b.answerCallback(query, "")
+		return
+	}
+	b.answerCallback(query, "")
+	b.handleBarcodeText(ctx, query.Message.Chat.ID, query.From.ID, args[0])
+}
@@ -0,0 +1,62 @@
+package bot
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const shelfPhoto = "4600000000015+5449000000996_shelf.jpg"
+
+func TestShelfPhoto(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "cmd", "barcodebench", "testdata", shelfPhoto))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		before   []string // сообщения перед фото
+		caption  string
+		requests int // продуктов искали в базе
+	}{
+		{"обычное фото - один код", nil, "", 1},
+		{"подпись полка", nil, "Полка", 2},
+		{"команда /shelf", []string{"/shelf"}, "", 2},
+		{"алиас /полка", []string{"/полка"}, "", 2},
+		{"текст отменяет /shelf", []string{"/shelf", "привет"}, "", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tb := newTestBot(t, Options{})
+			tb.tg.AddFile("shelf", data)
+			for _, text := range tt.before {
+				tb.handle(testMessage(text))
+			}
+
+			message := testMessage("")
+			message.Caption = tt.caption
+			message.Photo = []tgbotapi.PhotoSize{{FileID: "shelf"}}
+			tb.handle(message)
+
+			if got := tb.db.Requests(); got != tt.requests {
+				t.Errorf("запросов к базе %d, ожидалось %d", got, tt.requests)
+			}
+			multi := strings.Contains(strings.Join(tb.tg.SentTexts(testChatID), "\n"), "Штрих-кодов на фото: 2")
+			if multi != (tt.requests == 2) {
+				t.Errorf("сводка по нескольким продуктам: %v, отправлено %q", multi, tb.tg.SentTexts(testChatID))
+			}
+		})
+	}
+}
+
+func TestShelfCommandHint(t *testing.T) {
+	tb := newTestBot(t, Options{})
+	tb.handle(testMessage("/shelf"))
+	if text := tb.lastText(t); !strings.Contains(text, "Пришлите фото полки") {
+		t.Errorf("на /shelf ответ %q", text)
+	}
+}
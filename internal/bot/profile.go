@@ -0,0 +1,380 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"github.com/ajeanett/telbot/internal/models"
+	"github.com/ajeanett/telbot/internal/services"
+	"github.com/ajeanett/telbot/internal/utils"
+	"log"
+	"slices"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Сколько своих ингредиентов можно добавить в список исключений
+const maxAvoidItems = 30
+
+// Действия кнопок профиля: открыть раздел, отметить аллерген (аргумент -
+// тег без "en:"), отметить тип питания и сбросить профиль
+const (
+	actionProfileSection  = "ps"
+	actionProfileAllergen = "pa"
+	actionProfileDiet     = "pd"
+	actionProfileClear    = "pc"
+)
+
+// Разделы кнопок профиля
+const (
+	profileSectionMain      = "m"
+	profileSectionAllergens = "a"
+	profileSectionDiets     = "d"
+)
+
+// По две отметки в ряд, чтобы названия аллергенов не обрезались
+const profileButtonsPerRow = 2
+
+func (b *Bot) registerProfileCallbacks() {
+	b.callbacks.handle(actionProfileSection, b.onProfileSection)
+	b.callbacks.handle(actionProfileAllergen, b.onProfileAllergen)
+	b.callbacks.handle(actionProfileDiet, b.onProfileDiet)
+	b.callbacks.handle(actionProfileClear, b.onProfileClear)
+}
+
+// handleProfile обрабатывает /profile и его подкоманды:
+//
+//	/profile                      - показать профиль
+//	/profile allergens глютен, орехи
+//	/profile diet веган
+//	/profile avoid пальмовое масло
+//	/profile clear
+func (b *Bot) handleProfile(ctx context.Context, message *tgbotapi.Message, args string) {
+	chatID := message.Chat.ID
+	userID := userIDOf(message)
+
+	profile, err := b.store.Profiles().Get(ctx, userID)
+	if err != nil {
+		log.Printf("Ошибка чтения профиля %d: %v", userID, err)
+		b.sendError(chatID, "Не удалось загрузить профиль. Попробуйте позже.")
+		return
+	}
+
+	command, rest, _ := strings.Cut(strings.TrimSpace(args), " ")
+	rest = strings.TrimSpace(rest)
+
+	switch strings.ToLower(command) {
+	case "":
+		b.sendProfile(chatID, profile)
+		return
+	case "allergens", "аллергены":
+		tags, unknown := parseAllergens(rest)
+		if len(unknown) > 0 {
+			b.sendError(chatID, fmt.Sprintf("Не знаю аллерген: %s\n\nДоступны: %s",
+				strings.Join(unknown, ", "), allergenNames()))
+			return
+		}
+		profile.Allergens = tags
+	case "diet", "диета":
+		ids, unknown := parseDiets(rest)
+		if len(unknown) > 0 {
+			b.sendError(chatID, fmt.Sprintf("Не знаю тип питания: %s\n\nДоступны: %s",
+				strings.Join(unknown, ", "), dietNames()))
+			return
+		}
+		profile.Diets = ids
+	case "avoid", "исключить":
+		items := splitList(rest)
+		if len(items) > maxAvoidItems {
+			b.sendError(chatID, fmt.Sprintf("Слишком длинный список, максимум %d ингредиентов", maxAvoidItems))
+			return
+		}
+		profile.Avoid = items
+	case "clear", "сбросить":
+		profile = &models.UserProfile{UserID: userID}
+	default:
+		b.sendProfileHelp(chatID)
+		return
+	}
+
+	if err := b.store.Profiles().Save(ctx, profile); err != nil {
+		log.Printf("Ошибка сохранения профиля %d: %v", userID, err)
+		b.sendError(chatID, "Не удалось сохранить профиль. Попробуйте позже.")
+		return
+	}
+	b.sendProfile(chatID, profile)
+}
+
+func (b *Bot) sendProfile(chatID int64, profile *models.UserProfile) {
+	msg := tgbotapi.NewMessage(chatID, profileText(profile))
+	msg.ParseMode = "Markdown"
+	msg.ReplyMarkup = b.profileKeyboard(profile, profileSectionMain)
+	b.api.Send(msg)
+}
+
+func (b *Bot) sendProfileHelp(chatID int64) {
+	b.sendProfile(chatID, nil)
+}
+
+// profileText - описание профиля, а для пустого профиля - подсказка, как его заполнить
+func profileText(profile *models.UserProfile) string {
+	if profile.IsEmpty() {
+		return fmt.Sprintf(`👤 *Личный профиль*
+
+Укажите, что вам нельзя, и я буду предупреждать о неподходящих продуктах. Аллергены и тип питания удобно выбрать кнопками ниже.
+
+*Аллергены:*
+/profile allergens глютен, орехи
+Доступны: %s
+
+*Тип питания:*
+/profile diet веган
+Доступны: %s
+
+*Свои исключения:*
+/profile avoid пальмовое масло, сахар
+
+*Сбросить профиль:*
+/profile clear`, allergenNames(), dietNames())
+	}
+
+	var message strings.Builder
+	message.WriteString("👤 *Ваш профиль*\n\n")
+
+	if len(profile.Allergens) > 0 {
+		names := make([]string, 0, len(profile.Allergens))
+		for _, tag := range profile.Allergens {
+			if allergen, ok := services.AllergenByTag(tag); ok {
+				names = append(names, allergen.Name)
+			}
+		}
+		message.WriteString(fmt.Sprintf("🤧 *Аллергены:* %s\n", strings.Join(names, ", ")))
+	}
+	if len(profile.Diets) > 0 {
+		names := make([]string, 0, len(profile.Diets))
+		for _, id := range profile.Diets {
+			if diet, ok := services.DietByID(id); ok {
+				names = append(names, diet.Name)
+			}
+		}
+		message.WriteString(fmt.Sprintf("🥗 *Питание:* %s\n", strings.Join(names, ", ")))
+	}
+	if len(profile.Avoid) > 0 {
+		message.WriteString(fmt.Sprintf("🙅 *Избегаю:* %s\n", markdownStripper.Replace(strings.Join(profile.Avoid, ", "))))
+	}
+	message.WriteString("\nТеперь при проверке продуктов я буду учитывать ваш профиль. Изменить его - кнопками ниже или /profile help")
+	return message.String()
+}
+
+// profileKeyboard - кнопки профиля: разделы аллергенов и питания, а внутри
+// раздела - отметки, которые сразу сохраняются
+func (b *Bot) profileKeyboard(profile *models.UserProfile, section string) tgbotapi.InlineKeyboardMarkup {
+	if profile == nil {
+		profile = &models.UserProfile{}
+	}
+	mark := func(selected bool, name string) string {
+		if selected {
+			return "✅ " + name
+		}
+		return "⬜ " + name
+	}
+
+	var buttons []tgbotapi.InlineKeyboardButton
+	switch section {
+	case profileSectionAllergens:
+		for _, allergen := range services.Allergens {
+			buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData(
+				mark(slices.Contains(profile.Allergens, allergen.Tag), allergen.Name),
+				// Аргументы кнопок не могут содержать ":", поэтому префикс тега опускается
+				b.callbacks.data(actionProfileAllergen, strings.TrimPrefix(allergen.Tag, "en:"))))
+		}
+	case profileSectionDiets:
+		for _, diet := range services.Diets {
+			buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData(
+				mark(slices.Contains(profile.Diets, diet.ID), diet.Name),
+				b.callbacks.data(actionProfileDiet, diet.ID)))
+		}
+	default:
+		rows := [][]tgbotapi.InlineKeyboardButton{tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("🤧 Аллергены (%d)", len(profile.Allergens)),
+				b.callbacks.data(actionProfileSection, profileSectionAllergens)),
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("🥗 Питание (%d)", len(profile.Diets)),
+				b.callbacks.data(actionProfileSection, profileSectionDiets)),
+		)}
+		if !profile.IsEmpty() {
+			rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("🗑 Сбросить профиль", b.callbacks.data(actionProfileClear)),
+			))
+		}
+		return tgbotapi.NewInlineKeyboardMarkup(rows...)
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for chunk := range slices.Chunk(buttons, profileButtonsPerRow) {
+		rows = append(rows, chunk)
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("◀️ Готово", b.callbacks.data(actionProfileSection, profileSectionMain)),
+	))
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// onProfileSection переключает кнопки профиля на раздел из аргумента
+func (b *Bot) onProfileSection(ctx context.Context, query *tgbotapi.CallbackQuery, args []string) {
+	if query.Message == nil || len(args) != 1 {
+		b.answerCallback(query, "")
+		return
+	}
+	profile, err := b.store.Profiles().Get(ctx, query.From.ID)
+	if err != nil {
+		log.Printf("Ошибка чтения профиля %d: %v", query.From.ID, err)
+		b.answerCallback(query, "Не удалось загрузить профиль")
+		return
+	}
+	b.answerCallback(query, "")
+	b.editProfile(query, profile, args[0])
+}
+
+// onProfileAllergen отмечает аллерген в профиле нажавшего или снимает отметку
+func (b *Bot) onProfileAllergen(ctx context.Context, query *tgbotapi.CallbackQuery, args []string) {
+	if query.Message == nil || len(args) != 1 {
+		b.answerCallback(query, "")
+		return
+	}
+	allergen, ok := services.AllergenByTag("en:" + args[0])
+	if !ok {
+		b.answerCallback(query, "")
+		return
+	}
+	b.updateProfile(ctx, query, profileSectionAllergens, func(profile *models.UserProfile) {
+		profile.Allergens = toggle(profile.Allergens, allergen.Tag)
+	})
+}
+
+// onProfileDiet отмечает тип питания в профиле нажавшего или снимает отметку
+func (b *Bot) onProfileDiet(ctx context.Context, query *tgbotapi.CallbackQuery, args []string) {
+	if query.Message == nil || len(args) != 1 {
+		b.answerCallback(query, "")
+		return
+	}
+	diet, ok := services.DietByID(args[0])
+	if !ok {
+		b.answerCallback(query, "")
+		return
+	}
+	b.updateProfile(ctx, query, profileSectionDiets, func(profile *models.UserProfile) {
+		profile.Diets = toggle(profile.Diets, diet.ID)
+	})
+}
+
+func (b *Bot) onProfileClear(ctx context.Context, query *tgbotapi.CallbackQuery, args []string) {
+	if query.Message == nil {
+		b.answerCallback(query, "")
+		return
+	}
+	b.updateProfile(ctx, query, profileSectionMain, func(profile *models.UserProfile) {
+		*profile = models.UserProfile{UserID: profile.UserID}
+	})
+}
+
+// updateProfile меняет и сохраняет профиль нажавшего кнопку, а затем
+// перерисовывает сообщение. Профиль всегда свой: в группе каждый
+// участник меняет только собственные ограничения.
+func (b *Bot) updateProfile(ctx context.Context, query *tgbotapi.CallbackQuery, section string, change func(profile *models.UserProfile)) {
+	userID := query.From.ID
+	profile, err := b.store.Profiles().Get(ctx, userID)
+	if err != nil {
+		log.Printf("Ошибка чтения профиля %d: %v", userID, err)
+		b.answerCallback(query, "Не удалось загрузить профиль")
+		return
+	}
+	change(profile)
+	if err := b.store.Profiles().Save(ctx, profile); err != nil {
+		log.Printf("Ошибка сохранения профиля %d: %v", userID, err)
+		b.answerCallback(query, "Не удалось сохранить профиль")
+		return
+	}
+	b.answerCallback(query, "Профиль сохранен")
+	b.editProfile(query, profile, section)
+}
+
+func (b *Bot) editProfile(query *tgbotapi.CallbackQuery, profile *models.UserProfile, section string) {
+	chatID := query.Message.Chat.ID
+	edit := tgbotapi.NewEditMessageTextAndMarkup(chatID, query.Message.MessageID,
+		profileText(profile), b.profileKeyboard(profile, section))
+	edit.ParseMode = "Markdown"
+	if _, err := b.api.Send(edit); err != nil {
+		log.Printf("Ошибка обновления сообщения в чате %d: %v", chatID, err)
+	}
+}
+
+// toggle добавляет значение в список или убирает его оттуда
+func toggle(values []string, value string) []string {
+	if i := slices.Index(values, value); i >= 0 {
+		return slices.Delete(slices.Clone(values), i, i+1)
+	}
+	return append(values, value)
+}
+
+// parseAllergens переводит названия аллергенов в теги Open Food Facts
+func parseAllergens(input string) (tags, unknown []string) {
+	for _, item := range splitList(input) {
+		allergen, ok := services.FindAllergen(item)
+		if !ok {
+			unknown = append(unknown, item)
+			continue
+		}
+		tags = utils.AppendIfNotExists(tags, allergen.Tag)
+	}
+	return tags, unknown
+}
+
+func parseDiets(input string) (ids, unknown []string) {
+	for _, item := range splitList(input) {
+		diet, ok := services.FindDiet(item)
+		if !ok {
+			unknown = append(unknown, item)
+			continue
+		}
+		ids = utils.AppendIfNotExists(ids, diet.ID)
+	}
+	return ids, unknown
+}
+
+// splitList разбивает ввод пользователя по запятым, точкам с запятой и переводам строк
+func splitList(input string) []string {
+	fields := strings.FieldsFunc(input, func(r rune) bool {
+		return r == ',' || r == ';' || r == '\n'
+	})
+	var items []string
+	for _, field := range fields {
+		if item := strings.ToLower(strings.TrimSpace(field)); item != "" && !slices.Contains(items, item) {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func allergenNames() string {
+	names := make([]string, len(services.Allergens))
+	for i, allergen := range services.Allergens {
+		names[i] = strings.ToLower(allergen.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+func dietNames() string {
+	names := make([]string, len(services.Diets))
+	for i, diet := range services.Diets {
+		names[i] = strings.ToLower(diet.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// userIDOf возвращает автора сообщения; в каналах автора нет - берем чат
+func userIDOf(message *tgbotapi.Message) int64 {
+	if message.From != nil {
+		return message.From.ID
+	}
+	return message.Chat.ID
+}
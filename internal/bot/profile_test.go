@@ -0,0 +1,150 @@
+package bot
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ajeanett/telbot/internal/services"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestParseProfileLists(t *testing.T) {
+	tests := []struct {
+		input       string
+		wantTags    []string
+		wantUnknown []string
+	}{
+		{"", nil, nil},
+		{"глютен, орехи", []string{"en:gluten", "en:nuts"}, nil},
+		{"Молоко;молочные продукты\nяйца", []string{"en:milk", "en:eggs"}, nil},
+		{"глютен, шоколад, , Кунжут", []string{"en:gluten", "en:sesame-seeds"}, []string{"шоколад"}},
+	}
+	for _, tt := range tests {
+		tags, unknown := parseAllergens(tt.input)
+		if !slices.Equal(tags, tt.wantTags) || !slices.Equal(unknown, tt.wantUnknown) {
+			t.Errorf("parseAllergens(%q) = %q, %q; ожидалось %q, %q", tt.input, tags, unknown, tt.wantTags, tt.wantUnknown)
+		}
+	}
+
+	ids, unknown := parseDiets("веган, халяль, веганство, кето")
+	if !slices.Equal(ids, []string{services.DietVegan, services.DietHalal}) || !slices.Equal(unknown, []string{"кето"}) {
+		t.Errorf("parseDiets = %q, %q", ids, unknown)
+	}
+
+	for input, want := range map[string][]string{
+		"":       nil,
+		" , ;\n": nil,
+		"Пальмовое масло, сахар":  {"пальмовое масло", "сахар"},
+		"сахар; САХАР\nглутамат ": {"сахар", "глутамат"},
+		"соль,,  перец  ,соль":    {"соль", "перец"},
+	} {
+		if got := splitList(input); !slices.Equal(got, want) {
+			t.Errorf("splitList(%q) = %q, ожидалось %q", input, got, want)
+		}
+	}
+}
+
+func TestProfileCommand(t *testing.T) {
+	tb := newTestBot(t, Options{})
+
+	tb.handle(testMessage("/profile allergens глютен, орехи"))
+	if text := tb.lastText(t); !strings.Contains(text, "Глютен, Орехи") {
+		t.Errorf("профиль после выбора аллергенов:\n%s", text)
+	}
+	tb.handle(testMessage("/profile diet шоколадная"))
+	if text := tb.lastText(t); !strings.Contains(text, "Не знаю тип питания: шоколадная") {
+		t.Errorf("на неизвестную диету отправлено:\n%s", text)
+	}
+
+	profile, err := tb.store.Profiles().Get(context.Background(), testChatID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(profile.Allergens, []string{"en:gluten", "en:nuts"}) || len(profile.Diets) != 0 {
+		t.Errorf("сохранен профиль %+v", profile)
+	}
+
+	tb.handle(testMessage("/profile clear"))
+	if text := tb.lastText(t); !strings.Contains(text, "Личный профиль") {
+		t.Errorf("после сброса отправлено:\n%s", text)
+	}
+}
+
+func TestProfileButtons(t *testing.T) {
+	tb := newTestBot(t, Options{})
+	profiles := tb.store.Profiles()
+
+	tb.handle(testMessage("/profile"))
+	if texts := tb.buttonTexts(t); !slices.Equal(texts, []string{"🤧 Аллергены (0)", "🥗 Питание (0)"}) {
+		t.Errorf("кнопки пустого профиля %q", texts)
+	}
+
+	tb.press(t, "Аллергены")
+	texts := tb.buttonTexts(t)
+	if len(texts) != len(services.Allergens)+1 || !slices.Contains(texts, "⬜ Глютен") || !slices.Contains(texts, "⬜ Сульфиты") {
+		t.Errorf("кнопки аллергенов %q", texts)
+	}
+
+	tb.press(t, "Глютен")
+	tb.press(t, "Сульфиты")
+	tb.press(t, "Орехи")
+	tb.press(t, "Орехи")
+	if texts := tb.buttonTexts(t); !slices.Contains(texts, "✅ Глютен") || !slices.Contains(texts, "⬜ Орехи") {
+		t.Errorf("отметки аллергенов %q", texts)
+	}
+	profile, err := profiles.Get(context.Background(), testChatID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(profile.Allergens, []string{"en:gluten", "en:sulphur-dioxide-and-sulphites"}) {
+		t.Errorf("сохранены аллергены %q", profile.Allergens)
+	}
+
+	tb.press(t, "Готово")
+	tb.press(t, "Питание")
+	tb.press(t, "Халяль")
+	tb.press(t, "Готово")
+	edits := tb.tg.Calls("editMessageText")
+	if text := edits[len(edits)-1].Params.Get("text"); !strings.Contains(text, "Глютен, Сульфиты") || !strings.Contains(text, "Халяль") {
+		t.Errorf("профиль после выбора кнопками:\n%s", text)
+	}
+	if texts := tb.buttonTexts(t); !slices.Contains(texts, "🥗 Питание (1)") || !slices.Contains(texts, "🗑 Сбросить профиль") {
+		t.Errorf("кнопки заполненного профиля %q", texts)
+	}
+
+	tb.press(t, "Сбросить")
+	if profile, err := profiles.Get(context.Background(), testChatID); err != nil || !profile.IsEmpty() {
+		t.Errorf("после сброса профиль %+v, %v", profile, err)
+	}
+}
+
+func TestProfileButtonsChangeOwnProfile(t *testing.T) {
+	tb := newTestBot(t, Options{})
+	tb.handle(testMessage("/profile"))
+	tb.press(t, "Питание")
+
+	// В группе кнопку под чужим профилем нажал другой участник
+	var data string
+	for _, row := range tb.lastMarkup(t).InlineKeyboard {
+		if strings.Contains(row[0].Text, "Веганство") {
+			data = *row[0].CallbackData
+		}
+	}
+	const otherUser = testChatID + 1
+	query := &tgbotapi.CallbackQuery{
+		ID:      "2",
+		From:    &tgbotapi.User{ID: otherUser},
+		Message: &tgbotapi.Message{MessageID: 2, Chat: &tgbotapi.Chat{ID: testChatID}},
+		Data:    data,
+	}
+	tb.route(context.Background(), newCallbackRequest(query))
+
+	own, _ := tb.store.Profiles().Get(context.Background(), testChatID)
+	other, _ := tb.store.Profiles().Get(context.Background(), otherUser)
+	if !own.IsEmpty() || !slices.Equal(other.Diets, []string{services.DietVegan}) {
+		t.Errorf("профиль автора %+v, нажавшего %+v", own, other)
+	}
+}
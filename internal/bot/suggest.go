@@ -0,0 +1,70 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ajeanett/telbot/internal/barcode"
+	"github.com/ajeanett/telbot/internal/models"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// Больше подсказок не показываем: дальше пользователю проще проверить цифры
+	maxSuggestions = 3
+	// Подсказки ищутся во всех базах сразу, долго ждать их не стоит
+	suggestTimeout = 15 * time.Second
+)
+
+// suggestBarcodes отвечает на штрих-код с неверной контрольной цифрой.
+// Исправлений одной цифры столько же, сколько цифр в коде, поэтому
+// подсказываются только те, что нашлись в базе продуктов.
+func (b *Bot) suggestBarcodes(ctx context.Context, chatID int64, typed string) {
+	candidates := barcode.Suggestions(typed)
+
+	ctx, cancel := context.WithTimeout(ctx, suggestTimeout)
+	defer cancel()
+
+	// Кэш запоминает и отсутствие продукта, поэтому повторная опечатка не
+	// нагружает внешние базы
+	lookups := make([]string, len(candidates))
+	products := make([]*models.Product, len(candidates))
+	var wg sync.WaitGroup
+	for i, candidate := range candidates {
+		code, err := barcode.Normalize(candidate)
+		if err != nil {
+			continue
+		}
+		lookups[i] = code.LookupCode()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if product, err := b.barcodeService.GetProductByBarcode(ctx, lookups[i]); err == nil {
+				products[i] = product
+			}
+		}()
+	}
+	wg.Wait()
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for i, product := range products {
+		if product == nil || len(rows) == maxSuggestions {
+			continue
+		}
+		label := fmt.Sprintf("%s · %s", truncate(product.DisplayName(), multiNameLimit), lookups[i])
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(label, b.callbacks.data(actionPickProduct, lookups[i])),
+		))
+	}
+
+	if len(rows) == 0 {
+		b.sendError(chatID, fmt.Sprintf("В штрих-коде %s не сходится контрольная цифра, похоже на опечатку. Проверьте цифры на упаковке.", barcode.Clean(typed)))
+		return
+	}
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("🤔 В штрих-коде %s не сходится контрольная цифра. Возможно, вы имели в виду:", barcode.Clean(typed)))
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	b.api.Send(msg)
+}
@@ -1,37 +1,123 @@
 package bot
 
 import (
-	// "context"
+	"context"
+	"crypto/rand"
+	"errors"
 	"fmt"
+	"github.com/ajeanett/telbot/internal/barcode"
 	"github.com/ajeanett/telbot/internal/models"
 	"github.com/ajeanett/telbot/internal/services"
+	"github.com/ajeanett/telbot/internal/storage"
 	"io"
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
+// Сколько пользователь готов ждать ответа о продукте
+const lookupTimeout = time.Minute
+
 type Bot struct {
-	api             *tgbotapi.BotAPI
+	api             API
+	username        string // имя бота без @, для команд вида /help@bot
 	barcodeService  *services.BarcodeService
 	analyzer        *services.Analyzer
-	barcodeDetector *services.BarcodeDetector
+	barcodeDetector services.BarcodeDecoder
+	textRecognizer  services.TextRecognizer     // nil - разбор этикеток отключен
+	alternatives    *services.AlternativeFinder // nil - поиск альтернатив отключен
+	country         string                      // страна поиска альтернатив по умолчанию
+	store           storage.Store
 	httpClient      *http.Client
+	callbacks       *callbackRouter
+	commands        *commandRegistry
+	handler         handlerFunc // route, обернутый в middleware
+	workers         *workerPool
+	limiter         *userLimiter
+	allowedUsers    []int64
+	adminUsers      []int64
+
+	// Ожидающие действия пользователей: первый продукт для сравнения
+	// и продукт, о котором пользователь пишет сообщение об ошибке;
+	// штрих-код продукта, фото этикетки которого ждем;
+	// пользователи, приславшие /shelf перед фото полки;
+	// продукты, отмеченные в истории для /compare
+	pendingMu        sync.Mutex
+	pendingCompare   map[int64]string
+	pendingFeedback  map[int64]string
+	pendingLabel     map[int64]string
+	pendingShelf     map[int64]bool
+	compareSelection map[int64][]string
+}
+
+// Options - настройки бота помимо сервисов
+type Options struct {
+	// Ключ подписи данных кнопок; пусто - выводится из токена
+	// (в NewBotWithAPI - случайный, кнопки не переживут перезапуск)
+	CallbackSecret string
+	// Сколько запросов в минуту и подряд может прислать один пользователь; 0 - без ограничений
+	RateLimit int
+	RateBurst int
+	// Если список не пуст, ботом могут пользоваться только эти пользователи
+	AllowedUsers []int64
+	// Администраторам доступны служебные команды, например /reports
+	AdminUsers []int64
+	// Число воркеров и длина очереди каждого; при переполнении очереди
+	// пользователь получает ответ "сервер перегружен"
+	Workers   int
+	QueueSize int
+	// Распознавание текста для разбора фото этикеток; nil - функция отключена
+	TextRecognizer services.TextRecognizer
+	// Поиск продуктов лучше в той же категории; nil - функция отключена
+	Alternatives *services.AlternativeFinder
+	// Страна по умолчанию для поиска альтернатив, тег Open Food Facts
+	// (en:russia), если ее не подсказывает язык пользователя; пусто - любая
+	Country string
 }
 
 func NewBot(
 	token string,
 	barcodeService *services.BarcodeService,
 	analyzer *services.Analyzer,
-	barcodeDetector *services.BarcodeDetector,
+	barcodeDetector services.BarcodeDecoder,
+	store storage.Store,
+	opts Options,
 ) (*Bot, error) {
 	api, err := tgbotapi.NewBotAPI(token)
 	if err != nil {
 		return nil, err
 	}
+	if opts.CallbackSecret == "" {
+		opts.CallbackSecret = string(callbackSecret("", token))
+	}
+	return NewBotWithAPI(api, api.Self.UserName, barcodeService, analyzer, barcodeDetector, store, opts)
+}
+
+// NewBotWithAPI создает бота поверх готового клиента Bot API, не обращаясь
+// к Telegram при создании (кроме публикации меню команд)
+func NewBotWithAPI(
+	api API,
+	username string,
+	barcodeService *services.BarcodeService,
+	analyzer *services.Analyzer,
+	barcodeDetector services.BarcodeDecoder,
+	store storage.Store,
+	opts Options,
+) (*Bot, error) {
+	secret := []byte(opts.CallbackSecret)
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+	}
+
 	httpClient := &http.Client{
 		Timeout: 30 * time.Second, // Таймаут на запросы
 		Transport: &http.Transport{
@@ -41,84 +127,323 @@ func NewBot(
 		},
 	}
 
-	return &Bot{
-		api:             api,
-		barcodeService:  barcodeService,
-		analyzer:        analyzer,
-		barcodeDetector: barcodeDetector,
-		httpClient:      httpClient,
-	}, nil
+	b := &Bot{
+		api:              api,
+		username:         username,
+		barcodeService:   barcodeService,
+		analyzer:         analyzer,
+		barcodeDetector:  barcodeDetector,
+		textRecognizer:   opts.TextRecognizer,
+		alternatives:     opts.Alternatives,
+		country:          opts.Country,
+		store:            store,
+		httpClient:       httpClient,
+		callbacks:        newCallbackRouter(secret),
+		commands:         newCommandRegistry(),
+		limiter:          newUserLimiter(opts.RateLimit, opts.RateBurst),
+		allowedUsers:     opts.AllowedUsers,
+		adminUsers:       opts.AdminUsers,
+		pendingCompare:   make(map[int64]string),
+		pendingFeedback:  make(map[int64]string),
+		pendingLabel:     make(map[int64]string),
+		pendingShelf:     make(map[int64]bool),
+		compareSelection: make(map[int64][]string),
+	}
+	b.registerCommands()
+	b.registerHistoryCallbacks()
+	b.registerAnalysisCallbacks()
+	b.registerMultiCallbacks()
+	b.registerCompareCallbacks()
+	b.registerProfileCallbacks()
+	if b.alternatives != nil {
+		b.registerAlternativesCallbacks()
+	}
+	if b.textRecognizer != nil {
+		b.registerLabelCallbacks()
+	}
+	b.handler = chain(b.route,
+		b.recoverMiddleware,
+		loggingMiddleware,
+		metricsMiddleware,
+		b.authMiddleware,
+		b.rateLimitMiddleware,
+	)
+	b.workers = newWorkerPool(b.handler, opts.Workers, opts.QueueSize)
+
+	if err := b.publishCommands(); err != nil {
+		log.Printf("Не удалось зарегистрировать меню команд: %v", err)
+	}
+	return b, nil
 }
 
-func (b *Bot) Start() {
+// Start получает обновления через long polling, пока не отменен ctx
+func (b *Bot) Start(ctx context.Context) {
 	u := tgbotapi.NewUpdate(0)
 	u.Timeout = 60
+	u.AllowedUpdates = allowedUpdates
 
 	updates := b.api.GetUpdatesChan(u)
 
-	for update := range updates {
-		if update.Message == nil {
-			continue
+	for {
+		select {
+		case <-ctx.Done():
+			b.api.StopReceivingUpdates()
+			return
+		case update, ok := <-updates:
+			if !ok {
+				return
+			}
+			b.dispatch(update)
 		}
+	}
+}
 
-		go b.handleMessage(update.Message)
+// Shutdown перестает принимать обновления и ждет завершения начатых
+// обработчиков, но не дольше ctx
+func (b *Bot) Shutdown(ctx context.Context) error {
+	defer logMetrics()
+	if err := b.workers.stop(ctx); err != nil {
+		return fmt.Errorf("не все обновления успели обработаться: %w", err)
+	}
+	return nil
+}
+
+// updateRequest создает запрос из обновления; nil - тип обновления не обрабатывается
+func (b *Bot) updateRequest(update tgbotapi.Update) *request {
+	switch {
+	case update.Message != nil:
+		return b.newMessageRequest(update.Message)
+	case update.EditedMessage != nil:
+		// Исправленный штрих-код проверяем заново
+		return b.newMessageRequest(update.EditedMessage)
+	case update.CallbackQuery != nil:
+		return newCallbackRequest(update.CallbackQuery)
+	default:
+		return nil
 	}
 }
 
-func (b *Bot) handleMessage(message *tgbotapi.Message) {
+// dispatch ставит обновление из long polling в очередь воркеров
+func (b *Bot) dispatch(update tgbotapi.Update) {
+	req := b.updateRequest(update)
+	if req == nil {
+		return
+	}
+
+	switch err := b.workers.submit(req); {
+	case errors.Is(err, errQueueFull):
+		log.Printf("⚠️ Очередь переполнена, %s из чата %d отклонен", req.kind, req.chatID)
+		b.reply(req, "⚠️ Сервер перегружен, попробуйте через минуту.")
+	case err != nil:
+		// Бот останавливается
+		log.Printf("%s из чата %d не принят: %v", req.kind, req.chatID, err)
+	}
+}
+
+// route - конечный обработчик после всех middleware
+func (b *Bot) route(ctx context.Context, req *request) {
+	if req.callback != nil {
+		b.callbacks.dispatch(ctx, b, req.callback)
+		return
+	}
+
+	message := req.message
+	b.rememberUser(ctx, message)
+
+	labelBarcode, wantLabel := b.takeLabel(req.userID)
+	wantShelf := b.takeShelf(req.userID)
 	if message.Photo != nil {
+		if b.textRecognizer != nil && (wantLabel || isLabelCaption(message.Caption)) {
+			b.handleLabelPhoto(ctx, message, labelBarcode)
+			return
+		}
 		// Обработка фото со штрих-кодом
-		b.handleBarcodePhoto(message)
+		b.handleBarcodePhoto(ctx, message, wantShelf || isShelfCaption(message.Caption))
 		return
 	}
 
 	text := strings.TrimSpace(message.Text)
+	if b.takeFeedback(ctx, message, text) {
+		return
+	}
 
 	switch {
-	case text == "/start":
-		b.sendWelcomeMessage(message.Chat.ID)
-	case len(text) >= 8 && len(text) <= 13 && isNumeric(text):
-		// Предполагаем что это штрих-код
-		b.handleBarcodeText(message.Chat.ID, text)
+	case req.command != nil:
+		req.command.handler(ctx, req)
+	case barcode.Plausible(text):
+		b.handleTypedBarcode(ctx, message.Chat.ID, req.userID, text)
 	default:
 		b.sendHelpMessage(message.Chat.ID)
 	}
 }
 
-func (b *Bot) handleBarcodeText(chatID int64, barcode string) {
+// rememberUser сохраняет автора сообщения и время его последнего визита
+func (b *Bot) rememberUser(ctx context.Context, message *tgbotapi.Message) {
+	if message.From == nil {
+		return
+	}
+	user := &models.User{
+		ID:           message.From.ID,
+		ChatID:       message.Chat.ID,
+		Username:     message.From.UserName,
+		FirstName:    message.From.FirstName,
+		LanguageCode: message.From.LanguageCode,
+	}
+	if err := b.store.Users().Upsert(ctx, user); err != nil {
+		log.Printf("Ошибка сохранения пользователя %d: %v", user.ID, err)
+	}
+}
+
+func (b *Bot) handleBarcodeText(ctx context.Context, chatID, userID int64, barcode string) {
 	msg := tgbotapi.NewMessage(chatID, "🔍 Ищу информацию о продукте...")
 	b.api.Send(msg)
 
-	product, err := b.barcodeService.GetProductByBarcode(barcode)
+	ctx, cancel := context.WithTimeout(ctx, lookupTimeout)
+	defer cancel()
+
+	result, err := b.analyzeBarcode(ctx, userID, barcode)
 	if err != nil {
-		errorMsg := tgbotapi.NewMessage(chatID,
-			"❌ Не удалось найти продукт с таким штрих-кодом")
-		b.api.Send(errorMsg)
+		log.Printf("Ошибка поиска продукта %s: %v", barcode, err)
+		b.sendLookupError(chatID, barcode, err)
 		return
 	}
 
-	result := b.analyzer.AnalyzeProduct(product)
-	b.sendAnalysisResult(chatID, result)
+	b.recordScan(ctx, chatID, userID, result)
+	b.sendAnalysisResult(ctx, chatID, userID, result)
 }
 
-func (b *Bot) sendAnalysisResult(chatID int64, result *models.AnalysisResult) {
-	var message strings.Builder
+// handleTypedBarcode проверяет набранный вручную штрих-код. При опечатке
+// в одной цифре бот предлагает похожие коды, которые есть в базе.
+func (b *Bot) handleTypedBarcode(ctx context.Context, chatID, userID int64, text string) {
+	code, err := barcode.Normalize(text)
+	switch {
+	case errors.Is(err, barcode.ErrCheckDigit):
+		b.suggestBarcodes(ctx, chatID, text)
+	case err != nil:
+		b.sendError(chatID, "Штрих-код должен содержать 8, 12, 13 или 14 цифр.")
+	default:
+		b.handleProductCode(ctx, chatID, userID, code)
+	}
+}
+
+// handleProductCode ищет продукт по проверенному коду. Книги и журналы
+// в базах продуктов не ищутся: их там нет.
+func (b *Bot) handleProductCode(ctx context.Context, chatID, userID int64, code barcode.Code) {
+	switch code.Kind() {
+	case barcode.KindBook:
+		b.api.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf(
+			"📚 %s - это ISBN книги. Бот проверяет продукты питания, косметику и корма, книг в этих базах нет.", code)))
+	case barcode.KindPeriodical:
+		b.api.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf(
+			"📰 %s - это штрих-код журнала или газеты (ISSN %s). Бот проверяет продукты питания, косметику и корма.", code, code.ISSN())))
+	default:
+		b.handleBarcodeText(ctx, chatID, userID, code.LookupCode())
+	}
+}
+
+// analyzeBarcode находит продукт и анализирует его с учетом профиля пользователя
+func (b *Bot) analyzeBarcode(ctx context.Context, userID int64, barcode string) (*models.AnalysisResult, error) {
+	product, err := b.barcodeService.GetProductByBarcode(ctx, barcode)
+	if err != nil {
+		return nil, err
+	}
 
-	message.WriteString(fmt.Sprintf("🏷️ *%s*\n", result.Product.Name))
-	message.WriteString(fmt.Sprintf("👨‍💼 *Бренд:* %s\n", result.Product.Brand))
-	message.WriteString(fmt.Sprintf("📊 *Штрих-код:* %s\n\n", result.Product.Barcode))
+	result := b.analyzer.AnalyzeProduct(product)
+	b.personalize(ctx, userID, result)
+	return result, nil
+}
 
-	message.WriteString("*Состав:*\n")
-	if result.Product.Composition != "" {
-		message.WriteString(result.Product.Composition + "\n\n")
+// personalize дополняет анализ предупреждениями из профиля пользователя
+func (b *Bot) personalize(ctx context.Context, userID int64, result *models.AnalysisResult) {
+	if profile, err := b.store.Profiles().Get(ctx, userID); err != nil {
+		log.Printf("Ошибка чтения профиля %d: %v", userID, err)
 	} else {
-		message.WriteString("Не указан\n\n")
+		b.analyzer.Personalize(result, profile)
+	}
+}
+
+// sendLookupError объясняет пользователю, почему продукт не удалось получить
+func (b *Bot) sendLookupError(chatID int64, lookup string, err error) {
+	var text string
+	notFound := errors.Is(err, services.ErrProductNotFound)
+	code, codeErr := barcode.Normalize(lookup)
+	inStore := codeErr == nil && code.Kind() == barcode.KindInStore
+	switch {
+	case notFound && inStore:
+		text = "❌ Это внутренний штрих-код магазина или весового товара. Такие коды магазин назначает сам, в общих базах продуктов их нет."
+		if b.textRecognizer != nil {
+			text += "\n\nМожно проверить продукт по фото состава на упаковке."
+		}
+	case notFound && b.textRecognizer != nil:
+		text = "❌ Продукта с таким штрих-кодом нет в базе данных.\n\nМожно проверить его по фото состава на упаковке."
+	case notFound:
+		text = "❌ Продукта с таким штрих-кодом нет в базе данных"
+	case errors.Is(err, services.ErrRateLimited),
+		errors.Is(err, services.ErrUpstreamUnavailable),
+		errors.Is(err, context.DeadlineExceeded):
+		text = "⏳ База продуктов сейчас недоступна. Попробуйте еще раз через пару минут."
+	default:
+		text = "❌ Не удалось найти продукт с таким штрих-кодом"
+	}
+
+	msg := tgbotapi.NewMessage(chatID, text)
+	if notFound && b.textRecognizer != nil {
+		msg.ReplyMarkup = b.labelKeyboard(lookup)
+	}
+	b.api.Send(msg)
+}
+
+func (b *Bot) sendAnalysisResult(ctx context.Context, chatID, userID int64, result *models.AnalysisResult) {
+	msg := tgbotapi.NewMessage(chatID, b.formatAnalysis(result, 0, ""))
+	msg.ParseMode = "Markdown"
+	msg.ReplyMarkup = b.analysisKeyboard(ctx, userID, result, 0)
+
+	// TODO: отправлять фото если есть
+	// Если есть изображение продукта
+	// if result.Product.ImageURL != "" {
+	// 	photo := tgbotapi.NewPhoto(chatID, tgbotapi.FileURL(result.Product.ImageURL))
+	// 	photo.Caption = message.String()
+	// 	photo.ParseMode = "Markdown"
+	// 	b.api.Send(photo)
+	// } else {
+	b.api.Send(msg)
+	// }
+}
+
+// formatAnalysis собирает текст ответа с анализом. view определяет раскрытые
+// разделы, extra дописывается в конец (сравнение, просьба описать ошибку).
+func (b *Bot) formatAnalysis(result *models.AnalysisResult, view analysisView, extra string) string {
+	var head, message strings.Builder
+
+	head.WriteString(fmt.Sprintf("🏷️ *%s*\n", markdownStripper.Replace(result.Product.DisplayName())))
+	if result.Product.Brand != "" {
+		head.WriteString(fmt.Sprintf("👨‍💼 *Бренд:* %s\n", markdownStripper.Replace(result.Product.Brand)))
+	}
+	if result.Product.Quantity != "" {
+		head.WriteString(fmt.Sprintf("⚖️ *Количество:* %s\n", markdownStripper.Replace(result.Product.Quantity)))
+	}
+	// У продукта с фото этикетки штрих-кода может не быть
+	if result.Product.Barcode != "" {
+		head.WriteString(fmt.Sprintf("📊 *Штрих-код:* %s\n", result.Product.Barcode))
+	}
+	if result.Product.Source != "" {
+		head.WriteString(fmt.Sprintf("📚 *Источник:* %s\n", markdownStripper.Replace(result.Product.Source)))
+	}
+	head.WriteString("\n")
+	head.WriteString("*Состав:*\n")
+
+	if len(result.Unsuitable) > 0 {
+		message.WriteString("🙅 *НЕ ПОДХОДИТ ВАМ:*\n")
+		for _, reason := range result.Unsuitable {
+			message.WriteString(fmt.Sprintf("• %s\n", markdownStripper.Replace(reason)))
+		}
+		message.WriteString("\n")
 	}
 
 	if len(result.Dangerous) > 0 {
 		message.WriteString("🚫 *ОПАСНЫЕ ИНГРЕДИЕНТЫ:*\n")
 		for _, ingredient := range result.Dangerous {
-			message.WriteString(fmt.Sprintf("• %s\n", ingredient))
+			message.WriteString(fmt.Sprintf("• %s\n", markdownStripper.Replace(ingredient)))
 		}
 		message.WriteString("\n")
 	}
@@ -126,29 +451,84 @@ func (b *Bot) sendAnalysisResult(chatID int64, result *models.AnalysisResult) {
 	if len(result.Warnings) > 0 {
 		message.WriteString("⚠️ *СОМНИТЕЛЬНЫЕ ИНГРЕДИЕНТЫ:*\n")
 		for _, ingredient := range result.Warnings {
-			message.WriteString(fmt.Sprintf("• %s\n", ingredient))
+			message.WriteString(fmt.Sprintf("• %s\n", markdownStripper.Replace(ingredient)))
 		}
 		message.WriteString("\n")
 	}
 
+	if view&viewAdditives != 0 {
+		writeAdditiveDetails(&message, b.analyzer.MatchedRules(result.Product), result.Product.ProductType)
+	}
+
+	writeNutrition(&message, result)
+
+	message.WriteString(fmt.Sprintf("🎯 *Оценка:* %d из 100\n\n", result.Score))
+	if view&viewScore != 0 {
+		writeScoreBreakdown(&message, result)
+	}
+
 	message.WriteString("*Рекомендации:*\n")
 	for _, rec := range result.Recommendations {
-		message.WriteString(fmt.Sprintf("%s\n", rec))
+		message.WriteString(fmt.Sprintf("%s\n", markdownStripper.Replace(rec)))
 	}
 
-	msg := tgbotapi.NewMessage(chatID, message.String())
-	msg.ParseMode = "Markdown"
+	if extra != "" {
+		message.WriteString("\n" + extra)
+	}
 
-	// TODO: отправлять фото если есть
-	// Если есть изображение продукта
-	// if result.Product.ImageURL != "" {
-	// 	photo := tgbotapi.NewPhoto(chatID, tgbotapi.FileURL(result.Product.ImageURL))
-	// 	photo.Caption = message.String()
-	// 	photo.ParseMode = "Markdown"
-	// 	b.api.Send(photo)
-	// } else {
-	b.api.Send(msg)
-	// }
+	// Состав - самая длинная часть ответа. Обрезаем его, а не готовую
+	// разметку, чтобы не разорвать *...* и `...` на границе лимита.
+	composition := markdownStripper.Replace(result.Product.DisplayComposition())
+	if composition == "" {
+		composition = "Не указан"
+	}
+	limit := messageLimit - utf8.RuneCountInString(head.String()) - utf8.RuneCountInString(message.String()) - len("\n\n")
+	if view&viewFullComposition == 0 {
+		limit = min(limit, compositionPreviewLimit)
+	}
+	return limitMessage(head.String() + truncate(composition, max(limit, 1)) + "\n\n" + message.String())
+}
+
+// writeNutrition добавляет в сообщение пищевую ценность, Nutri-Score и NOVA
+func writeNutrition(message *strings.Builder, result *models.AnalysisResult) {
+	if len(result.Nutrition) > 0 {
+		message.WriteString("*Пищевая ценность на 100 г:*\n")
+		for _, n := range result.Nutrition {
+			message.WriteString(fmt.Sprintf("%s %s: %s %s\n",
+				levelIcon(n.Level), n.Name, strconv.FormatFloat(n.Per100g, 'f', -1, 64), n.Unit))
+		}
+		message.WriteString("\n")
+	}
+
+	if result.NutriScore != "" {
+		message.WriteString(fmt.Sprintf("🅰️ *Nutri-Score:* %s\n", strings.ToUpper(result.NutriScore)))
+	}
+	if result.NovaGroup != 0 {
+		message.WriteString(fmt.Sprintf("🏭 *NOVA:* %d - %s\n", result.NovaGroup, novaDescriptions[result.NovaGroup]))
+	}
+	if result.NutriScore != "" || result.NovaGroup != 0 {
+		message.WriteString("\n")
+	}
+}
+
+var novaDescriptions = map[int]string{
+	1: "необработанный или минимально обработанный",
+	2: "кулинарный ингредиент",
+	3: "обработанный продукт",
+	4: "ультраобработанный продукт",
+}
+
+func levelIcon(level models.NutrientLevel) string {
+	switch level {
+	case models.LevelLow:
+		return "🟢"
+	case models.LevelMedium:
+		return "🟡"
+	case models.LevelHigh:
+		return "🔴"
+	default:
+		return "▫️"
+	}
 }
 
 func (b *Bot) sendWelcomeMessage(chatID int64) {
@@ -162,6 +542,8 @@ func (b *Bot) sendWelcomeMessage(chatID int64) {
 
 Я проанализирую состав и выделю потенциально опасные ингредиенты.
 
+👤 Расскажите об аллергиях и диете через /profile - и я буду предупреждать, если продукт вам не подходит.
+
 🚫 *Проверяю:*
 • Пальмовое масло
 • ГМО
@@ -169,8 +551,10 @@ func (b *Bot) sendWelcomeMessage(chatID int64) {
 • Консерванты
 • Искусственные красители
 • Усилители вкуса
+• Сахар, соль и жиры (по системе «светофора»)
+• Nutri-Score и степень обработки NOVA
 
-_Данные предоставляются из открытой базы Open Food Facts_`
+_Данные предоставляются из открытых баз Open Food Facts, Open Beauty Facts и Open Pet Food Facts_`
 
 	msg := tgbotapi.NewMessage(chatID, text)
 	msg.ParseMode = "Markdown"
@@ -178,20 +562,38 @@ _Данные предоставляются из открытой базы Open
 }
 
 func (b *Bot) sendHelpMessage(chatID int64) {
+	var commands strings.Builder
+	for _, cmd := range b.commands.commands {
+		if cmd.description == "" || cmd.adminOnly {
+			continue
+		}
+		commands.WriteString("/" + cmd.name)
+		if cmd.usage != "" {
+			commands.WriteString(" " + cmd.usage)
+		}
+		commands.WriteString(" - " + cmd.description + "\n")
+	}
+
 	text := `📋 *Помощь*
 
 Просто отправьте мне:
 • 📷 Фото штрих-кода
-• 🔢 Цифры штрих-кода (8-13 цифр)
+• 🔢 Цифры штрих-кода (8, 12, 13 или 14 цифр)
 
-Я найду информацию о продукте и проанализирую его состав на наличие опасных ингредиентов.`
+Я найду информацию о продукте и проанализирую его состав на наличие опасных ингредиентов.
+
+*Команды:*
+` + commands.String()
 
 	msg := tgbotapi.NewMessage(chatID, text)
 	msg.ParseMode = "Markdown"
 	b.api.Send(msg)
 }
 
-func (b *Bot) handleBarcodePhoto(message *tgbotapi.Message) {
+// handleBarcodePhoto распознает штрих-код на фото. Все коды по фрагментам
+// фото ищутся только для полки (shelf): это в разы дольше, чем один проход
+// по целому фото, а на обычном снимке продукт один.
+func (b *Bot) handleBarcodePhoto(ctx context.Context, message *tgbotapi.Message, shelf bool) {
 	chatID := message.Chat.ID
 
 	// Отправляем сообщение о начале обработки
@@ -200,43 +602,77 @@ func (b *Bot) handleBarcodePhoto(message *tgbotapi.Message) {
 
 	// Скачиваем изображение
 	// Берем последний элемент, тк это самое качественное изображение
-	imageData, err := b.downloadImage(message.Photo[len(message.Photo)-1].FileID)
+	imageData, err := b.downloadImage(ctx, message.Photo[len(message.Photo)-1].FileID)
 	if err != nil {
 		log.Printf("Ошибка загрузки изображения: %v", err)
 		b.sendError(chatID, "Не удалось загрузить изображение. Попробуйте еще раз.")
 		return
 	}
 
-	// Проверяем что VisionService доступен
+	// Проверяем что декодер настроен
 	if b.barcodeDetector == nil {
-		log.Println("BarcodeDetector не инициализирован")
+		log.Println("BarcodeDecoder не инициализирован")
 		b.sendBarcodeDetectorError(chatID)
 		return
 	}
 
-	// Распознаем штрих-код через BarcodeDetector
-	barcode, err := b.barcodeDetector.DetectFromImage(imageData)
+	// На фото полки несколько продуктов, если декодер умеет их находить
+	if multi, ok := b.barcodeDetector.(services.MultiBarcodeDecoder); ok && shelf {
+		codes, err := multi.DetectAll(ctx, imageData)
+		if err != nil {
+			log.Printf("Ошибка распознавания штрих-кода: %v", err)
+			b.sendBarcodeNotFound(chatID)
+			return
+		}
+		log.Printf("✅ Распознаны штрих-коды: %q", codes)
+		if len(codes) > 1 {
+			b.handleMultipleBarcodes(ctx, chatID, userIDOf(message), codes)
+			return
+		}
+		b.handleScannedCode(ctx, chatID, userIDOf(message), codes[0])
+		return
+	}
+
+	// Распознаем штрих-код цепочкой декодеров
+	code, err := b.barcodeDetector.Decode(ctx, imageData)
 	if err != nil {
 		log.Printf("Ошибка распознавания штрих-кода: %v", err)
 		b.sendBarcodeNotFound(chatID)
 		return
 	}
 
-	log.Printf("✅ Распознан штрих-код: %s", barcode)
+	log.Printf("✅ Распознан штрих-код: %q", code)
 
 	// Обрабатываем найденный штрих-код
-	b.handleBarcodeText(chatID, barcode)
+	b.handleScannedCode(ctx, chatID, userIDOf(message), code)
+}
+
+// handleScannedCode ищет продукт по коду с фото; для кода маркировки
+// дополнительно присылает срок годности и партию
+func (b *Bot) handleScannedCode(ctx context.Context, chatID, userID int64, raw string) {
+	code, marking, err := scannedCode(raw)
+	if err != nil {
+		log.Printf("Нераспознанный код на фото %q: %v", raw, err)
+		b.sendBarcodeNotFound(chatID)
+		return
+	}
+	b.handleProductCode(ctx, chatID, userID, code)
+	b.sendMarking(chatID, marking)
 }
 
 // downloadImage скачивает изображение по fileID
-func (b *Bot) downloadImage(fileID string) ([]byte, error) {
+func (b *Bot) downloadImage(ctx context.Context, fileID string) ([]byte, error) {
 	fileURL, err := b.api.GetFileDirectURL(fileID)
 	if err != nil {
 		return nil, fmt.Errorf("не удалось получить URL файла: %w", err)
 	}
 
 	// Используем общий HTTP клиент
-	resp, err := b.httpClient.Get(fileURL)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fileURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := b.httpClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("не удалось скачать файл: %w", err)
 	}
@@ -249,8 +685,9 @@ func (b *Bot) downloadImage(fileID string) ([]byte, error) {
 	return io.ReadAll(resp.Body)
 }
 
-func (b *Bot) Api() *tgbotapi.BotAPI {
-	return b.api
+// Username возвращает имя бота без @
+func (b *Bot) Username() string {
+	return b.username
 }
 
 // sendBarcodeNotFound отправляет сообщение если штрих-код не найден
@@ -266,6 +703,10 @@ func (b *Bot) sendBarcodeNotFound(chatID int64) {
 Или введите цифры штрих-кода вручную.`
 
 	msg := tgbotapi.NewMessage(chatID, text)
+	if b.textRecognizer != nil {
+		// Штрих-кода на фото может и не быть, если пользователь снял состав
+		msg.ReplyMarkup = b.labelKeyboard("")
+	}
 	b.api.Send(msg)
 }
 
@@ -0,0 +1,336 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ajeanett/telbot/internal/models"
+	"github.com/ajeanett/telbot/internal/services"
+	"github.com/ajeanett/telbot/internal/storage"
+	"github.com/ajeanett/telbot/internal/testutil"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/makiuchi-d/gozxing"
+	"github.com/makiuchi-d/gozxing/oned"
+)
+
+const (
+	testChatID  = 100
+	testBarcode = "4607001771234"
+	// Сколько ждать ответа бота, обрабатывающего обновление в воркере
+	replyTimeout = 5 * time.Second
+)
+
+var testProduct = models.Product{
+	Barcode:         testBarcode,
+	Name:            "Йогурт классический",
+	CompositionRu:   "молоко нормализованное, сахар, закваска",
+	ProductType:     models.ProductTypeFood,
+	NutriScoreGrade: "b",
+	CategoriesTags:  []string{"en:dairies", "en:yogurts"},
+	CountriesTags:   []string{"en:russia"},
+}
+
+// testBot - бот, подключенный к поддельным Telegram и базе продуктов
+type testBot struct {
+	*Bot
+	tg *testutil.FakeTelegram
+	db *testutil.FakeProductDB
+}
+
+// newTestBot создает бота с базой из products. Декодер фото - gozxing,
+// как в боевой цепочке без облачных сервисов.
+func newTestBot(t *testing.T, opts Options, products ...models.Product) *testBot {
+	t.Helper()
+	db := testutil.NewFakeProductDB(products...)
+	t.Cleanup(db.Close)
+	return newTestBotWithDB(t, db, opts)
+}
+
+// newTestBotWithDB создает бота с уже запущенной базой, например чтобы
+// передать в opts поиск альтернатив по ней же
+func newTestBotWithDB(t *testing.T, db *testutil.FakeProductDB, opts Options) *testBot {
+	t.Helper()
+	tg := testutil.NewFakeTelegram()
+	t.Cleanup(tg.Close)
+
+	client, err := tg.Client("test-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	barcodeService := services.NewBarcodeService(testSource(db), services.NewMemoryCache(10), time.Minute, time.Minute)
+	decoder := services.NewCompositeDecoder(services.DecoderStep{Decoder: services.NewBarcodeDetector(), Timeout: replyTimeout})
+
+	if opts.CallbackSecret == "" {
+		opts.CallbackSecret = "test-secret"
+	}
+	b, err := NewBotWithAPI(client, testutil.BotUsername, barcodeService, services.NewAnalyzer(), decoder, storage.NewMemoryStore(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { b.Close() })
+	return &testBot{Bot: b, tg: tg, db: db}
+}
+
+func testSource(db *testutil.FakeProductDB) *services.OpenFactsSource {
+	return services.NewOpenFactsSource("Open Food Facts", models.ProductTypeFood, db.URL(), services.APIVersionV2, nil, services.RetryPolicy{MaxAttempts: 1})
+}
+
+// start запускает long polling; при завершении теста бот останавливается
+func (tb *testBot) start(t *testing.T) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		tb.Start(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), replyTimeout)
+		defer cancel()
+		if err := tb.Shutdown(shutdownCtx); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+// waitForText ждет сообщения в testChatID, содержащего substr
+func (tb *testBot) waitForText(t *testing.T, substr string) testutil.Call {
+	t.Helper()
+	deadline := time.Now().Add(replyTimeout)
+	for {
+		for _, call := range tb.tg.Calls("sendMessage") {
+			if call.ChatID() == testChatID && strings.Contains(call.Params.Get("text"), substr) {
+				return call
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("бот не прислал сообщение с %q, отправлено: %q", substr, tb.tg.SentTexts(testChatID))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// handle обрабатывает сообщение сразу, без очереди воркеров
+func (tb *testBot) handle(message *tgbotapi.Message) {
+	tb.route(context.Background(), tb.newMessageRequest(message))
+}
+
+// lastText - последнее сообщение бота в testChatID
+func (tb *testBot) lastText(t *testing.T) string {
+	t.Helper()
+	texts := tb.tg.SentTexts(testChatID)
+	if len(texts) == 0 {
+		t.Fatal("бот ничего не отправил")
+	}
+	return texts[len(texts)-1]
+}
+
+// callback обрабатывает нажатие кнопки с данными data в testChatID, без очереди воркеров
+func (tb *testBot) callback(data string) {
+	query := &tgbotapi.CallbackQuery{
+		ID:      "1",
+		From:    testMessage("").From,
+		Message: &tgbotapi.Message{MessageID: 2, Chat: &tgbotapi.Chat{ID: testChatID}},
+		Data:    data,
+	}
+	tb.route(context.Background(), newCallbackRequest(query))
+}
+
+// lastAnswer - текст последнего ответа на нажатие кнопки
+func (tb *testBot) lastAnswer(t *testing.T) string {
+	t.Helper()
+	calls := tb.tg.Calls("answerCallbackQuery")
+	if len(calls) == 0 {
+		t.Fatal("бот не ответил на нажатие кнопки")
+	}
+	return calls[len(calls)-1].Params.Get("text")
+}
+
+// lastMarkup - кнопки последнего измененного сообщения, а если изменений
+// не было - последнего отправленного
+func (tb *testBot) lastMarkup(t *testing.T) tgbotapi.InlineKeyboardMarkup {
+	t.Helper()
+	calls := tb.tg.Calls("editMessageText")
+	if len(calls) == 0 {
+		calls = tb.tg.Calls("sendMessage")
+	}
+	if len(calls) == 0 {
+		t.Fatal("бот ничего не отправил")
+	}
+	var markup tgbotapi.InlineKeyboardMarkup
+	if err := json.Unmarshal([]byte(calls[len(calls)-1].Params.Get("reply_markup")), &markup); err != nil {
+		t.Fatalf("у сообщения нет кнопок: %v", err)
+	}
+	return markup
+}
+
+// press нажимает кнопку последнего сообщения, в подписи которой есть label
+func (tb *testBot) press(t *testing.T, label string) {
+	t.Helper()
+	for _, row := range tb.lastMarkup(t).InlineKeyboard {
+		for _, button := range row {
+			if strings.Contains(button.Text, label) && button.CallbackData != nil {
+				tb.callback(*button.CallbackData)
+				return
+			}
+		}
+	}
+	t.Fatalf("нет кнопки %q", label)
+}
+
+// buttonTexts - подписи всех кнопок последнего сообщения
+func (tb *testBot) buttonTexts(t *testing.T) []string {
+	t.Helper()
+	var texts []string
+	for _, row := range tb.lastMarkup(t).InlineKeyboard {
+		for _, button := range row {
+			texts = append(texts, button.Text)
+			if button.CallbackData != nil && len(*button.CallbackData) > callbackDataLimit {
+				t.Errorf("callback_data кнопки %q длиннее %d байт", button.Text, callbackDataLimit)
+			}
+		}
+	}
+	return texts
+}
+
+func testMessage(text string) *tgbotapi.Message {
+	return &tgbotapi.Message{
+		MessageID: 1,
+		From:      &tgbotapi.User{ID: testChatID, FirstName: "Тест", LanguageCode: "ru"},
+		Chat:      &tgbotapi.Chat{ID: testChatID, Type: "private"},
+		Date:      int(time.Now().Unix()),
+		Text:      text,
+	}
+}
+
+// ean13PNG рисует штрих-код EAN-13 с полями, как на упаковке
+func ean13PNG(t *testing.T, code string) []byte {
+	t.Helper()
+	matrix, err := oned.NewEAN13Writer().Encode(code, gozxing.BarcodeFormat_EAN_13, 380, 200, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := image.NewGray(image.Rect(0, 0, matrix.GetWidth(), matrix.GetHeight()))
+	for y := 0; y < matrix.GetHeight(); y++ {
+		for x := 0; x < matrix.GetWidth(); x++ {
+			if !matrix.Get(x, y) {
+				img.SetGray(x, y, color.Gray{Y: 0xff})
+			}
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestStartCommand(t *testing.T) {
+	tb := newTestBot(t, Options{})
+	tb.start(t)
+
+	tb.tg.PushUpdate(tgbotapi.Update{Message: testMessage("/start")})
+	call := tb.waitForText(t, "Добро пожаловать")
+	if call.Params.Get("parse_mode") != "Markdown" {
+		t.Errorf("parse_mode %q, ожидался Markdown", call.Params.Get("parse_mode"))
+	}
+	if tb.db.Requests() != 0 {
+		t.Errorf("на /start бот обратился к базе продуктов %d раз", tb.db.Requests())
+	}
+}
+
+func TestTypedBarcode(t *testing.T) {
+	tb := newTestBot(t, Options{}, testProduct)
+	tb.start(t)
+
+	tb.tg.PushUpdate(tgbotapi.Update{Message: testMessage(testBarcode)})
+	call := tb.waitForText(t, testProduct.Name)
+	if call.Params.Get("parse_mode") != "Markdown" {
+		t.Errorf("parse_mode %q, ожидался Markdown", call.Params.Get("parse_mode"))
+	}
+	if call.Params.Get("reply_markup") == "" {
+		t.Error("под анализом нет кнопок")
+	}
+
+	texts := tb.tg.SentTexts(testChatID)
+	if len(texts) != 2 || !strings.Contains(texts[0], "Ищу информацию") {
+		t.Errorf("отправлено %q, ожидались сообщение о поиске и анализ", texts)
+	}
+}
+
+func TestTypedBarcodeErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"нет в базе", "4600000000015", "нет в базе данных"},
+		{"ISBN", "9785170000005", "ISBN книги"},
+		{"неверная длина", "1234567890", "8, 12, 13 или 14 цифр"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tb := newTestBot(t, Options{}, testProduct)
+			tb.handle(testMessage(tt.text))
+
+			texts := tb.tg.SentTexts(testChatID)
+			if len(texts) == 0 || !strings.Contains(texts[len(texts)-1], tt.want) {
+				t.Errorf("отправлено %q, ожидалось сообщение с %q", texts, tt.want)
+			}
+		})
+	}
+}
+
+func TestBarcodePhoto(t *testing.T) {
+	tb := newTestBot(t, Options{}, testProduct)
+	tb.start(t)
+
+	tb.tg.AddFile("photo-1", ean13PNG(t, testBarcode))
+	message := testMessage("")
+	message.Photo = []tgbotapi.PhotoSize{{FileID: "photo-1", Width: 380, Height: 200}}
+	tb.tg.PushUpdate(tgbotapi.Update{Message: message})
+
+	tb.waitForText(t, testProduct.Name)
+	texts := tb.tg.SentTexts(testChatID)
+	if !strings.Contains(texts[0], "Обрабатываю изображение") {
+		t.Errorf("первое сообщение %q, ожидалось сообщение об обработке фото", texts[0])
+	}
+	if tb.db.Requests() != 1 {
+		t.Errorf("запросов к базе %d, ожидался 1", tb.db.Requests())
+	}
+}
+
+func TestBarcodePhotoWithoutBarcode(t *testing.T) {
+	tb := newTestBot(t, Options{}, testProduct)
+
+	blank := image.NewGray(image.Rect(0, 0, 200, 100))
+	for i := range blank.Pix {
+		blank.Pix[i] = 0xff
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, blank); err != nil {
+		t.Fatal(err)
+	}
+	tb.tg.AddFile("blank", buf.Bytes())
+	message := testMessage("")
+	message.Photo = []tgbotapi.PhotoSize{{FileID: "blank"}}
+	tb.handle(message)
+
+	texts := tb.tg.SentTexts(testChatID)
+	if len(texts) == 0 || !strings.Contains(texts[len(texts)-1], "Не удалось распознать штрих-код") {
+		t.Errorf("отправлено %q, ожидалось сообщение о нераспознанном штрих-коде", texts)
+	}
+	if tb.db.Requests() != 0 {
+		t.Errorf("без штрих-кода бот обратился к базе %d раз", tb.db.Requests())
+	}
+}
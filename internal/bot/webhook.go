@@ -0,0 +1,91 @@
+package bot
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// Заголовок, в котором Telegram присылает secret_token вебхука
+	webhookSecretHeader = "X-Telegram-Bot-Api-Secret-Token"
+	// Обновления Telegram намного меньше, лимит защищает от мусорных запросов
+	webhookBodyLimit = 1 << 20
+)
+
+// Типы обновлений, которые обрабатывает бот
+var allowedUpdates = []string{"message", "edited_message", "callback_query"}
+
+// WebhookHandler принимает обновления от Telegram. Запросы без верного
+// секрета отклоняются: адрес вебхука может стать известен кому угодно.
+func (b *Bot) WebhookHandler(secret string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get(webhookSecretHeader)), []byte(secret)) != 1 {
+			metricWebhookRejected.Add(1)
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		var update tgbotapi.Update
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, webhookBodyLimit)).Decode(&update); err != nil {
+			log.Printf("Некорректное обновление от вебхука: %v", err)
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+
+		// Сначала ставим в очередь: 200 означает для Telegram, что обновление
+		// доставлено, а на ошибку он повторит его позже. Обрабатывается обновление
+		// уже после ответа - пока Telegram ждет, он не присылает следующие.
+		if req := b.updateRequest(update); req != nil {
+			if err := b.workers.submit(req); err != nil {
+				log.Printf("%s из чата %d не принят, Telegram повторит доставку: %v", req.kind, req.chatID, err)
+				http.Error(w, "service unavailable", http.StatusServiceUnavailable)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// SetWebhook регистрирует вебхук в Telegram
+func (b *Bot) SetWebhook(webhookURL, secret string) error {
+	params := tgbotapi.Params{
+		"url":          webhookURL,
+		"secret_token": secret,
+	}
+	if err := params.AddInterface("allowed_updates", allowedUpdates); err != nil {
+		return err
+	}
+	if _, err := b.api.MakeRequest("setWebhook", params); err != nil {
+		return fmt.Errorf("не удалось зарегистрировать вебхук: %w", err)
+	}
+	return nil
+}
+
+// DeleteWebhook снимает вебхук, чтобы бота снова можно было запустить через long polling
+func (b *Bot) DeleteWebhook() error {
+	if _, err := b.api.Request(tgbotapi.DeleteWebhookConfig{}); err != nil {
+		return fmt.Errorf("не удалось удалить вебхук: %w", err)
+	}
+	return nil
+}
+
+// NewWebhookSecret генерирует случайный secret_token для вебхука
+func NewWebhookSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
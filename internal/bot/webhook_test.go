@@ -0,0 +1,166 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const testWebhookSecret = "webhook-secret"
+
+func webhookRequest(t *testing.T, method, secret, body string) *http.Request {
+	t.Helper()
+	req := httptest.NewRequest(method, "/webhook", strings.NewReader(body))
+	if secret != "" {
+		req.Header.Set(webhookSecretHeader, secret)
+	}
+	return req
+}
+
+func TestWebhookHandlerRejects(t *testing.T) {
+	update, err := json.Marshal(tgbotapi.Update{UpdateID: 1, Message: testMessage("/start")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		secret   string
+		body     string
+		status   int
+		rejected int64 // на сколько растет счетчик отклоненных по секрету
+	}{
+		{"GET", http.MethodGet, testWebhookSecret, string(update), http.StatusMethodNotAllowed, 0},
+		{"без секрета", http.MethodPost, "", string(update), http.StatusForbidden, 1},
+		{"неверный секрет", http.MethodPost, "wrong", string(update), http.StatusForbidden, 1},
+		{"секрет - префикс верного", http.MethodPost, testWebhookSecret[:3], string(update), http.StatusForbidden, 1},
+		{"не JSON", http.MethodPost, testWebhookSecret, "{not json", http.StatusBadRequest, 0},
+		{"слишком большое тело", http.MethodPost, testWebhookSecret, `{"update_id":1,"x":"` + strings.Repeat("a", webhookBodyLimit) + `"}`, http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tb := newTestBot(t, Options{})
+			rejected := metricWebhookRejected.Value()
+
+			rec := httptest.NewRecorder()
+			tb.WebhookHandler(testWebhookSecret).ServeHTTP(rec, webhookRequest(t, tt.method, tt.secret, tt.body))
+
+			if rec.Code != tt.status {
+				t.Errorf("статус %d, ожидался %d", rec.Code, tt.status)
+			}
+			if tt.status == http.StatusMethodNotAllowed && rec.Header().Get("Allow") != http.MethodPost {
+				t.Errorf("Allow %q, ожидался POST", rec.Header().Get("Allow"))
+			}
+			if got := metricWebhookRejected.Value() - rejected; got != tt.rejected {
+				t.Errorf("отклонено по секрету %d, ожидалось %d", got, tt.rejected)
+			}
+			if err := tb.Shutdown(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if texts := tb.tg.SentTexts(testChatID); len(texts) != 0 {
+				t.Errorf("на отклоненный запрос бот ответил пользователю: %q", texts)
+			}
+		})
+	}
+}
+
+func TestWebhookHandlerDispatches(t *testing.T) {
+	tb := newTestBot(t, Options{}, testProduct)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), replyTimeout)
+		defer cancel()
+		if err := tb.Shutdown(ctx); err != nil {
+			t.Error(err)
+		}
+	})
+
+	body, err := json.Marshal(tgbotapi.Update{UpdateID: 1, Message: testMessage(testBarcode)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	tb.WebhookHandler(testWebhookSecret).ServeHTTP(rec, webhookRequest(t, http.MethodPost, testWebhookSecret, string(body)))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("статус %d, ожидался 200: %s", rec.Code, rec.Body)
+	}
+	// Обработчик отвечает сразу, анализ присылает воркер
+	tb.waitForText(t, testProduct.Name)
+}
+
+func TestWebhookHandlerUnavailable(t *testing.T) {
+	tb := newTestBot(t, Options{Workers: 1, QueueSize: 1})
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+	tb.commands.register(&command{name: "slow", handler: func(ctx context.Context, req *request) {
+		started <- struct{}{}
+		<-release
+	}})
+	body, err := json.Marshal(tgbotapi.Update{UpdateID: 1, Message: testMessage("/slow")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	post := func() int {
+		rec := httptest.NewRecorder()
+		tb.WebhookHandler(testWebhookSecret).ServeHTTP(rec, webhookRequest(t, http.MethodPost, testWebhookSecret, string(body)))
+		return rec.Code
+	}
+
+	if code := post(); code != http.StatusOK {
+		t.Fatalf("статус %d, ожидался 200", code)
+	}
+	<-started
+	if code := post(); code != http.StatusOK {
+		t.Fatalf("в свободную очередь статус %d, ожидался 200", code)
+	}
+	// Очередь полна: Telegram повторит доставку, пользователю ничего не пишем
+	if code := post(); code != http.StatusServiceUnavailable {
+		t.Errorf("при полной очереди статус %d, ожидался 503", code)
+	}
+	if texts := tb.tg.SentTexts(testChatID); len(texts) != 0 {
+		t.Errorf("при полной очереди бот ответил %q", texts)
+	}
+
+	close(release)
+	ctx, cancel := context.WithTimeout(context.Background(), replyTimeout)
+	defer cancel()
+	if err := tb.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if code := post(); code != http.StatusServiceUnavailable {
+		t.Errorf("после остановки статус %d, ожидался 503", code)
+	}
+}
+
+func TestShutdownDrainTimeout(t *testing.T) {
+	tb := newTestBot(t, Options{})
+	started := make(chan struct{})
+	canceled := make(chan struct{})
+	tb.commands.register(&command{name: "stuck", handler: func(ctx context.Context, req *request) {
+		close(started)
+		<-ctx.Done()
+		close(canceled)
+	}})
+	tb.dispatch(tgbotapi.Update{Message: testMessage("/stuck")})
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := tb.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown с зависшим обработчиком вернул %v", err)
+	}
+	// Не успевшему обработчику отменяют контекст, чтобы он бросил запросы к базам
+	select {
+	case <-canceled:
+	case <-time.After(replyTimeout):
+		t.Error("контекст зависшего обработчика не отменен")
+	}
+}
@@ -0,0 +1,118 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	errQueueFull   = errors.New("очередь обработки переполнена")
+	errPoolStopped = errors.New("обработка обновлений остановлена")
+)
+
+// job - обновление, ожидающее обработки
+type job struct {
+	req      *request
+	queuedAt time.Time
+}
+
+// workerPool обрабатывает обновления фиксированным числом воркеров.
+// Чат всегда попадает к одному и тому же воркеру, поэтому сообщения
+// одного чата обрабатываются по очереди и ответы не перемешиваются.
+type workerPool struct {
+	handler handlerFunc
+	shards  []chan job
+
+	// ctx передается обработчикам; отменяется, если они не успели завершиться при остановке
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu      sync.RWMutex
+	stopped bool
+}
+
+// newWorkerPool запускает workers воркеров с очередью queueSize у каждого
+func newWorkerPool(handler handlerFunc, workers, queueSize int) *workerPool {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &workerPool{
+		handler: handler,
+		shards:  make([]chan job, max(workers, 1)),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+	for i := range p.shards {
+		p.shards[i] = make(chan job, max(queueSize, 1))
+		p.wg.Add(1)
+		go p.run(p.shards[i])
+	}
+	return p
+}
+
+// submit ставит запрос в очередь его чата
+func (p *workerPool) submit(req *request) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.stopped {
+		return errPoolStopped
+	}
+
+	shard := p.shards[shardOf(req, len(p.shards))]
+	select {
+	case shard <- job{req: req, queuedAt: time.Now()}:
+		metricQueueDepth.Add(1)
+		return nil
+	default:
+		metricQueueRejected.Add(1)
+		return errQueueFull
+	}
+}
+
+func (p *workerPool) run(queue <-chan job) {
+	defer p.wg.Done()
+	for j := range queue {
+		metricQueueDepth.Add(-1)
+		metricQueueWaitMillis.Add(time.Since(j.queuedAt).Milliseconds())
+		p.handler(p.ctx, j.req)
+	}
+}
+
+// stop перестает принимать обновления и ждет, пока воркеры обработают
+// уже принятые. Если ctx истек раньше, контекст обработчиков отменяется.
+func (p *workerPool) stop(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.stopped {
+		p.stopped = true
+		for _, shard := range p.shards {
+			close(shard)
+		}
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		p.cancel()
+		return nil
+	case <-ctx.Done():
+		p.cancel()
+		return ctx.Err()
+	}
+}
+
+// shardOf выбирает воркера по чату; у кнопок в inline-сообщениях чата нет,
+// для них берется пользователь
+func shardOf(req *request, n int) int {
+	key := req.chatID
+	if key == 0 {
+		key = req.userID
+	}
+	return int(uint64(key) % uint64(n))
+}
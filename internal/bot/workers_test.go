@@ -0,0 +1,171 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// chatRequest - сообщение номер seq из чата chatID
+func chatRequest(chatID int64, seq int) *request {
+	return &request{
+		chatID:  chatID,
+		userID:  chatID,
+		message: &tgbotapi.Message{MessageID: seq, Chat: &tgbotapi.Chat{ID: chatID}},
+	}
+}
+
+// blockingHandler обрабатывает запросы, только когда закрыт release;
+// о взятом в работу запросе сообщает в started
+func blockingHandler(release <-chan struct{}) (handlerFunc, <-chan *request) {
+	started := make(chan *request, 16)
+	return func(ctx context.Context, req *request) {
+		started <- req
+		<-release
+	}, started
+}
+
+func stopPool(t *testing.T, p *workerPool) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), replyTimeout)
+	defer cancel()
+	if err := p.stop(ctx); err != nil {
+		t.Fatalf("пул не остановился: %v", err)
+	}
+}
+
+func TestWorkerPoolKeepsChatOrder(t *testing.T) {
+	const chats, perChat = 8, 30
+	var (
+		mu   sync.Mutex
+		seen = map[int64][]int{}
+	)
+	p := newWorkerPool(func(ctx context.Context, req *request) {
+		// Разная длительность обработки перемешала бы ответы без привязки чата к воркеру
+		time.Sleep(time.Duration(rand.IntN(300)) * time.Microsecond)
+		mu.Lock()
+		seen[req.chatID] = append(seen[req.chatID], req.message.MessageID)
+		mu.Unlock()
+	}, 3, chats*perChat)
+
+	for seq := range perChat {
+		for chatID := range int64(chats) {
+			if err := p.submit(chatRequest(chatID+1, seq)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	stopPool(t, p)
+
+	for chatID := range int64(chats) {
+		got := seen[chatID+1]
+		if len(got) != perChat || !slices.IsSorted(got) {
+			t.Errorf("чат %d: сообщения обработаны в порядке %v", chatID+1, got)
+		}
+	}
+}
+
+func TestShardOf(t *testing.T) {
+	tests := []struct {
+		name string
+		req  *request
+		want int
+	}{
+		{"чат", &request{chatID: 7, userID: 1}, 3},
+		{"кнопка без чата - по пользователю", &request{userID: 6}, 2},
+		{"отрицательный id группы", &request{chatID: -3}, 1},
+	}
+	for _, tt := range tests {
+		if got := shardOf(tt.req, 4); got != tt.want {
+			t.Errorf("%s: воркер %d, ожидался %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWorkerPoolQueueFull(t *testing.T) {
+	release := make(chan struct{})
+	handler, started := blockingHandler(release)
+	p := newWorkerPool(handler, 1, 1)
+	defer stopPool(t, p)
+	defer close(release)
+
+	if err := p.submit(chatRequest(1, 1)); err != nil {
+		t.Fatal(err)
+	}
+	<-started // первый запрос у воркера, очередь пуста
+	if err := p.submit(chatRequest(1, 2)); err != nil {
+		t.Fatalf("запрос в свободную очередь отклонен: %v", err)
+	}
+	for _, chatID := range []int64{1, 2} {
+		if err := p.submit(chatRequest(chatID, 3)); !errors.Is(err, errQueueFull) {
+			t.Errorf("чат %d: в полную очередь submit вернул %v", chatID, err)
+		}
+	}
+}
+
+func TestWorkerPoolStop(t *testing.T) {
+	var (
+		mu        sync.Mutex
+		processed []int
+	)
+	p := newWorkerPool(func(ctx context.Context, req *request) {
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		processed = append(processed, req.message.MessageID)
+		mu.Unlock()
+	}, 2, 10)
+	for seq := range 10 {
+		if err := p.submit(chatRequest(int64(seq%2), seq)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stopPool(t, p)
+
+	// Принятое до остановки обработано, новое не принимается
+	if len(processed) != 10 {
+		t.Errorf("до остановки обработано %d из 10 запросов", len(processed))
+	}
+	if err := p.submit(chatRequest(1, 10)); !errors.Is(err, errPoolStopped) {
+		t.Errorf("после остановки submit вернул %v", err)
+	}
+	// Повторная остановка, например из defer, не паникует на закрытых очередях
+	stopPool(t, p)
+}
+
+func TestDispatchQueueFull(t *testing.T) {
+	tb := newTestBot(t, Options{Workers: 1, QueueSize: 1})
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+	tb.commands.register(&command{name: "slow", handler: func(ctx context.Context, req *request) {
+		started <- struct{}{}
+		<-release
+	}})
+	slow := tgbotapi.Update{Message: testMessage("/slow")}
+
+	tb.dispatch(slow)
+	<-started
+	tb.dispatch(slow)
+	tb.dispatch(slow)
+	if text := tb.lastText(t); text != "⚠️ Сервер перегружен, попробуйте через минуту." {
+		t.Errorf("на переполнение очереди ответ %q", text)
+	}
+	close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), replyTimeout)
+	defer cancel()
+	if err := tb.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// После остановки обновления не обрабатываются и ответов не получают
+	sent := len(tb.tg.Calls("sendMessage"))
+	tb.dispatch(tgbotapi.Update{Message: testMessage("/help")})
+	if calls := tb.tg.Calls("sendMessage"); len(calls) != sent {
+		t.Errorf("после остановки отправлено %q", tb.tg.SentTexts(testChatID)[sent:])
+	}
+}
@@ -1,20 +1,139 @@
 package config
 
 import (
+	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Config struct {
-	TelegramToken    string
+	TelegramToken string
+	// Порт сервера здоровья и вебхука
+	Port string
+	// Публичный HTTPS-адрес вебхука, например https://bot.example.com/telegram;
+	// пусто - long polling
+	WebhookURL string
+	// secret_token вебхука; пусто - генерируется при запуске
+	WebhookSecret string
+	// Адрес служебного сервера метрик /debug/vars; пусто - метрики не отдаются
+	AdminAddr string
+
 	RedisURL         string
 	OpenFoodFactsAPI string
+	// Версия API: v2 (по умолчанию) или устаревший v0
+	OpenFoodFactsAPIVersion string
+
+	// Порядок опроса баз: off, obf, opff, local
+	ProductSources []string
+	// Локальный каталог: путь к JSON-файлу или sqlite://путь к базе
+	// с таблицей catalog_products
+	LocalCatalogPath string
+
+	// Страна для поиска альтернатив, если ее не подсказывает язык пользователя:
+	// тег Open Food Facts (en:russia); пусто - продукты из любой страны
+	Country string
+
+	// Файл или каталог с правилами анализа; пусто - встроенная база
+	RulesPath string
+	// Период проверки файлов правил на изменения; 0 - только по SIGHUP
+	RulesReloadInterval time.Duration
+
+	// JSON с порогами "светофора" пищевой ценности; пусто - пороги FSA
+	NutritionThresholdsPath string
+
+	// Хранилище пользователей, профилей и истории:
+	// sqlite://путь (по умолчанию), redis://host:6379/0 или memory
+	StorageURL string
+
+	// Ключ подписи данных inline-кнопок; пусто - выводится из токена бота
+	CallbackSecret string
+
+	// Ограничение частоты запросов одного пользователя; 0 - без ограничений
+	RateLimit int
+	RateBurst int
+	// Telegram ID пользователей с доступом к боту (пусто - все) и администраторов
+	AllowedUsers []int64
+	AdminUsers   []int64
+
+	// Разбор фото этикеток через Google Vision (нужен GOOGLE_APPLICATION_CREDENTIALS)
+	LabelOCR bool
+
+	// Цепочка распознавания штрих-кодов на фото: "gozxing", "vision",
+	// у каждого можно задать таймаут: "gozxing:5s,vision:10s"
+	BarcodeDecoders []string
+	// Таймаут декодера, если он не указан в цепочке
+	DecoderTimeout time.Duration
+	// Лимит запросов к Google Vision в месяц (0 - без лимита) и файл счетчика
+	VisionMonthlyQuota int
+	VisionQuotaPath    string
+
+	// Воркеры обработки обновлений и длина очереди каждого
+	Workers   int
+	QueueSize int
+	// Сколько ждать завершения начатых обработчиков при остановке
+	ShutdownTimeout time.Duration
+
+	// HTTP-клиент для Open Food Facts
+	UserAgent   string
+	HTTPTimeout time.Duration
+	HTTPRetries int
+
+	// Кэш продуктов: Redis если задан RedisURL, иначе LRU в памяти
+	CacheSize        int
+	CacheTTL         time.Duration
+	CacheNotFoundTTL time.Duration
 }
 
 func Load() *Config {
 	return &Config{
 		TelegramToken:    getEnv("TELEGRAM_BOT_TOKEN", ""),
-		RedisURL:         getEnv("REDIS_URL", "localhost:6379"),
-		OpenFoodFactsAPI: getEnv("OPEN_FOOD_FACTS_API", "https://world.openfoodfacts.org/api/v0"),
+		Port:             getEnv("PORT", "8080"),
+		WebhookURL:       getEnv("WEBHOOK_URL", ""),
+		WebhookSecret:    getEnv("WEBHOOK_SECRET", ""),
+		AdminAddr:        getEnv("ADMIN_ADDR", "127.0.0.1:6060"),
+		RedisURL:         getEnv("REDIS_URL", ""),
+		OpenFoodFactsAPI: apiRoot(getEnv("OPEN_FOOD_FACTS_API", "https://world.openfoodfacts.org")),
+
+		OpenFoodFactsAPIVersion: getEnv("OPEN_FOOD_FACTS_API_VERSION", "v2"),
+
+		ProductSources:   getEnvList("PRODUCT_SOURCES", []string{"off", "obf", "opff", "local"}),
+		LocalCatalogPath: getEnv("LOCAL_CATALOG_PATH", "data/catalog.json"),
+		Country:          countryTag(getEnv("COUNTRY", "en:russia")),
+
+		RulesPath:           getEnv("RULES_PATH", ""),
+		RulesReloadInterval: getEnvDuration("RULES_RELOAD_INTERVAL", 30*time.Second),
+
+		NutritionThresholdsPath: getEnv("NUTRITION_THRESHOLDS_PATH", ""),
+
+		StorageURL:     getEnv("STORAGE_URL", "sqlite://data/telbot.db"),
+		CallbackSecret: getEnv("CALLBACK_SECRET", ""),
+
+		RateLimit:    getEnvInt("RATE_LIMIT", 30),
+		RateBurst:    getEnvInt("RATE_BURST", 10),
+		AllowedUsers: getEnvIDs("ALLOWED_USERS"),
+		AdminUsers:   getEnvIDs("ADMIN_USERS"),
+
+		LabelOCR: getEnvBool("LABEL_OCR", false),
+
+		BarcodeDecoders:    getEnvList("BARCODE_DECODERS", []string{"gozxing"}),
+		DecoderTimeout:     getEnvDuration("DECODER_TIMEOUT", 10*time.Second),
+		VisionMonthlyQuota: getEnvInt("VISION_MONTHLY_QUOTA", 1000),
+		VisionQuotaPath:    getEnv("VISION_QUOTA_PATH", "data/vision_quota.json"),
+
+		Workers:   getEnvInt("WORKERS", 8),
+		QueueSize: getEnvInt("QUEUE_SIZE", 32),
+
+		ShutdownTimeout: getEnvDuration("SHUTDOWN_TIMEOUT", 20*time.Second),
+
+		UserAgent:   getEnv("USER_AGENT", "telbot/1.0 (https://t.me/insidecode_bot)"),
+		HTTPTimeout: getEnvDuration("HTTP_TIMEOUT", 10*time.Second),
+		HTTPRetries: getEnvInt("HTTP_RETRIES", 3),
+
+		CacheSize:        getEnvInt("CACHE_SIZE", 1000),
+		CacheTTL:         getEnvDuration("CACHE_TTL", 24*time.Hour),
+		CacheNotFoundTTL: getEnvDuration("CACHE_NOT_FOUND_TTL", time.Hour),
 	}
 }
 
@@ -24,3 +143,88 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// apiRoot отрезает путь /api/vN от старых значений OPEN_FOOD_FACTS_API,
+// версия API теперь задается отдельно
+func apiRoot(apiURL string) string {
+	if i := strings.Index(apiURL, "/api/"); i >= 0 {
+		return apiURL[:i]
+	}
+	return strings.TrimRight(apiURL, "/")
+}
+
+// countryTag дополняет название страны префиксом языка тега: russia -> en:russia
+func countryTag(country string) string {
+	country = strings.ToLower(strings.TrimSpace(country))
+	if country == "" || strings.Contains(country, ":") {
+		return country
+	}
+	return "en:" + country
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Некорректное значение %s=%q, используется %d", key, value, defaultValue)
+		return defaultValue
+	}
+	return n
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Некорректное значение %s=%q, используется %t", key, value, defaultValue)
+		return defaultValue
+	}
+	return b
+}
+
+func getEnvList(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// getEnvIDs читает список Telegram ID через запятую, некорректные значения пропускает
+func getEnvIDs(key string) []int64 {
+	var ids []int64
+	for _, item := range getEnvList(key, nil) {
+		id, err := strconv.ParseInt(item, 10, 64)
+		if err != nil {
+			log.Printf("Некорректный ID %q в %s, пропускаю", item, key)
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Некорректное значение %s=%q, используется %s", key, value, defaultValue)
+		return defaultValue
+	}
+	return d
+}
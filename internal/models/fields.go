@@ -0,0 +1,25 @@
+package models
+
+import (
+	"reflect"
+	"strings"
+)
+
+// APIFields возвращает имена полей Product в Open Food Facts
+// для параметра fields= API v2, чтобы не скачивать весь документ продукта.
+// Поля с тегом off:"-" заполняются ботом и в запрос не попадают.
+func APIFields() []string {
+	t := reflect.TypeOf(Product{})
+	fields := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Tag.Get("off") == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		fields = append(fields, name)
+	}
+	return fields
+}
@@ -0,0 +1,48 @@
+package models
+
+// Finding - находка анализа, за которую с оценки продукта снимаются баллы
+type Finding struct {
+	Code     string // e250, sugars, nova, en:milk
+	Title    string
+	Category string // FindingAdditives, FindingNutrition, ...
+	Severity string // high, medium, low - как в правилах анализа
+	// Найденный фрагмент и его смещение в байтах в тексте состава.
+	// Смещение есть только у находок из SourceComposition.
+	Text       string
+	Start, End int
+	Source     string // поле продукта, в котором найдено: SourceComposition, ...
+	Points     int    // сколько баллов снято из 100
+}
+
+// Категории находок, из которых складывается оценка
+const (
+	FindingAdditives  = "additives"  // опасные и сомнительные добавки и ингредиенты
+	FindingNutrition  = "nutrition"  // сахар, соль, жиры и Nutri-Score
+	FindingProcessing = "processing" // степень обработки NOVA
+	FindingAllergens  = "allergens"  // заявленные аллергены
+)
+
+// Поля продукта, в которых сделаны находки
+const (
+	SourceComposition = "ingredients_text"
+	SourceIngredients = "ingredients"
+	SourceAdditives   = "additives_tags"
+	SourceNutriments  = "nutriments"
+	SourceNutriScore  = "nutriscore_grade"
+	SourceNova        = "nova_group"
+	SourceAllergens   = "allergens_tags"
+)
+
+// FindingCategories - категории в порядке показа пользователю
+var FindingCategories = []string{FindingAdditives, FindingNutrition, FindingProcessing, FindingAllergens}
+
+// FindingsIn возвращает находки одной категории
+func (r *AnalysisResult) FindingsIn(category string) []Finding {
+	var findings []Finding
+	for _, f := range r.Findings {
+		if f.Category == category {
+			findings = append(findings, f)
+		}
+	}
+	return findings
+}
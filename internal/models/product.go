@@ -1,16 +1,95 @@
 package models
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"strconv"
+)
 
 type Product struct {
-	Barcode     string       `json:"code"`
-	Name        string       `json:"product_name"`
-	Brand       string       `json:"brands"`
-	Ingredients []Ingredient `json:"ingredients"`
-	Composition string       `json:"ingredients_text"`
-	ImageURL    string       `json:"image_url"`
-	Additives   []string     `json:"additives_tags"`
-	Allergens   string       `json:"allergens"`
+	Barcode       string       `json:"code"`
+	Name          string       `json:"product_name"`
+	NameRu        string       `json:"product_name_ru"`
+	Brand         string       `json:"brands"`
+	Quantity      string       `json:"quantity"`
+	Ingredients   []Ingredient `json:"ingredients"`
+	Composition   string       `json:"ingredients_text"`
+	CompositionRu string       `json:"ingredients_text_ru"`
+	ImageURL      string       `json:"image_url"`
+	Additives     []string     `json:"additives_tags"`
+	Allergens     string       `json:"allergens"`
+
+	AllergensTags  []string `json:"allergens_tags"`
+	TracesTags     []string `json:"traces_tags"`
+	LabelsTags     []string `json:"labels_tags"`
+	CategoriesTags []string `json:"categories_tags"`
+	CountriesTags  []string `json:"countries_tags"` // страны, где продается продукт
+
+	Nutriments      Nutriments  `json:"nutriments"`
+	NutriScoreGrade string      `json:"nutriscore_grade"`
+	NovaGroup       json.Number `json:"nova_group,omitempty"`
+	EcoScoreGrade   string      `json:"ecoscore_grade"`
+
+	// Тип продукта: food, beauty, petfood - определяет набор правил анализа
+	ProductType string `json:"product_type"`
+	// Источник, в котором нашелся продукт. Заполняется ботом, в API такого поля нет.
+	Source string `json:"source" off:"-"`
+}
+
+// Типы продуктов (совпадают со значениями product_type в Open Food Facts)
+const (
+	ProductTypeFood    = "food"
+	ProductTypeBeauty  = "beauty"
+	ProductTypePetFood = "petfood"
+)
+
+// Nutriments - пищевая ценность на 100 г продукта.
+// Пустое значение означает, что данных нет (а не ноль).
+type Nutriments struct {
+	EnergyKcal   json.Number `json:"energy-kcal_100g,omitempty"`
+	EnergyKJ     json.Number `json:"energy-kj_100g,omitempty"`
+	Fat          json.Number `json:"fat_100g,omitempty"`
+	SaturatedFat json.Number `json:"saturated-fat_100g,omitempty"`
+	Sugars       json.Number `json:"sugars_100g,omitempty"`
+	Salt         json.Number `json:"salt_100g,omitempty"`
+	Fiber        json.Number `json:"fiber_100g,omitempty"`
+	Proteins     json.Number `json:"proteins_100g,omitempty"`
+}
+
+// NovaLevel возвращает группу обработки NOVA (1-4) или 0, если она неизвестна
+func (p *Product) NovaLevel() int {
+	group, err := strconv.Atoi(p.NovaGroup.String())
+	if err != nil || group < 1 || group > 4 {
+		return 0
+	}
+	return group
+}
+
+// NumberValue возвращает значение числового поля API; ok == false если данных нет
+func NumberValue(n json.Number) (value float64, ok bool) {
+	if n == "" {
+		return 0, false
+	}
+	value, err := n.Float64()
+	if err != nil {
+		return 0, false
+	}
+	return value, true
+}
+
+// DisplayName возвращает название на русском, если оно есть
+func (p *Product) DisplayName() string {
+	if p.NameRu != "" {
+		return p.NameRu
+	}
+	return p.Name
+}
+
+// DisplayComposition возвращает состав на русском, если он есть
+func (p *Product) DisplayComposition() string {
+	if p.CompositionRu != "" {
+		return p.CompositionRu
+	}
+	return p.Composition
 }
 
 type Ingredient struct {
@@ -29,6 +108,30 @@ type APIResponse struct {
 	Product Product `json:"product"`
 }
 
+// SearchResponse - ответ /api/v2/search
+type SearchResponse struct {
+	Count    int       `json:"count"`
+	Products []Product `json:"products"`
+}
+
+// Уровень содержания нутриента по системе "светофора"
+type NutrientLevel string
+
+const (
+	LevelLow    NutrientLevel = "low"
+	LevelMedium NutrientLevel = "medium"
+	LevelHigh   NutrientLevel = "high"
+)
+
+// NutrientInfo - содержание одного нутриента на 100 г и его оценка
+type NutrientInfo struct {
+	Key     string // sugars, salt, fat, saturated-fat, fiber, proteins, energy-kcal
+	Name    string
+	Per100g float64
+	Unit    string
+	Level   NutrientLevel // пусто для нутриентов без "светофора" (энергия, белок, клетчатка)
+}
+
 // Результат анализа продукта
 type AnalysisResult struct {
 	Product         *Product
@@ -36,4 +139,47 @@ type AnalysisResult struct {
 	Warnings        []string
 	Dangerous       []string
 	Recommendations []string
+
+	// Пищевая ценность на 100 г (только для продуктов питания)
+	Nutrition  []NutrientInfo
+	NutriScore string // a-e или пусто, если неизвестен
+	NovaGroup  int    // 1-4 или 0, если неизвестна
+
+	// Оценка от 0 до 100: чем выше, тем лучше продукт. Складывается из
+	// находок: 100 минус их баллы. Личные ограничения из профиля не учитываются.
+	Score    int
+	Findings []Finding
+
+	// Почему продукт не подходит пользователю по его профилю
+	Unsuitable []string
+}
+
+// HighNutrients возвращает нутриенты с высоким содержанием
+func (r *AnalysisResult) HighNutrients() []NutrientInfo {
+	var high []NutrientInfo
+	for _, n := range r.Nutrition {
+		if n.Level == LevelHigh {
+			high = append(high, n)
+		}
+	}
+	return high
+}
+
+// Итоговая оценка продукта для истории проверок
+const (
+	VerdictHealthy   = "healthy"
+	VerdictCaution   = "caution"
+	VerdictDangerous = "dangerous"
+)
+
+// Verdict сводит результат анализа к одной оценке
+func (r *AnalysisResult) Verdict() string {
+	switch {
+	case len(r.Dangerous) > 0:
+		return VerdictDangerous
+	case len(r.Warnings) > 0 || !r.Healthy:
+		return VerdictCaution
+	default:
+		return VerdictHealthy
+	}
 }
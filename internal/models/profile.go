@@ -0,0 +1,14 @@
+package models
+
+// UserProfile - личные ограничения пользователя по питанию
+type UserProfile struct {
+	UserID    int64    `json:"user_id"`
+	Allergens []string `json:"allergens"` // теги Open Food Facts: en:gluten, en:nuts
+	Diets     []string `json:"diets"`     // vegan, vegetarian, halal, lactose_free, diabetic
+	Avoid     []string `json:"avoid"`     // свои ингредиенты, которых нужно избегать
+}
+
+// IsEmpty - пользователь ничего не указал
+func (p *UserProfile) IsEmpty() bool {
+	return p == nil || len(p.Allergens) == 0 && len(p.Diets) == 0 && len(p.Avoid) == 0
+}
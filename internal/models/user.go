@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// User - пользователь бота
+type User struct {
+	ID           int64     `json:"id"`
+	ChatID       int64     `json:"chat_id"` // последний чат, из которого писал пользователь
+	Username     string    `json:"username,omitempty"`
+	FirstName    string    `json:"first_name,omitempty"`
+	LanguageCode string    `json:"language_code,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	LastSeenAt   time.Time `json:"last_seen_at"`
+}
+
+// Scan - успешная проверка продукта в чате
+type Scan struct {
+	ID          int64     `json:"id"`
+	UserID      int64     `json:"user_id"`
+	ChatID      int64     `json:"chat_id"`
+	Barcode     string    `json:"barcode"`
+	ProductName string    `json:"product_name"`
+	Verdict     string    `json:"verdict"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Favorite - продукт в избранном пользователя
+type Favorite struct {
+	UserID      int64     `json:"user_id"`
+	Barcode     string    `json:"barcode"`
+	ProductName string    `json:"product_name"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Feedback - сообщение пользователя об ошибке в данных о продукте
+type Feedback struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"user_id"`
+	ChatID    int64     `json:"chat_id"`
+	Barcode   string    `json:"barcode"`
+	Message   string    `json:"message"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/ajeanett/telbot/internal/models"
+)
+
+// ProductSearcher ищет продукты по категории Open Food Facts
+type ProductSearcher interface {
+	SearchByCategory(ctx context.Context, category, country string, limit int) ([]models.Product, error)
+}
+
+const (
+	// Сколько продуктов категории анализируется при поиске альтернатив
+	alternativesSearchSize = 50
+	// Сколько последних, самых узких категорий продукта пробуется: в
+	// узкой категории альтернатив может не найтись
+	alternativesCategoryDepth = 2
+)
+
+// AlternativeFinder ищет в категории продукта товары с оценкой выше
+type AlternativeFinder struct {
+	searcher ProductSearcher
+	analyzer *Analyzer
+}
+
+func NewAlternativeFinder(searcher ProductSearcher, analyzer *Analyzer) *AlternativeFinder {
+	return &AlternativeFinder{searcher: searcher, analyzer: analyzer}
+}
+
+// Alternatives - найденные альтернативы и категория, в которой они найдены
+type Alternatives struct {
+	Category string
+	Results  []*models.AnalysisResult // от лучшей оценки к худшей
+}
+
+// Find анализирует популярные продукты из категорий result, которые продаются
+// в country, и возвращает те, у которых оценка выше. accept отсеивает
+// кандидатов, например неподходящих по профилю; nil - подходят все.
+// Категории перебираются от самой узкой, пока не найдется хотя бы одна альтернатива.
+func (f *AlternativeFinder) Find(ctx context.Context, result *models.AnalysisResult, country string, accept func(candidate *models.AnalysisResult) bool) (*Alternatives, error) {
+	categories := result.Product.CategoriesTags
+	if len(categories) == 0 {
+		return &Alternatives{}, nil
+	}
+
+	// Open Food Facts перечисляет категории от общей к частной
+	var lastErr error
+	for i := len(categories) - 1; i >= max(0, len(categories)-alternativesCategoryDepth); i-- {
+		category := categories[i]
+		products, err := f.searcher.SearchByCategory(ctx, category, country, alternativesSearchSize)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			lastErr = fmt.Errorf("поиск в категории %s: %w", category, err)
+			continue
+		}
+
+		better := f.better(result, products, country, accept)
+		if len(better) > 0 {
+			return &Alternatives{Category: category, Results: better}, nil
+		}
+	}
+	if lastErr != nil {
+		return nil, lastErr
+	}
+	return &Alternatives{}, nil
+}
+
+// better анализирует кандидатов и оставляет продукты с оценкой выше, чем у result
+func (f *AlternativeFinder) better(result *models.AnalysisResult, products []models.Product, country string, accept func(candidate *models.AnalysisResult) bool) []*models.AnalysisResult {
+	var better []*models.AnalysisResult
+	for i := range products {
+		product := &products[i]
+		if product.Barcode == "" || product.Barcode == result.Product.Barcode {
+			continue
+		}
+		// Поиск по стране не строгий: проверяем еще раз по тегам продукта
+		if country != "" && !slices.Contains(product.CountriesTags, country) {
+			continue
+		}
+		// Без состава и пищевой ценности оценка будет завышена
+		if product.DisplayComposition() == "" && product.NutriScoreGrade == "" {
+			continue
+		}
+		candidate := f.analyzer.AnalyzeProduct(product)
+		if candidate.Score > result.Score && (accept == nil || accept(candidate)) {
+			better = append(better, candidate)
+		}
+	}
+	slices.SortStableFunc(better, func(x, y *models.AnalysisResult) int {
+		return cmp.Compare(y.Score, x.Score)
+	})
+	return better
+}
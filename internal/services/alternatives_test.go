@@ -0,0 +1,191 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/ajeanett/telbot/internal/models"
+	"github.com/ajeanett/telbot/internal/testutil"
+)
+
+const testCountry = "en:russia"
+
+// yogurt - продукт категории йогуртов, продающийся в testCountry
+func yogurt(code, composition, nutriScore string, categories ...string) models.Product {
+	if len(categories) == 0 {
+		categories = []string{"en:dairies", "en:yogurts"}
+	}
+	return models.Product{
+		Barcode:         code,
+		Name:            "Йогурт " + code,
+		CompositionRu:   composition,
+		NutriScoreGrade: nutriScore,
+		CategoriesTags:  categories,
+		CountriesTags:   []string{testCountry},
+	}
+}
+
+// laxCountrySearcher ищет без фильтра по стране, как настоящая база,
+// которая возвращает и продукты без тега страны
+type laxCountrySearcher struct {
+	ProductSearcher
+}
+
+func (s laxCountrySearcher) SearchByCategory(ctx context.Context, category, country string, limit int) ([]models.Product, error) {
+	return s.ProductSearcher.SearchByCategory(ctx, category, "", limit)
+}
+
+type failingSearcher struct {
+	err error
+}
+
+func (s failingSearcher) SearchByCategory(ctx context.Context, category, country string, limit int) ([]models.Product, error) {
+	return nil, s.err
+}
+
+func newFakeSearchSource(t *testing.T, products ...models.Product) *OpenFactsSource {
+	t.Helper()
+	db := testutil.NewFakeProductDB(products...)
+	t.Cleanup(db.Close)
+	return NewOpenFactsSource("Open Food Facts", models.ProductTypeFood, db.URL(), APIVersionV2, nil, noRetry)
+}
+
+func barcodes(results []*models.AnalysisResult) []string {
+	var codes []string
+	for _, result := range results {
+		codes = append(codes, result.Product.Barcode)
+	}
+	return codes
+}
+
+func TestSearchByCategory(t *testing.T) {
+	other := yogurt("3", "молоко", "a")
+	other.CountriesTags = []string{"en:france"}
+	source := newFakeSearchSource(t, yogurt("1", "молоко", "a"), yogurt("2", "молоко", "b", "en:dairies"), other)
+
+	products, err := source.SearchByCategory(context.Background(), "en:yogurts", testCountry, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(products) != 1 || products[0].Barcode != "1" {
+		t.Fatalf("найдены %v, ожидался только продукт 1", products)
+	}
+	if products[0].Source != "Open Food Facts" || products[0].ProductType != models.ProductTypeFood {
+		t.Errorf("источник %q и тип %q не заполнены", products[0].Source, products[0].ProductType)
+	}
+}
+
+func TestAlternativeFinderFind(t *testing.T) {
+	analyzer := NewAnalyzer()
+	// Оценка 86: Nutri-Score D
+	original := yogurt("100", "молоко", "d", "en:dairies", "en:yogurts", "en:greek-yogurts")
+
+	noCountry := yogurt("6", "молоко", "a")
+	noCountry.CountriesTags = nil
+	catalog := []models.Product{
+		original,
+		yogurt("1", "молоко", "c"), // 94
+		yogurt("2", "молоко", "a"), // 100
+		yogurt("3", "молоко", "e"), // 78 - хуже оригинала
+		yogurt("4", "", ""),        // ни состава, ни Nutri-Score
+		yogurt("5", "молоко", "b"), // 100
+		noCountry,                  // 100, но не продается в стране
+		yogurt("7", "молоко, нитрит натрия", "a"), // 70 - хуже оригинала
+	}
+
+	tests := []struct {
+		name     string
+		catalog  []models.Product
+		lax      bool // поиск не фильтрует по стране
+		accept   func(*models.AnalysisResult) bool
+		category string
+		want     []string
+	}{
+		{
+			name:     "переход от узкой категории к более общей",
+			catalog:  catalog,
+			category: "en:yogurts",
+			want:     []string{"2", "5", "1"},
+		},
+		{
+			name:     "повторная проверка страны",
+			catalog:  catalog,
+			lax:      true,
+			category: "en:yogurts",
+			want:     []string{"2", "5", "1"},
+		},
+		{
+			name:     "фильтр accept",
+			catalog:  catalog,
+			accept:   func(c *models.AnalysisResult) bool { return c.Product.Barcode != "2" },
+			category: "en:yogurts",
+			want:     []string{"5", "1"},
+		},
+		{
+			name:     "альтернатива в самой узкой категории",
+			catalog:  append(slices.Clone(catalog), yogurt("8", "молоко", "c", "en:yogurts", "en:greek-yogurts")),
+			category: "en:greek-yogurts",
+			want:     []string{"8"},
+		},
+		{
+			name:    "общие категории не перебираются",
+			catalog: []models.Product{original, yogurt("1", "молоко", "a", "en:dairies")},
+		},
+		{
+			name:    "все кандидаты хуже",
+			catalog: []models.Product{original, yogurt("3", "молоко", "e"), yogurt("4", "", "")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := newFakeSearchSource(t, tt.catalog...)
+			var searcher ProductSearcher = source
+			if tt.lax {
+				searcher = laxCountrySearcher{source}
+			}
+			found, err := NewAlternativeFinder(searcher, analyzer).Find(context.Background(), analyzer.AnalyzeProduct(&original), testCountry, tt.accept)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if found.Category != tt.category {
+				t.Errorf("категория %q, ожидалась %q", found.Category, tt.category)
+			}
+			if got := barcodes(found.Results); !slices.Equal(got, tt.want) {
+				t.Errorf("альтернативы %v, ожидались %v", got, tt.want)
+			}
+			for i := 1; i < len(found.Results); i++ {
+				if found.Results[i-1].Score < found.Results[i].Score {
+					t.Errorf("альтернативы не отсортированы по оценке: %d перед %d", found.Results[i-1].Score, found.Results[i].Score)
+				}
+			}
+		})
+	}
+}
+
+func TestAlternativeFinderErrors(t *testing.T) {
+	analyzer := NewAnalyzer()
+	original := yogurt("100", "молоко", "d")
+	result := analyzer.AnalyzeProduct(&original)
+
+	found, err := NewAlternativeFinder(failingSearcher{ErrUpstreamUnavailable}, analyzer).Find(context.Background(), result, testCountry, nil)
+	if !errors.Is(err, ErrUpstreamUnavailable) {
+		t.Errorf("ошибка %v, ожидалась ErrUpstreamUnavailable", err)
+	}
+	if found != nil {
+		t.Errorf("при ошибке поиска вернулись альтернативы %v", found)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := NewAlternativeFinder(failingSearcher{context.Canceled}, analyzer).Find(ctx, result, testCountry, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("ошибка %v, ожидалась context.Canceled", err)
+	}
+
+	uncategorized := models.Product{Barcode: "200", CompositionRu: "молоко"}
+	found, err = NewAlternativeFinder(failingSearcher{ErrUpstreamUnavailable}, analyzer).Find(context.Background(), analyzer.AnalyzeProduct(&uncategorized), testCountry, nil)
+	if err != nil || len(found.Results) != 0 {
+		t.Errorf("без категорий: %v, %v; ожидался пустой результат без поиска", found, err)
+	}
+}
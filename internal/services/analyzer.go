@@ -1,47 +1,42 @@
 package services
 
 import (
+	"fmt"
 	"github.com/ajeanett/telbot/internal/models"
 	"github.com/ajeanett/telbot/internal/utils"
 	"strings"
+	"sync/atomic"
 )
 
 type Analyzer struct {
-	dangerousIngredients  map[string]string
-	suspiciousIngredients map[string]string
-	additives             map[string]string
+	// Правила подменяются целиком при перезагрузке, пока идут проверки
+	rules atomic.Pointer[RuleSet]
+	// Пороги "светофора" для пищевой ценности
+	thresholds NutritionThresholds
 }
 
 func NewAnalyzer() *Analyzer {
-	return &Analyzer{
-		dangerousIngredients: map[string]string{
-			"e951": "Аспартам (искусственный подсластитель)",
-			"e621": "Глутамат натрия (усилитель вкуса)",
-			"e250": "Нитрит натрия (консервант)",
-			"e211": "Бензоат натрия (консервант)",
-			"e102": "Тартразин (краситель)",
-		},
-		suspiciousIngredients: map[string]string{
-			"пальмовое масло": "Пальмовое масло",
-			"palm oil":        "Пальмовое масло",
-			"гмо":             "ГМО",
-			"gmo":             "ГМО",
-			"трансжиры":       "Трансжиры",
-			"trans fat":       "Трансжиры",
-			"краситель":       "Искусственные красители",
-			"консервант":      "Консерванты",
-			"ароматизатор":    "Искусственные ароматизаторы",
-			"усилитель вкуса": "Усилители вкуса",
-		},
-		additives: map[string]string{
-			"e471":  "Моно- и диглицериды жирных кислот (эмульгатор)",
-			"e440":  "Пектин (загуститель)",
-			"e965":  "Мальтит (подсластитель)",
-			"e422":  "Глицерин (влагоудерживающий агент)",
-			"e150a": "Сахарный колер I (краситель)",
-			"e306":  "Концентрат смеси токоферолов (антиокислитель)",
-		},
+	rules, err := DefaultRules()
+	if err != nil {
+		// Встроенные правила проверяются при каждой сборке - ошибка здесь это баг
+		panic(fmt.Sprintf("встроенные правила анализа некорректны: %v", err))
 	}
+
+	a := &Analyzer{
+		thresholds: DefaultNutritionThresholds,
+	}
+	a.rules.Store(rules)
+	return a
+}
+
+// SetRules атомарно заменяет набор правил
+func (a *Analyzer) SetRules(rules *RuleSet) {
+	a.rules.Store(rules)
+}
+
+// Rules возвращает текущий набор правил
+func (a *Analyzer) Rules() *RuleSet {
+	return a.rules.Load()
 }
 
 func (a *Analyzer) AnalyzeProduct(product *models.Product) *models.AnalysisResult {
@@ -49,71 +44,137 @@ func (a *Analyzer) AnalyzeProduct(product *models.Product) *models.AnalysisResul
 		Product: product,
 	}
 
-	// Анализируем состав из ingredients_text
-	if product.Composition != "" {
-		composition := strings.ToLower(product.Composition)
-		a.analyzeComposition(composition, result)
-	}
-
-	// Анализируем список ингредиентов
-	if len(product.Ingredients) > 0 {
-		a.analyzeIngredientsList(product.Ingredients, result)
+	for _, match := range matchProduct(a.rules.Load(), product) {
+		reportRule(match, product.ProductType, result)
 	}
 
-	// Анализируем пищевые добавки (E-шки)
-	if len(product.Additives) > 0 {
-		a.analyzeAdditives(product.Additives, result)
+	// Пищевая ценность есть только у еды
+	if product.ProductType != models.ProductTypeBeauty {
+		a.analyzeNutrition(product, result)
 	}
 
 	// Формируем итоговые рекомендации
 	a.generateRecommendations(result)
 
+	score(product, result)
+
 	return result
 }
 
-func (a *Analyzer) analyzeComposition(composition string, result *models.AnalysisResult) {
-	// Проверяем опасные ингредиенты
-	for code, description := range a.dangerousIngredients {
-		if strings.Contains(composition, code) {
-			result.Dangerous = append(result.Dangerous, description)
+// MatchedRules возвращает правила, сработавшие на продукте и видимые
+// пользователю, - для подробного объяснения найденных добавок
+func (a *Analyzer) MatchedRules(product *models.Product) []*Rule {
+	var visible []*Rule
+	for _, match := range matchProduct(a.rules.Load(), product) {
+		if match.rule.SeverityFor(product.ProductType) != SeverityNone {
+			visible = append(visible, match.rule)
 		}
 	}
+	return visible
+}
 
-	// Проверяем сомнительные ингредиенты
-	for ingredient, description := range a.suspiciousIngredients {
-		if strings.Contains(composition, ingredient) {
-			result.Warnings = append(result.Warnings, description)
+// ruleMatch - сработавшее правило и первое место, где оно найдено
+type ruleMatch struct {
+	rule   *Rule
+	source string // models.SourceComposition, SourceIngredients или SourceAdditives
+	text   string // найденный фрагмент
+	// Смещение фрагмента в тексте состава; только для models.SourceComposition
+	start, end int
+}
+
+// matchProduct ищет правила в составе, списке ингредиентов и тегах добавок.
+// Каждое правило возвращается один раз, в порядке первого срабатывания.
+func matchProduct(rules *RuleSet, product *models.Product) []ruleMatch {
+	var matched []ruleMatch
+	seen := make(map[*Rule]bool)
+	add := func(match ruleMatch) {
+		if !seen[match.rule] {
+			seen[match.rule] = true
+			matched = append(matched, match)
 		}
 	}
-}
+	productType := product.ProductType
+
+	// Анализируем состав из ingredients_text
+	if text := product.DisplayComposition(); text != "" {
+		matchRules(rules.ForType(productType), text, func(rule *Rule, span Token) {
+			add(ruleMatch{rule: rule, source: models.SourceComposition, text: text[span.Start:span.End], start: span.Start, end: span.End})
+		})
+	}
 
-func (a *Analyzer) analyzeIngredientsList(ingredients []models.Ingredient, result *models.AnalysisResult) {
-	for _, ingredient := range ingredients {
-		text := strings.ToLower(ingredient.Text)
+	// Анализируем список ингредиентов
+	for _, ingredient := range product.Ingredients {
+		matchRules(rules.ForType(productType), ingredient.Text, func(rule *Rule, span Token) {
+			add(ruleMatch{rule: rule, source: models.SourceIngredients, text: ingredient.Text[span.Start:span.End]})
+		})
+	}
 
-		// Проверяем каждый ингредиент
-		for ing, description := range a.suspiciousIngredients {
-			if strings.Contains(text, ing) {
-				result.Warnings = utils.AppendIfNotExists(result.Warnings, description)
-			}
+	// Анализируем пищевые добавки (E-шки), они приходят в формате "en:e471"
+	for _, additive := range product.Additives {
+		if rule, ok := rules.ByCode(additive); ok && rule.AppliesTo(productType) {
+			add(ruleMatch{rule: rule, source: models.SourceAdditives, text: additive})
 		}
+	}
+	return matched
+}
 
-		for code, description := range a.dangerousIngredients {
-			if strings.Contains(text, code) {
-				result.Dangerous = utils.AppendIfNotExists(result.Dangerous, description)
+// matchRules разбирает состав на ингредиенты и ищет в каждом коды и
+// названия из правил целыми словами, с учетом форм слова. span - найденные
+// слова со смещением в text.
+func matchRules(rules []*Rule, text string, report func(rule *Rule, span Token)) {
+	for _, root := range ParseIngredients(text) {
+		root.Walk(func(node *IngredientNode) {
+			for _, rule := range rules {
+				if span, ok := ruleMatches(rule, node.Tokens); ok {
+					report(rule, span)
+				}
 			}
+		})
+	}
+}
+
+func ruleMatches(rule *Rule, tokens []Token) (Token, bool) {
+	for _, pattern := range rule.patterns {
+		if span, ok := containsTokens(tokens, pattern); ok {
+			return span, true
 		}
 	}
+	return Token{}, false
 }
 
-func (a *Analyzer) analyzeAdditives(additives []string, result *models.AnalysisResult) {
-	for _, additive := range additives {
-		// Добавки приходят в формате "en:e471" - извлекаем код
-		code := strings.TrimPrefix(additive, "en:")
-		if description, exists := a.additives[code]; exists {
-			result.Warnings = utils.AppendIfNotExists(result.Warnings, "Добавка "+code+": "+description)
+// reportRule добавляет сработавшее правило в результат по уровню опасности
+func reportRule(match ruleMatch, productType string, result *models.AnalysisResult) {
+	rule := match.rule
+	severity := rule.SeverityFor(productType)
+	switch severity {
+	case SeverityHigh:
+		result.Dangerous = utils.AppendIfNotExists(result.Dangerous, rule.Title)
+	case SeverityMedium:
+		result.Warnings = utils.AppendIfNotExists(result.Warnings, rule.Title)
+	case SeverityLow:
+		title := rule.Title
+		if rule.Code != "" {
+			title = "Добавка " + strings.ToUpper(rule.Code) + ": " + rule.Title
 		}
+		result.Warnings = utils.AppendIfNotExists(result.Warnings, title)
+	default:
+		return
+	}
+
+	code := rule.Code
+	if code == "" {
+		code = rule.ID
 	}
+	result.Findings = append(result.Findings, models.Finding{
+		Code:     code,
+		Title:    rule.Title,
+		Category: models.FindingAdditives,
+		Severity: severity,
+		Text:     match.text,
+		Start:    match.start,
+		End:      match.end,
+		Source:   match.source,
+	})
 }
 
 func (a *Analyzer) generateRecommendations(result *models.AnalysisResult) {
@@ -122,8 +183,13 @@ func (a *Analyzer) generateRecommendations(result *models.AnalysisResult) {
 		result.Recommendations = append(result.Recommendations,
 			"🚫 Продукт содержит потенциально опасные ингредиенты")
 	} else if len(result.Warnings) > 0 {
+		result.Healthy = false
 		result.Recommendations = append(result.Recommendations,
 			"⚠️ Продукт содержит сомнительные ингредиенты")
+	} else if poorNutrition(result) {
+		result.Healthy = false
+		result.Recommendations = append(result.Recommendations,
+			"⚠️ Состав без опасных ингредиентов, но пищевая ценность низкая")
 	} else {
 		result.Healthy = true
 		result.Recommendations = append(result.Recommendations,
@@ -135,6 +201,8 @@ func (a *Analyzer) generateRecommendations(result *models.AnalysisResult) {
 		result.Recommendations = append(result.Recommendations,
 			"💡 Обратите внимание на пищевые добавки в составе")
 	}
+
+	a.nutritionRecommendations(result)
 }
 
 // // Вспомогательная функция чтобы избежать дубликатов
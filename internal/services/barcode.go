@@ -1,49 +1,112 @@
 package services
 
 import (
-	"encoding/json"
-	"fmt"
+	"context"
+	"errors"
 	"github.com/ajeanett/telbot/internal/models"
-	"io"
-	"net/http"
+	"log"
+	"time"
+
+	"golang.org/x/sync/singleflight"
 )
 
+// ErrProductNotFound - продукта с таким штрих-кодом нет в базе
+var ErrProductNotFound = errors.New("продукт не найден в базе")
+
+// Максимальное время общего запроса к источникам, включая повторы.
+// Не зависит от контекста первого вызвавшего, т.к. результат делят все ожидающие.
+const productFetchTimeout = 45 * time.Second
+
 type BarcodeService struct {
-	apiURL string
+	// Обычно SourceChain из нескольких баз
+	source ProductSource
+
+	cache       ProductCache
+	cacheTTL    time.Duration // время жизни найденного продукта
+	notFoundTTL time.Duration // время жизни ответа "не найден"
+
+	// Объединяет одновременные запросы одного штрих-кода в один запрос к API
+	inflight singleflight.Group
 }
 
-func NewBarcodeService(apiURL string) *BarcodeService {
+func NewBarcodeService(
+	source ProductSource,
+	cache ProductCache,
+	cacheTTL, notFoundTTL time.Duration,
+) *BarcodeService {
 	return &BarcodeService{
-		apiURL: apiURL,
+		source:      source,
+		cache:       cache,
+		cacheTTL:    cacheTTL,
+		notFoundTTL: notFoundTTL,
 	}
 }
 
-func (s *BarcodeService) GetProductByBarcode(barcode string) (*models.Product, error) {
-	url := fmt.Sprintf("%s/product/%s.json", s.apiURL, barcode)
+// GetProductByBarcode ищет продукт по штрих-коду.
+// Возвращает ErrProductNotFound, ErrUpstreamUnavailable или ErrRateLimited.
+func (s *BarcodeService) GetProductByBarcode(ctx context.Context, barcode string) (*models.Product, error) {
+	if entry, ok := s.getCached(ctx, barcode); ok {
+		if entry.NotFound {
+			return nil, ErrProductNotFound
+		}
+		return entry.Product, nil
+	}
 
-	resp, err := http.Get(url)
-	if err != nil {
-		return nil, fmt.Errorf("ошибка запроса: %w", err)
+	ch := s.inflight.DoChan(barcode, func() (interface{}, error) {
+		fetchCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), productFetchTimeout)
+		defer cancel()
+
+		product, err := s.source.Lookup(fetchCtx, barcode)
+		s.storeCached(fetchCtx, barcode, product, err)
+		return product, err
+	})
+
+	var res singleflight.Result
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res = <-ch:
+	}
+	if res.Err != nil {
+		return nil, res.Err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("продукт не найден (статус: %d)", resp.StatusCode)
+	// Копия, чтобы горутины, получившие общий результат, не делили один указатель
+	product := *res.Val.(*models.Product)
+	return &product, nil
+}
+
+func (s *BarcodeService) getCached(ctx context.Context, barcode string) (CacheEntry, bool) {
+	if s.cache == nil {
+		return CacheEntry{}, false
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	entry, ok, err := s.cache.Get(ctx, barcode)
 	if err != nil {
-		return nil, fmt.Errorf("ошибка чтения ответа: %w", err)
+		log.Printf("Ошибка чтения кэша для %s: %v", barcode, err)
+		return CacheEntry{}, false
 	}
+	return entry, ok
+}
 
-	var response models.APIResponse
-	if err := json.Unmarshal(body, &response); err != nil {
-		return nil, fmt.Errorf("ошибка парсинга JSON: %w", err)
+func (s *BarcodeService) storeCached(ctx context.Context, barcode string, product *models.Product, err error) {
+	if s.cache == nil {
+		return
 	}
 
-	if response.Status != 1 {
-		return nil, fmt.Errorf("продукт не найден в базе")
+	var entry CacheEntry
+	var ttl time.Duration
+	switch {
+	case err == nil:
+		entry, ttl = CacheEntry{Product: product}, s.cacheTTL
+	case errors.Is(err, ErrProductNotFound):
+		entry, ttl = CacheEntry{NotFound: true}, s.notFoundTTL
+	default:
+		// Сетевые ошибки и сбои API не кэшируем
+		return
 	}
 
-	return &response.Product, nil
+	if err := s.cache.Set(ctx, barcode, entry, ttl); err != nil {
+		log.Printf("Ошибка записи в кэш для %s: %v", barcode, err)
+	}
 }
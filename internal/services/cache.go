@@ -0,0 +1,33 @@
+// services/cache.go
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/ajeanett/telbot/internal/models"
+)
+
+// CacheEntry - закэшированный результат поиска продукта.
+// NotFound == true означает закэшированный ответ "продукт не найден".
+type CacheEntry struct {
+	Product  *models.Product `json:"product,omitempty"`
+	NotFound bool            `json:"not_found,omitempty"`
+}
+
+// ProductCache - хранилище результатов поиска продуктов по штрих-коду
+type ProductCache interface {
+	// Get возвращает запись из кэша; ok == false если записи нет или она устарела
+	Get(ctx context.Context, barcode string) (entry CacheEntry, ok bool, err error)
+	// Set сохраняет запись на время ttl
+	Set(ctx context.Context, barcode string, entry CacheEntry, ttl time.Duration) error
+	Close() error
+}
+
+// NewProductCache создает Redis-кэш если задан redisURL, иначе LRU-кэш в памяти
+func NewProductCache(redisURL string, size int) (ProductCache, error) {
+	if redisURL != "" {
+		return NewRedisCache(redisURL)
+	}
+	return NewMemoryCache(size), nil
+}
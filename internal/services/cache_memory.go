@@ -0,0 +1,112 @@
+// services/cache_memory.go
+package services
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+const defaultMemoryCacheSize = 1000
+
+// MemoryCache - LRU-кэш продуктов в памяти процесса
+type MemoryCache struct {
+	mu       sync.Mutex
+	capacity int
+	items    map[string]*list.Element
+	order    *list.List // в начале - самые свежие записи
+	now      func() time.Time
+}
+
+type memoryCacheItem struct {
+	key       string
+	entry     CacheEntry
+	expiresAt time.Time
+}
+
+func NewMemoryCache(capacity int) *MemoryCache {
+	if capacity <= 0 {
+		capacity = defaultMemoryCacheSize
+	}
+	return &MemoryCache{
+		capacity: capacity,
+		items:    make(map[string]*list.Element),
+		order:    list.New(),
+		now:      time.Now,
+	}
+}
+
+func (c *MemoryCache) Get(_ context.Context, barcode string) (CacheEntry, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[barcode]
+	if !ok {
+		return CacheEntry{}, false, nil
+	}
+
+	item := elem.Value.(*memoryCacheItem)
+	if !item.expiresAt.IsZero() && c.now().After(item.expiresAt) {
+		c.removeElement(elem)
+		return CacheEntry{}, false, nil
+	}
+
+	c.order.MoveToFront(elem)
+	return copyEntry(item.entry), true, nil
+}
+
+func (c *MemoryCache) Set(_ context.Context, barcode string, entry CacheEntry, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = c.now().Add(ttl)
+	}
+
+	if elem, ok := c.items[barcode]; ok {
+		item := elem.Value.(*memoryCacheItem)
+		item.entry = copyEntry(entry)
+		item.expiresAt = expiresAt
+		c.order.MoveToFront(elem)
+		return nil
+	}
+
+	elem := c.order.PushFront(&memoryCacheItem{
+		key:       barcode,
+		entry:     copyEntry(entry),
+		expiresAt: expiresAt,
+	})
+	c.items[barcode] = elem
+
+	// Вытесняем самые давно использованные записи
+	for c.order.Len() > c.capacity {
+		c.removeElement(c.order.Back())
+	}
+	return nil
+}
+
+func (c *MemoryCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *MemoryCache) Close() error {
+	return nil
+}
+
+func (c *MemoryCache) removeElement(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.items, elem.Value.(*memoryCacheItem).key)
+}
+
+// copyEntry копирует продукт, чтобы вызывающий код не менял закэшированные данные
+func copyEntry(entry CacheEntry) CacheEntry {
+	if entry.Product != nil {
+		product := *entry.Product
+		entry.Product = &product
+	}
+	return entry
+}
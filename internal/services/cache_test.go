@@ -0,0 +1,227 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ajeanett/telbot/internal/models"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// countingSource считает обращения к базе; release, если задан, задерживает ответ
+type countingSource struct {
+	calls   atomic.Int32
+	started chan struct{}
+	release chan struct{}
+	err     error
+}
+
+func (s *countingSource) Name() string { return "test" }
+
+func (s *countingSource) Lookup(ctx context.Context, barcode string) (*models.Product, error) {
+	if s.calls.Add(1) == 1 && s.started != nil {
+		close(s.started)
+	}
+	if s.release != nil {
+		<-s.release
+	}
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &models.Product{Barcode: barcode, Name: "Кефир"}, nil
+}
+
+// fakeClock - управляемое время для MemoryCache
+type fakeClock struct{ now time.Time }
+
+func (c *fakeClock) Now() time.Time          { return c.now }
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newTestMemoryCache(capacity int) (*MemoryCache, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	cache := NewMemoryCache(capacity)
+	cache.now = clock.Now
+	return cache, clock
+}
+
+func TestMemoryCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	cache, _ := newTestMemoryCache(2)
+
+	cache.Set(ctx, "a", CacheEntry{NotFound: true}, 0)
+	cache.Set(ctx, "b", CacheEntry{NotFound: true}, 0)
+	// Чтение делает "a" свежей записью, вытеснена будет "b"
+	if _, ok, _ := cache.Get(ctx, "a"); !ok {
+		t.Fatal("запись a не найдена")
+	}
+	cache.Set(ctx, "c", CacheEntry{NotFound: true}, 0)
+
+	if cache.Len() != 2 {
+		t.Errorf("записей в кэше %d, ожидалось 2", cache.Len())
+	}
+	for key, want := range map[string]bool{"a": true, "b": false, "c": true} {
+		if _, ok, _ := cache.Get(ctx, key); ok != want {
+			t.Errorf("запись %s в кэше: %v, ожидалось %v", key, ok, want)
+		}
+	}
+}
+
+func TestMemoryCacheExpiresEntries(t *testing.T) {
+	ctx := context.Background()
+	cache, clock := newTestMemoryCache(10)
+
+	cache.Set(ctx, "short", CacheEntry{NotFound: true}, time.Minute)
+	cache.Set(ctx, "forever", CacheEntry{NotFound: true}, 0)
+
+	clock.Advance(59 * time.Second)
+	if _, ok, _ := cache.Get(ctx, "short"); !ok {
+		t.Error("запись истекла раньше TTL")
+	}
+	clock.Advance(2 * time.Second)
+	if _, ok, _ := cache.Get(ctx, "short"); ok {
+		t.Error("запись не истекла после TTL")
+	}
+	if _, ok, _ := cache.Get(ctx, "forever"); !ok {
+		t.Error("запись без TTL истекла")
+	}
+	if cache.Len() != 1 {
+		t.Errorf("истекшая запись не удалена: записей %d", cache.Len())
+	}
+}
+
+func TestMemoryCacheReturnsCopies(t *testing.T) {
+	ctx := context.Background()
+	cache, _ := newTestMemoryCache(10)
+
+	product := &models.Product{Barcode: "4600000000015", Name: "Кефир"}
+	cache.Set(ctx, product.Barcode, CacheEntry{Product: product}, 0)
+	product.Name = "Изменен после записи"
+
+	entry, _, _ := cache.Get(ctx, "4600000000015")
+	entry.Product.Name = "Изменен после чтения"
+	if entry, _, _ := cache.Get(ctx, "4600000000015"); entry.Product.Name != "Кефир" {
+		t.Errorf("закэшированный продукт изменился: %q", entry.Product.Name)
+	}
+}
+
+func TestBarcodeServiceCachesNotFound(t *testing.T) {
+	ctx := context.Background()
+	cache, clock := newTestMemoryCache(10)
+	source := &countingSource{err: ErrProductNotFound}
+	service := NewBarcodeService(source, cache, time.Hour, time.Minute)
+
+	for range 3 {
+		if _, err := service.GetProductByBarcode(ctx, "4600000000015"); !errors.Is(err, ErrProductNotFound) {
+			t.Fatalf("ошибка %v, ожидалась ErrProductNotFound", err)
+		}
+	}
+	if calls := source.calls.Load(); calls != 1 {
+		t.Errorf("обращений к базе %d, ответ \"не найден\" должен браться из кэша", calls)
+	}
+
+	// После notFoundTTL продукт ищется заново: его могли добавить в базу
+	clock.Advance(2 * time.Minute)
+	service.GetProductByBarcode(ctx, "4600000000015")
+	if calls := source.calls.Load(); calls != 2 {
+		t.Errorf("обращений к базе %d, после TTL ожидалось 2", calls)
+	}
+}
+
+func TestBarcodeServiceDoesNotCacheSourceErrors(t *testing.T) {
+	ctx := context.Background()
+	cache, _ := newTestMemoryCache(10)
+	source := &countingSource{err: ErrUpstreamUnavailable}
+	service := NewBarcodeService(source, cache, time.Hour, time.Minute)
+
+	for range 2 {
+		if _, err := service.GetProductByBarcode(ctx, "4600000000015"); !errors.Is(err, ErrUpstreamUnavailable) {
+			t.Fatalf("ошибка %v, ожидалась ErrUpstreamUnavailable", err)
+		}
+	}
+	if calls := source.calls.Load(); calls != 2 {
+		t.Errorf("обращений к базе %d, сбой базы не должен кэшироваться", calls)
+	}
+}
+
+func TestBarcodeServiceDeduplicatesConcurrentLookups(t *testing.T) {
+	ctx := context.Background()
+	source := &countingSource{started: make(chan struct{}), release: make(chan struct{})}
+	service := NewBarcodeService(source, NewMemoryCache(10), time.Hour, time.Minute)
+
+	const callers = 10
+	var wg sync.WaitGroup
+	products := make([]*models.Product, callers)
+	for i := range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			product, err := service.GetProductByBarcode(ctx, "4600000000015")
+			if err != nil {
+				t.Errorf("ошибка поиска: %v", err)
+			}
+			products[i] = product
+		}()
+	}
+	<-source.started
+	close(source.release)
+	wg.Wait()
+
+	if calls := source.calls.Load(); calls != 1 {
+		t.Errorf("обращений к базе %d, одновременные запросы должны объединяться", calls)
+	}
+	for i := 1; i < callers; i++ {
+		if products[i] == nil || products[i] == products[0] {
+			t.Fatalf("вызов %d получил %p, ожидалась отдельная копия продукта", i, products[i])
+		}
+	}
+}
+
+func TestRedisCache(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	cache := NewRedisCacheFromClient(redis.NewClient(&redis.Options{Addr: server.Addr()}))
+	defer cache.Close()
+
+	if _, ok, err := cache.Get(ctx, "4600000000015"); ok || err != nil {
+		t.Fatalf("пустой кэш: ok=%v, err=%v", ok, err)
+	}
+
+	product := &models.Product{Barcode: "4600000000015", Name: "Кефир"}
+	if err := cache.Set(ctx, product.Barcode, CacheEntry{Product: product}, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Set(ctx, "4600000000022", CacheEntry{NotFound: true}, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if !server.Exists(redisKeyPrefix + product.Barcode) {
+		t.Errorf("ключ %s не найден в Redis", redisKeyPrefix+product.Barcode)
+	}
+
+	entry, ok, err := cache.Get(ctx, product.Barcode)
+	if !ok || err != nil || entry.Product == nil || entry.Product.Name != "Кефир" {
+		t.Errorf("Get = %+v, %v, %v", entry, ok, err)
+	}
+	entry, ok, _ = cache.Get(ctx, "4600000000022")
+	if !ok || !entry.NotFound || entry.Product != nil {
+		t.Errorf("отрицательная запись: %+v, %v", entry, ok)
+	}
+
+	server.FastForward(2 * time.Minute)
+	if _, ok, _ := cache.Get(ctx, "4600000000022"); ok {
+		t.Error("отрицательная запись не истекла после TTL")
+	}
+	if _, ok, _ := cache.Get(ctx, product.Barcode); !ok {
+		t.Error("продукт истек раньше TTL")
+	}
+
+	server.Set(redisKeyPrefix+"broken", "{")
+	if _, _, err := cache.Get(ctx, "broken"); err == nil {
+		t.Error("поврежденная запись прочитана без ошибки")
+	}
+}
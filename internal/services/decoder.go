@@ -0,0 +1,130 @@
+// services/decoder.go
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// ErrBarcodeNotFound - ни один декодер не нашел штрих-код на фото
+var ErrBarcodeNotFound = errors.New("штрих-код на изображении не найден")
+
+// BarcodeDecoder находит штрих-код на фото
+type BarcodeDecoder interface {
+	// Name - короткое имя для логов и конфигурации: gozxing, vision
+	Name() string
+	Decode(ctx context.Context, imageData []byte) (string, error)
+}
+
+// MultiBarcodeDecoder находит все штрих-коды на фото, например на снимке полки
+type MultiBarcodeDecoder interface {
+	DetectAll(ctx context.Context, imageData []byte) ([]string, error)
+}
+
+// DecoderStep - декодер в цепочке и время, которое ему дается
+type DecoderStep struct {
+	Decoder BarcodeDecoder
+	Timeout time.Duration // 0 - без ограничения, кроме контекста запроса
+}
+
+// CompositeDecoder пробует декодеры по очереди до первого успеха:
+// сначала бесплатный локальный, затем платные облачные
+type CompositeDecoder struct {
+	steps []DecoderStep
+}
+
+func NewCompositeDecoder(steps ...DecoderStep) *CompositeDecoder {
+	return &CompositeDecoder{steps: steps}
+}
+
+func (c *CompositeDecoder) Name() string {
+	return "chain"
+}
+
+func (c *CompositeDecoder) Decode(ctx context.Context, imageData []byte) (string, error) {
+	var errs []error
+	for _, step := range c.steps {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+
+		barcode, err := decodeWithTimeout(ctx, step, imageData)
+		if err == nil {
+			log.Printf("Штрих-код распознан декодером %s", step.Decoder.Name())
+			return barcode, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", step.Decoder.Name(), err))
+	}
+	return "", fmt.Errorf("%w: %w", ErrBarcodeNotFound, errors.Join(errs...))
+}
+
+// DetectAll ищет все штрих-коды первым декодером, который это умеет и нашел
+// хоть что-то; декодеры только с одним кодом дают список из одного элемента
+func (c *CompositeDecoder) DetectAll(ctx context.Context, imageData []byte) ([]string, error) {
+	var errs []error
+	for _, step := range c.steps {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		barcodes, err := withTimeout(ctx, step.Timeout, func(ctx context.Context) ([]string, error) {
+			if multi, ok := step.Decoder.(MultiBarcodeDecoder); ok {
+				return multi.DetectAll(ctx, imageData)
+			}
+			barcode, err := step.Decoder.Decode(ctx, imageData)
+			if err != nil {
+				return nil, err
+			}
+			return []string{barcode}, nil
+		})
+		if err == nil && len(barcodes) > 0 {
+			log.Printf("Штрих-коды (%d) распознаны декодером %s", len(barcodes), step.Decoder.Name())
+			return barcodes, nil
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", step.Decoder.Name(), err))
+		}
+	}
+	return nil, fmt.Errorf("%w: %w", ErrBarcodeNotFound, errors.Join(errs...))
+}
+
+func decodeWithTimeout(ctx context.Context, step DecoderStep, imageData []byte) (string, error) {
+	return withTimeout(ctx, step.Timeout, func(ctx context.Context) (string, error) {
+		return step.Decoder.Decode(ctx, imageData)
+	})
+}
+
+// withTimeout не ждет декодер дольше его таймаута. Локальное распознавание
+// проверяет ctx только между проходами, а один проход по большому фото
+// бывает долгим, поэтому декодер запускается в горутине, результат которой
+// после таймаута просто отбрасывается. Сама горутина при этом не
+// останавливается: она дорабатывает текущий проход, видит отмененный ctx
+// и только тогда завершается, так что процессор еще какое-то время занят.
+func withTimeout[T any](ctx context.Context, timeout time.Duration, decode func(context.Context) (T, error)) (T, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	type decoded struct {
+		value T
+		err   error
+	}
+	done := make(chan decoded, 1)
+	go func() {
+		value, err := decode(ctx)
+		done <- decoded{value, err}
+	}()
+
+	select {
+	case result := <-done:
+		return result.value, result.err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
@@ -0,0 +1,262 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var errNoCode = errors.New("нет кода")
+
+// stubDecoder возвращает заданный результат; delay имитирует долгий проход,
+// который не проверяет ctx
+type stubDecoder struct {
+	name  string
+	codes []string
+	err   error
+	delay time.Duration
+	calls atomic.Int32
+}
+
+func (d *stubDecoder) Name() string { return d.name }
+
+func (d *stubDecoder) Decode(ctx context.Context, imageData []byte) (string, error) {
+	codes, err := d.detect()
+	if err != nil {
+		return "", err
+	}
+	if len(codes) == 0 {
+		return "", errNoCode
+	}
+	return codes[0], nil
+}
+
+func (d *stubDecoder) detect() ([]string, error) {
+	d.calls.Add(1)
+	time.Sleep(d.delay)
+	return d.codes, d.err
+}
+
+// stubMultiDecoder умеет находить несколько кодов
+type stubMultiDecoder struct {
+	stubDecoder
+}
+
+func (d *stubMultiDecoder) DetectAll(ctx context.Context, imageData []byte) ([]string, error) {
+	return d.detect()
+}
+
+func TestCompositeDecoderDecode(t *testing.T) {
+	const slow = time.Second
+	tests := []struct {
+		name    string
+		steps   func() []DecoderStep
+		want    string
+		wantErr []error
+	}{
+		{
+			name: "первый успешный",
+			steps: func() []DecoderStep {
+				return []DecoderStep{
+					{Decoder: &stubDecoder{name: "a", codes: []string{"4607001771234"}}},
+					{Decoder: &stubDecoder{name: "b", err: errors.New("не должен вызываться")}},
+				}
+			},
+			want: "4607001771234",
+		},
+		{
+			name: "запасной после ошибки",
+			steps: func() []DecoderStep {
+				return []DecoderStep{
+					{Decoder: &stubDecoder{name: "a", err: errNoCode}},
+					{Decoder: &stubDecoder{name: "b", codes: []string{"4600000000015"}}},
+				}
+			},
+			want: "4600000000015",
+		},
+		{
+			name: "запасной после таймаута шага",
+			steps: func() []DecoderStep {
+				return []DecoderStep{
+					{Decoder: &stubDecoder{name: "a", codes: []string{"1"}, delay: slow}, Timeout: 20 * time.Millisecond},
+					{Decoder: &stubDecoder{name: "b", codes: []string{"4600000000015"}}, Timeout: time.Second},
+				}
+			},
+			want: "4600000000015",
+		},
+		{
+			name: "все декодеры не справились",
+			steps: func() []DecoderStep {
+				return []DecoderStep{
+					{Decoder: &stubDecoder{name: "a", err: errNoCode}},
+					{Decoder: &stubDecoder{name: "b", delay: slow}, Timeout: 20 * time.Millisecond},
+				}
+			},
+			wantErr: []error{ErrBarcodeNotFound, errNoCode, context.DeadlineExceeded},
+		},
+		{
+			name:    "пустая цепочка",
+			steps:   func() []DecoderStep { return nil },
+			wantErr: []error{ErrBarcodeNotFound},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			got, err := NewCompositeDecoder(tt.steps()...).Decode(context.Background(), nil)
+			if elapsed := time.Since(start); elapsed >= slow {
+				t.Errorf("цепочка ждала медленный декодер %v", elapsed)
+			}
+			if got != tt.want {
+				t.Errorf("код %q, ожидался %q", got, tt.want)
+			}
+			for _, want := range tt.wantErr {
+				if !errors.Is(err, want) {
+					t.Errorf("ошибка %v не содержит %v", err, want)
+				}
+			}
+			if tt.wantErr == nil && err != nil {
+				t.Errorf("ошибка %v", err)
+			}
+		})
+	}
+}
+
+func TestCompositeDecoderStopsOnCanceledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	decoder := &stubDecoder{name: "a", codes: []string{"1"}}
+	chain := NewCompositeDecoder(DecoderStep{Decoder: decoder})
+	if _, err := chain.Decode(ctx, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("Decode: ошибка %v, ожидалась context.Canceled", err)
+	}
+	if _, err := chain.DetectAll(ctx, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("DetectAll: ошибка %v, ожидалась context.Canceled", err)
+	}
+	if calls := decoder.calls.Load(); calls != 0 {
+		t.Errorf("при отмененном контексте декодер вызван %d раз", calls)
+	}
+}
+
+func TestCompositeDecoderDetectAll(t *testing.T) {
+	tests := []struct {
+		name    string
+		steps   []DecoderStep
+		want    []string
+		wantErr error
+	}{
+		{
+			name: "несколько кодов",
+			steps: []DecoderStep{
+				{Decoder: &stubMultiDecoder{stubDecoder{name: "multi", codes: []string{"1", "2"}}}},
+			},
+			want: []string{"1", "2"},
+		},
+		{
+			name: "декодер одного кода дает список из одного",
+			steps: []DecoderStep{
+				{Decoder: &stubDecoder{name: "single", codes: []string{"1", "2"}}},
+			},
+			want: []string{"1"},
+		},
+		{
+			name: "пустой результат - следующий декодер",
+			steps: []DecoderStep{
+				{Decoder: &stubMultiDecoder{stubDecoder{name: "multi"}}},
+				{Decoder: &stubDecoder{name: "single", codes: []string{"3"}}},
+			},
+			want: []string{"3"},
+		},
+		{
+			name: "таймаут шага - следующий декодер",
+			steps: []DecoderStep{
+				{Decoder: &stubMultiDecoder{stubDecoder{name: "multi", codes: []string{"1"}, delay: time.Second}}, Timeout: 20 * time.Millisecond},
+				{Decoder: &stubMultiDecoder{stubDecoder{name: "multi2", codes: []string{"2"}}}},
+			},
+			want: []string{"2"},
+		},
+		{
+			name: "ничего не найдено",
+			steps: []DecoderStep{
+				{Decoder: &stubMultiDecoder{stubDecoder{name: "multi"}}},
+				{Decoder: &stubDecoder{name: "single", err: errNoCode}},
+			},
+			wantErr: errNoCode,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewCompositeDecoder(tt.steps...).DetectAll(context.Background(), nil)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("коды %q, ожидались %q", got, tt.want)
+			}
+			if tt.wantErr != nil && (!errors.Is(err, ErrBarcodeNotFound) || !errors.Is(err, tt.wantErr)) {
+				t.Errorf("ошибка %v, ожидалась %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && err != nil {
+				t.Errorf("ошибка %v", err)
+			}
+		})
+	}
+}
+
+func TestWithTimeout(t *testing.T) {
+	t.Run("без таймаута", func(t *testing.T) {
+		got, err := withTimeout(context.Background(), 0, func(ctx context.Context) (int, error) {
+			if _, ok := ctx.Deadline(); ok {
+				t.Error("при нулевом таймауте у контекста есть срок")
+			}
+			return 42, nil
+		})
+		if got != 42 || err != nil {
+			t.Errorf("withTimeout = %d, %v", got, err)
+		}
+	})
+
+	t.Run("декодер видит отмену", func(t *testing.T) {
+		finished := make(chan error, 1)
+		_, err := withTimeout(context.Background(), 20*time.Millisecond, func(ctx context.Context) (int, error) {
+			<-ctx.Done()
+			finished <- ctx.Err()
+			return 0, ctx.Err()
+		})
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("ошибка %v, ожидалась DeadlineExceeded", err)
+		}
+		if err := <-finished; !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("декодер получил %v", err)
+		}
+	})
+
+	t.Run("не ждет декодер после таймаута", func(t *testing.T) {
+		release := make(chan struct{})
+		defer close(release)
+		start := time.Now()
+		got, err := withTimeout(context.Background(), 20*time.Millisecond, func(ctx context.Context) (string, error) {
+			// Долгий проход, не проверяющий ctx: горутина доработает после возврата
+			<-release
+			return "поздно", nil
+		})
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("withTimeout ждал декодер %v", elapsed)
+		}
+		if got != "" || !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("withTimeout = %q, %v; ожидался таймаут", got, err)
+		}
+	})
+
+	t.Run("отмена родительского контекста", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, err := withTimeout(ctx, time.Minute, func(ctx context.Context) (int, error) {
+			<-ctx.Done()
+			return 0, ctx.Err()
+		}); !errors.Is(err, context.Canceled) {
+			t.Errorf("ошибка %v, ожидалась Canceled", err)
+		}
+	})
+}
@@ -1,16 +1,22 @@
-// services/barcode_detector_simple.go
+// services/gozxing_detector.go
 package services
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"image"
 	_ "image/jpeg"
 	_ "image/png"
-	"regexp"
+	"log"
+	"slices"
+
+	"github.com/ajeanett/telbot/internal/barcode"
 
 	"github.com/makiuchi-d/gozxing"
+	"github.com/makiuchi-d/gozxing/datamatrix"
 	"github.com/makiuchi-d/gozxing/oned"
+	"github.com/makiuchi-d/gozxing/qrcode"
 )
 
 type BarcodeDetector struct{}
@@ -19,59 +25,218 @@ func NewBarcodeDetector() *BarcodeDetector {
 	return &BarcodeDetector{}
 }
 
-func (d *BarcodeDetector) DetectFromImage(imageData []byte) (string, error) {
+func (d *BarcodeDetector) Name() string {
+	return "gozxing"
+}
+
+// Decode распознает штрих-код локально (BarcodeDecoder). Распознавание
+// прерывается по ctx между проходами предобработки.
+func (d *BarcodeDetector) Decode(ctx context.Context, imageData []byte) (string, error) {
+	return d.DetectFromImage(ctx, imageData)
+}
+
+func (d *BarcodeDetector) DetectFromImage(ctx context.Context, imageData []byte) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
 	// Декодируем изображение
 	img, _, err := image.Decode(bytes.NewReader(imageData))
 	if err != nil {
 		return "", fmt.Errorf("не удалось декодировать изображение: %w", err)
 	}
 
-	// Конвертируем для gozxing
-	bmp, err := gozxing.NewBinaryBitmapFromImage(img)
+	barcode, strategy, err := d.DecodeImage(ctx, img)
 	if err != nil {
-		return "", fmt.Errorf("ошибка создания bitmap: %w", err)
+		return "", err
 	}
-
-	// Пробуем разные настройки декодера
-	hints := map[gozxing.DecodeHintType]interface{}{
-		gozxing.DecodeHintType_TRY_HARDER: true,
-		gozxing.DecodeHintType_POSSIBLE_FORMATS: []gozxing.BarcodeFormat{
-			gozxing.BarcodeFormat_EAN_13,
-			gozxing.BarcodeFormat_EAN_8,
-			gozxing.BarcodeFormat_UPC_A,
-			gozxing.BarcodeFormat_UPC_E,
-			gozxing.BarcodeFormat_CODE_128,
-			gozxing.BarcodeFormat_CODE_39,
-		},
+	if strategy != StrategyOriginal {
+		log.Printf("Штрих-код распознан после предобработки: %s", strategy)
 	}
+	return barcode, nil
+}
 
-	// Используем стандартный мультиформатный ридер
-	reader := oned.NewMultiFormatUPCEANReader(hints)
+// Настройки декодера: форматы штрих-кодов на продуктах. ASSUME_GS1
+// сохраняет разделители полей в GS1-128, без них не разобрать партию и серию.
+var decodeHints = map[gozxing.DecodeHintType]interface{}{
+	gozxing.DecodeHintType_TRY_HARDER: true,
+	gozxing.DecodeHintType_ASSUME_GS1: true,
+	gozxing.DecodeHintType_POSSIBLE_FORMATS: []gozxing.BarcodeFormat{
+		gozxing.BarcodeFormat_EAN_13,
+		gozxing.BarcodeFormat_EAN_8,
+		gozxing.BarcodeFormat_UPC_A,
+		gozxing.BarcodeFormat_UPC_E,
+		gozxing.BarcodeFormat_CODE_128,
+		gozxing.BarcodeFormat_DATA_MATRIX,
+		gozxing.BarcodeFormat_QR_CODE,
+	},
+}
 
-	// Пытаемся распознать
-	result, err := reader.Decode(bmp, nil)
-	if err != nil {
-		return "", fmt.Errorf("не удалось распознать штрих-код: %w", err)
+// productReaders - декодеры всех форматов из decodeHints. Коды маркировки
+// (DataMatrix "Честного ЗНАКа", QR) идут первыми: кроме GTIN в них есть срок
+// годности и партия. Декодеры хранят состояние, поэтому создаются на каждое фото.
+func productReaders() []gozxing.Reader {
+	return []gozxing.Reader{
+		datamatrix.NewDataMatrixReader(),
+		qrcode.NewQRCodeReader(),
+		oned.NewMultiFormatUPCEANReader(decodeHints),
+		oned.NewCode128Reader(),
+	}
+}
+
+// readProductCode пробует все декодеры; пустая строка - кода нет.
+// Штрих-код EAN/UPC возвращается в виде для поиска продукта, код
+// маркировки - целиком, GTIN и остальные поля из него достает barcode.ParseGS1.
+// short - код из восьми штрихов (EAN-8, UPC-E), такие декодер иногда
+// находит внутри обрезанного или повернутого EAN-13.
+func readProductCode(readers []gozxing.Reader, bmp *gozxing.BinaryBitmap) (code string, short bool) {
+	for _, reader := range readers {
+		result, err := reader.Decode(bmp, decodeHints)
+		if err != nil {
+			continue
+		}
+		text := result.GetText()
+		switch result.GetBarcodeFormat() {
+		case gozxing.BarcodeFormat_UPC_E:
+			// Восьмизначный UPC-E неотличим от EAN-8, формат известен только здесь
+			if upca, err := barcode.ExpandUPCE(text); err == nil {
+				return "0" + upca, true
+			}
+		case gozxing.BarcodeFormat_EAN_13, gozxing.BarcodeFormat_EAN_8, gozxing.BarcodeFormat_UPC_A:
+			if code, err := barcode.Normalize(text); err == nil {
+				return code.LookupCode(), code.Format == barcode.EAN8
+			}
+		default:
+			// В QR чаще ссылка на сайт, а в Code 128 - внутренний номер склада
+			if _, err := barcode.ParseGS1(text); err == nil {
+				return text, false
+			}
+		}
 	}
+	return "", false
+}
+
+// Бинаризаторы: гибридный справляется с неравномерным освещением,
+// глобальная гистограмма - с мелкими штрих-кодами на однородном фоне
+var binarizers = []struct {
+	name string
+	new  func(gozxing.LuminanceSource) gozxing.Binarizer
+}{
+	{"hybrid", gozxing.NewHybridBinarizer},
+	{"histogram", gozxing.NewGlobalHistgramBinarizer},
+}
 
-	barcode := result.GetText()
+// Стратегия без предобработки; так распознается большинство фото
+const StrategyOriginal = "original/hybrid"
 
-	// Проверяем валидность
-	if !isValidBarcode(barcode) {
-		return "", fmt.Errorf("невалидный штрих-код: %s", barcode)
+// DecodeImage перебирает способы предобработки и бинаризаторы до первого
+// успешного распознавания. strategy - какой способ сработал, например "rotate90/hybrid".
+func (d *BarcodeDetector) DecodeImage(ctx context.Context, img image.Image) (barcode, strategy string, err error) {
+	gray := toGray(img)
+	readers := productReaders()
+
+	// Повернутый или обрезанный EAN-13 иногда читается как короткий EAN-8
+	// по части штрихов, поэтому короткий код после предобработки - только
+	// запасной вариант, пока не найдется полный
+	var fallback, fallbackStrategy string
+	for _, step := range preprocessSteps {
+		if err := ctx.Err(); err != nil {
+			return "", "", err
+		}
+		variant := step.apply(gray)
+		if variant == nil {
+			continue
+		}
+
+		source := gozxing.NewLuminanceSourceFromImage(variant)
+		for _, binarizer := range binarizers {
+			if err := ctx.Err(); err != nil {
+				return "", "", err
+			}
+			bmp, err := gozxing.NewBinaryBitmap(binarizer.new(source))
+			if err != nil {
+				continue
+			}
+			barcode, short := readProductCode(readers, bmp)
+			if barcode == "" {
+				continue
+			}
+			strategy := step.name + "/" + binarizer.name
+			if !short || strategy == StrategyOriginal {
+				return barcode, strategy, nil
+			}
+			if fallback == "" {
+				fallback, fallbackStrategy = barcode, strategy
+			}
+		}
 	}
+	if fallback != "" {
+		return fallback, fallbackStrategy, nil
+	}
+	return "", "", fmt.Errorf("не удалось распознать штрих-код")
+}
 
-	return barcode, nil
+// Больше штрих-кодов с одного фото не ищем: сводка перестает быть читаемой
+const maxBarcodesPerImage = 10
+
+// DetectAll находит все штрих-коды на фото: полке, нескольких продуктах рядом
+func (d *BarcodeDetector) DetectAll(ctx context.Context, imageData []byte) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(bytes.NewReader(imageData))
+	if err != nil {
+		return nil, fmt.Errorf("не удалось декодировать изображение: %w", err)
+	}
+	return d.DecodeAll(ctx, img)
 }
 
-func isValidBarcode(barcode string) bool {
-	// Убираем все нецифровые символы
-	clean := regexp.MustCompile(`\D`).ReplaceAllString(barcode, "")
+// DecodeAll ищет штрих-коды по частям изображения: декодер находит только
+// один код за попытку, поэтому фото режется на перекрывающиеся фрагменты.
+// Если так ничего не нашлось, фото разбирается целиком с предобработкой.
+func (d *BarcodeDetector) DecodeAll(ctx context.Context, img image.Image) ([]string, error) {
+	gray := toGray(img)
+	readers := productReaders()
+
+	var found []string
+	for _, tile := range imageTiles(gray) {
+		for _, variant := range []*image.Gray{tile.img, stretchContrast(tile.img)} {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			barcode, short := decodeVariant(readers, variant)
+			// Разрезанный EAN-13 читается как EAN-8 по половине штрихов,
+			// поэтому короткие коды принимаем только с целого фото
+			if barcode == "" || short && !tile.whole || slices.Contains(found, barcode) {
+				continue
+			}
+			found = append(found, barcode)
+			if len(found) == maxBarcodesPerImage {
+				return found, nil
+			}
+		}
+	}
+	if len(found) > 0 {
+		return found, nil
+	}
 
-	// Проверяем длину и что остались только цифры
-	if len(clean) < 8 || len(clean) > 13 {
-		return false
+	barcode, _, err := d.DecodeImage(ctx, gray)
+	if err != nil {
+		return nil, err
 	}
+	return []string{barcode}, nil
+}
 
-	return regexp.MustCompile(`^\d+$`).MatchString(clean)
+// decodeVariant пробует оба бинаризатора; пустая строка - штрих-кода нет
+func decodeVariant(readers []gozxing.Reader, img *image.Gray) (code string, short bool) {
+	source := gozxing.NewLuminanceSourceFromImage(img)
+	for _, binarizer := range binarizers {
+		bmp, err := gozxing.NewBinaryBitmap(binarizer.new(source))
+		if err != nil {
+			continue
+		}
+		if code, short := readProductCode(readers, bmp); code != "" {
+			return code, short
+		}
+	}
+	return "", false
 }
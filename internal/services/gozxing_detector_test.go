@@ -0,0 +1,75 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"image"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestBarcodeDetectorStopsOnCanceledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	img := image.NewGray(image.Rect(0, 0, 64, 64))
+	detector := NewBarcodeDetector()
+	if _, _, err := detector.DecodeImage(ctx, img); !errors.Is(err, context.Canceled) {
+		t.Errorf("DecodeImage: ошибка %v, ожидалась context.Canceled", err)
+	}
+	if _, err := detector.DecodeAll(ctx, img); !errors.Is(err, context.Canceled) {
+		t.Errorf("DecodeAll: ошибка %v, ожидалась context.Canceled", err)
+	}
+	if _, err := detector.Decode(ctx, []byte("not an image")); !errors.Is(err, context.Canceled) {
+		t.Errorf("Decode: ошибка %v, ожидалась context.Canceled", err)
+	}
+}
+
+// benchPhoto - фото из набора cmd/barcodebench
+func benchPhoto(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("..", "..", "cmd", "barcodebench", "testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestBarcodeDetectorDetectAll(t *testing.T) {
+	tests := []struct {
+		photo string
+		want  []string
+	}{
+		{"4600000000015+5449000000996_shelf.jpg", []string{"4600000000015", "5449000000996"}},
+		{"4600000000015+5449000000996_shelfskew.jpg", []string{"4600000000015", "5449000000996"}},
+		{"4600000000015_clean.png", []string{"4600000000015"}},
+		// Фрагменты ничего не дают, код находит предобработка целого фото
+		{"5449000000996_rotate90.jpg", []string{"5449000000996"}},
+	}
+	detector := NewBarcodeDetector()
+	for _, tt := range tests {
+		t.Run(tt.photo, func(t *testing.T) {
+			got, err := detector.DetectAll(context.Background(), benchPhoto(t, tt.photo))
+			if err != nil {
+				t.Fatal(err)
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("найдены %q, ожидались %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBarcodeDetectorDecodeShelf(t *testing.T) {
+	// Без разрезания на фрагменты с полки читается один полный код
+	code, err := NewBarcodeDetector().Decode(context.Background(), benchPhoto(t, "4600000000015+5449000000996_shelf.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != "4600000000015" && code != "5449000000996" {
+		t.Errorf("с фото полки распознан %q", code)
+	}
+}
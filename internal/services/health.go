@@ -1,24 +1,65 @@
 package services
 
 import (
-    "net/http"
+	"context"
+	"errors"
+	"expvar"
 	"log"
-	"os"
+	"net/http"
 )
 
-func StartHealthServer() {
-    go func() {
-        http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-            w.Write([]byte("🤖 Bot is alive!"))
-        })
-        
-        // Replit использует порт из env переменной
-        port := os.Getenv("PORT")
-        if port == "" {
-            port = "8080"
-        }
-        
-        log.Printf("Health server started on :%s", port)
-        http.ListenAndServe(":"+port, nil)
-    }()
-}
\ No newline at end of file
+// HealthServer - HTTP-сервер для проверки живости. На нем же в режиме
+// вебхука принимаются обновления от Telegram.
+type HealthServer struct {
+	name   string
+	mux    *http.ServeMux
+	server *http.Server
+}
+
+// NewHealthServer создает сервер на порту port (Replit передает его в $PORT)
+func NewHealthServer(port string) *HealthServer {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("🤖 Bot is alive!"))
+	})
+
+	return &HealthServer{
+		name:   "Health server",
+		mux:    mux,
+		server: &http.Server{Addr: ":" + port, Handler: mux},
+	}
+}
+
+// NewAdminServer создает сервер метрик expvar (/debug/vars) на адресе addr.
+// Метрики раскрывают внутреннее состояние бота, поэтому они не отдаются
+// на публичном порту вебхука, а addr обычно слушает только localhost.
+func NewAdminServer(addr string) *HealthServer {
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+
+	return &HealthServer{
+		name:   "Admin server",
+		mux:    mux,
+		server: &http.Server{Addr: addr, Handler: mux},
+	}
+}
+
+// Handle добавляет обработчик; можно вызывать и после Start
+func (s *HealthServer) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// Start запускает сервер в фоне
+func (s *HealthServer) Start() {
+	go func() {
+		log.Printf("%s started on %s", s.name, s.server.Addr)
+		if err := s.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("❌ %s остановлен с ошибкой: %v", s.name, err)
+		}
+	}()
+}
+
+// Shutdown останавливает сервер, дожидаясь текущих запросов
+func (s *HealthServer) Shutdown(ctx context.Context) error {
+	return s.server.Shutdown(ctx)
+}
@@ -0,0 +1,26 @@
+package services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMetricsOnlyOnAdminServer(t *testing.T) {
+	tests := []struct {
+		name    string
+		server  *HealthServer
+		metrics bool
+	}{
+		{"health", NewHealthServer("0"), false},
+		{"admin", NewAdminServer("127.0.0.1:0"), true},
+	}
+	for _, tt := range tests {
+		recorder := httptest.NewRecorder()
+		tt.server.mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/debug/vars", nil))
+		served := recorder.Header().Get("Content-Type") == "application/json; charset=utf-8"
+		if served != tt.metrics {
+			t.Errorf("%s: /debug/vars отдает метрики: %v, ожидалось %v", tt.name, served, tt.metrics)
+		}
+	}
+}
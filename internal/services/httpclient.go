@@ -0,0 +1,150 @@
+// services/httpclient.go
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var (
+	// ErrUpstreamUnavailable - внешний API не отвечает или возвращает 5xx
+	ErrUpstreamUnavailable = errors.New("внешний сервис недоступен")
+	// ErrRateLimited - внешний API ограничил частоту запросов (429)
+	ErrRateLimited = errors.New("превышен лимит запросов к внешнему сервису")
+)
+
+// RetryPolicy - параметры повторных запросов к внешним API
+type RetryPolicy struct {
+	MaxAttempts int           // всего попыток, включая первую
+	BaseDelay   time.Duration // задержка перед второй попыткой
+	MaxDelay    time.Duration // потолок задержки, в том числе для Retry-After
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    10 * time.Second,
+}
+
+// NewHTTPClient создает клиент с таймаутом, который подставляет User-Agent
+// во все запросы (Open Food Facts просит представляться)
+func NewHTTPClient(timeout time.Duration, userAgent string) *http.Client {
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &userAgentTransport{
+			base:      http.DefaultTransport,
+			userAgent: userAgent,
+		},
+	}
+}
+
+type userAgentTransport struct {
+	base      http.RoundTripper
+	userAgent string
+}
+
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.userAgent != "" && req.Header.Get("User-Agent") == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("User-Agent", t.userAgent)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// getWithRetry выполняет GET-запрос, повторяя его при сетевых ошибках, 5xx и 429.
+// Успешным считается любой ответ кроме 5xx и 429 - его разбор остается вызывающему коду.
+func getWithRetry(ctx context.Context, client *http.Client, policy RetryPolicy, url string) (*http.Response, error) {
+	attempts := max(policy.MaxAttempts, 1)
+
+	var lastErr error
+	for attempt := 1; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка создания запроса: %w", err)
+		}
+
+		var wait time.Duration
+		resp, err := client.Do(req)
+		switch {
+		case err != nil:
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			lastErr = fmt.Errorf("%w: %v", ErrUpstreamUnavailable, err)
+		case resp.StatusCode == http.StatusTooManyRequests:
+			wait = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+			discardBody(resp)
+			lastErr = ErrRateLimited
+			if wait > policy.MaxDelay {
+				// Ждать дольше лимита нет смысла - пользователь столько не ждет
+				return nil, lastErr
+			}
+		case resp.StatusCode >= http.StatusInternalServerError:
+			discardBody(resp)
+			lastErr = fmt.Errorf("%w: статус %d", ErrUpstreamUnavailable, resp.StatusCode)
+		default:
+			return resp, nil
+		}
+
+		if attempt >= attempts {
+			return nil, lastErr
+		}
+		if wait <= 0 {
+			wait = policy.backoff(attempt)
+		}
+
+		if err := sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// sleep ждет d или отмены ctx. В тестах подменяется, чтобы повторы не ждали.
+var sleep = func(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// backoff - экспоненциальная задержка с полным джиттером
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.BaseDelay << (attempt - 1)
+	if delay <= 0 || delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return rand.N(delay) + 1
+}
+
+// parseRetryAfter разбирает заголовок Retry-After: число секунд или HTTP-дату
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(max(seconds, 0)) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(date.Sub(now), 0)
+	}
+	return 0
+}
+
+// discardBody дочитывает и закрывает тело, чтобы соединение вернулось в пул
+func discardBody(resp *http.Response) {
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+}
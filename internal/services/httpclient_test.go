@@ -0,0 +1,188 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// recordSleeps подменяет ожидание между попытками: задержки запоминаются, а не выжидаются
+func recordSleeps(t *testing.T) *[]time.Duration {
+	t.Helper()
+	var delays []time.Duration
+	original := sleep
+	sleep = func(ctx context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return ctx.Err()
+	}
+	t.Cleanup(func() { sleep = original })
+	return &delays
+}
+
+// statusServer отвечает статусами из statuses по очереди, повторяя последний
+func statusServer(t *testing.T, header http.Header, statuses ...int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(requests.Add(1))
+		for key, values := range header {
+			w.Header()[key] = values
+		}
+		w.WriteHeader(statuses[min(n, len(statuses))-1])
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+var testRetry = RetryPolicy{MaxAttempts: 3, BaseDelay: 100 * time.Millisecond, MaxDelay: 5 * time.Second}
+
+func TestGetWithRetry(t *testing.T) {
+	tests := []struct {
+		name       string
+		statuses   []int
+		header     http.Header
+		wantStatus int   // 0 - ожидается ошибка
+		wantErr    error // ожидаемая ошибка
+		requests   int
+		sleeps     int
+	}{
+		{"успех с первой попытки", []int{200}, nil, 200, nil, 1, 0},
+		{"5xx, затем успех", []int{503, 502, 200}, nil, 200, nil, 3, 2},
+		{"5xx на всех попытках", []int{500}, nil, 0, ErrUpstreamUnavailable, 3, 2},
+		{"404 не повторяется", []int{404}, nil, 404, nil, 1, 0},
+		{"400 не повторяется", []int{400, 200}, nil, 400, nil, 1, 0},
+		{"429 без Retry-After", []int{429, 200}, nil, 200, nil, 2, 1},
+		{"429 на всех попытках", []int{429}, nil, 0, ErrRateLimited, 3, 2},
+		{"Retry-After дольше потолка", []int{429, 200}, http.Header{"Retry-After": {"60"}}, 0, ErrRateLimited, 1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delays := recordSleeps(t)
+			server, requests := statusServer(t, tt.header, tt.statuses...)
+
+			resp, err := getWithRetry(context.Background(), server.Client(), testRetry, server.URL)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("ошибка %v, ожидалась %v", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			} else {
+				resp.Body.Close()
+				if resp.StatusCode != tt.wantStatus {
+					t.Errorf("статус %d, ожидался %d", resp.StatusCode, tt.wantStatus)
+				}
+			}
+			if int(requests.Load()) != tt.requests {
+				t.Errorf("запросов %d, ожидалось %d", requests.Load(), tt.requests)
+			}
+			if len(*delays) != tt.sleeps {
+				t.Errorf("пауз %d, ожидалось %d", len(*delays), tt.sleeps)
+			}
+			for _, d := range *delays {
+				if d <= 0 || d > testRetry.MaxDelay {
+					t.Errorf("задержка %v вне (0, %v]", d, testRetry.MaxDelay)
+				}
+			}
+		})
+	}
+}
+
+func TestGetWithRetryHonoursRetryAfter(t *testing.T) {
+	delays := recordSleeps(t)
+	server, _ := statusServer(t, http.Header{"Retry-After": {"2"}}, 429, 200)
+
+	resp, err := getWithRetry(context.Background(), server.Client(), testRetry, server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if len(*delays) != 1 || (*delays)[0] != 2*time.Second {
+		t.Errorf("паузы %v, ожидалась одна на 2s из Retry-After", *delays)
+	}
+}
+
+func TestGetWithRetryNetworkError(t *testing.T) {
+	delays := recordSleeps(t)
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	_, err := getWithRetry(context.Background(), http.DefaultClient, testRetry, url)
+	if !errors.Is(err, ErrUpstreamUnavailable) {
+		t.Errorf("ошибка %v, ожидалась ErrUpstreamUnavailable", err)
+	}
+	if len(*delays) != testRetry.MaxAttempts-1 {
+		t.Errorf("пауз %d, сетевые ошибки должны повторяться", len(*delays))
+	}
+}
+
+func TestGetWithRetryCanceled(t *testing.T) {
+	recordSleeps(t)
+	server, requests := statusServer(t, nil, 503)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := getWithRetry(ctx, server.Client(), testRetry, server.URL); !errors.Is(err, context.Canceled) {
+		t.Errorf("ошибка %v, ожидалась context.Canceled", err)
+	}
+	if requests.Load() != 0 {
+		t.Errorf("с отмененным контекстом отправлено %d запросов", requests.Load())
+	}
+
+	// Отмена во время паузы между попытками прерывает повторы
+	original := sleep
+	t.Cleanup(func() { sleep = original })
+	sleep = func(ctx context.Context, d time.Duration) error { return context.Canceled }
+	if _, err := getWithRetry(context.Background(), server.Client(), testRetry, server.URL); !errors.Is(err, context.Canceled) {
+		t.Errorf("ошибка %v, ожидалась context.Canceled", err)
+	}
+	if requests.Load() != 1 {
+		t.Errorf("после отмены паузы запросов %d, ожидался 1", requests.Load())
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	for attempt, ceiling := range map[int]time.Duration{
+		1:  100 * time.Millisecond,
+		2:  200 * time.Millisecond,
+		3:  400 * time.Millisecond,
+		5:  time.Second,
+		70: time.Second, // сдвиг переполняется
+	} {
+		for range 100 {
+			if d := policy.backoff(attempt); d <= 0 || d > ceiling {
+				t.Fatalf("backoff(%d) = %v, ожидалось в (0, %v]", attempt, d, ceiling)
+			}
+		}
+	}
+	if d := (RetryPolicy{}).backoff(1); d != 0 {
+		t.Errorf("без задержек backoff = %v, ожидался 0", d)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"0", 0},
+		{"5", 5 * time.Second},
+		{"-3", 0},
+		{"Fri, 01 Mar 2024 12:00:30 GMT", 30 * time.Second},
+		{"Fri, 01 Mar 2024 11:00:00 GMT", 0}, // дата в прошлом
+		{"скоро", 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value, now); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %v, ожидалось %v", tt.value, got, tt.want)
+		}
+	}
+}
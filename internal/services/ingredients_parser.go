@@ -0,0 +1,288 @@
+// services/ingredients_parser.go
+package services
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Token - нормализованное слово состава с позицией в исходном тексте
+type Token struct {
+	Text  string // основа слова или код добавки вида e621
+	Start int    // смещение в байтах в исходной строке
+	End   int
+}
+
+// IngredientNode - ингредиент состава с вложенными подингредиентами:
+// "шоколад (сахар, какао-масло)" -> шоколад{сахар, какао-масло}
+type IngredientNode struct {
+	Text     string // исходный текст ингредиента без вложенного списка
+	Start    int
+	End      int
+	Tokens   []Token
+	Children []*IngredientNode
+}
+
+// Кириллические буквы, которые пишутся так же, как латинские
+var cyrillicToLatin = map[rune]rune{
+	'а': 'a', 'в': 'b', 'е': 'e', 'к': 'k', 'м': 'm', 'н': 'h', 'о': 'o',
+	'р': 'p', 'с': 'c', 'т': 't', 'у': 'y', 'х': 'x', 'і': 'i',
+}
+
+var latinToCyrillic = func() map[rune]rune {
+	m := make(map[rune]rune, len(cyrillicToLatin))
+	for cyr, lat := range cyrillicToLatin {
+		m[lat] = cyr
+	}
+	return m
+}()
+
+// ParseIngredients разбирает текст состава в дерево ингредиентов.
+// Ингредиенты разделяются запятыми и точками с запятой, вложенные списки
+// берутся из круглых, квадратных и фигурных скобок.
+func ParseIngredients(text string) []*IngredientNode {
+	nodes, _ := parseIngredientList(text, 0, 0)
+	return nodes
+}
+
+// parseIngredientList разбирает список с позиции pos до закрывающей скобки
+// или конца строки и возвращает позицию, на которой остановился
+func parseIngredientList(text string, pos, depth int) ([]*IngredientNode, int) {
+	var nodes []*IngredientNode
+	current := &IngredientNode{Start: pos}
+	var ownText strings.Builder
+	ownStart := pos
+
+	flush := func(end int) {
+		current.End = end
+		current.Text = strings.TrimSpace(ownText.String())
+		current.Tokens = append(current.Tokens, tokenizeAt(text[ownStart:end], ownStart)...)
+		if current.Text != "" || len(current.Children) > 0 {
+			nodes = append(nodes, current)
+		}
+	}
+
+	for pos < len(text) {
+		r, size := utf8.DecodeRuneInString(text[pos:])
+		switch r {
+		case '(', '[', '{':
+			// Слова до скобки относятся к самому ингредиенту
+			current.Tokens = append(current.Tokens, tokenizeAt(text[ownStart:pos], ownStart)...)
+			children, next := parseIngredientList(text, pos+size, depth+1)
+			current.Children = append(current.Children, children...)
+			pos = next
+			ownStart = pos
+			continue
+		case ')', ']', '}':
+			if depth > 0 {
+				flush(pos)
+				return nodes, pos + size
+			}
+			// Лишняя закрывающая скобка на верхнем уровне - пропускаем
+			pos += size
+			continue
+		case ',', ';':
+			flush(pos)
+			current = &IngredientNode{Start: pos + size}
+			ownText.Reset()
+			ownStart = pos + size
+			pos += size
+			continue
+		}
+		ownText.WriteRune(r)
+		pos += size
+	}
+
+	flush(pos)
+	return nodes, pos
+}
+
+// Walk обходит ингредиент и все вложенные в него
+func (n *IngredientNode) Walk(fn func(node *IngredientNode)) {
+	fn(n)
+	for _, child := range n.Children {
+		child.Walk(fn)
+	}
+}
+
+// Tokenize разбивает текст на нормализованные токены: слова приводятся
+// к основе, E-номера в любом написании ("E 621", "Е-621", "e621") - к виду e621
+func Tokenize(text string) []Token {
+	return tokenizeAt(text, 0)
+}
+
+func tokenizeAt(text string, offset int) []Token {
+	var raw []Token
+	start := -1
+	for i, r := range text {
+		if isWordRune(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			raw = append(raw, Token{Text: text[start:i], Start: offset + start, End: offset + i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		raw = append(raw, Token{Text: text[start:], Start: offset + start, End: offset + len(text)})
+	}
+
+	tokens := make([]Token, 0, len(raw))
+	for i := 0; i < len(raw); i++ {
+		tok := raw[i]
+		word := normalizeWord(tok.Text)
+
+		// "E 621", "E-621": буква E отдельно от номера
+		if isLetterE(word) && i+1 < len(raw) && onlyCodeSeparators(text[tok.End-offset:raw[i+1].Start-offset]) {
+			if code, ok := additiveCode("e" + normalizeWord(raw[i+1].Text)); ok {
+				tokens = append(tokens, Token{Text: code, Start: tok.Start, End: raw[i+1].End})
+				i++
+				continue
+			}
+		}
+		if code, ok := additiveCode(word); ok {
+			tokens = append(tokens, Token{Text: code, Start: tok.Start, End: tok.End})
+			continue
+		}
+
+		tokens = append(tokens, Token{Text: stemWord(word), Start: tok.Start, End: tok.End})
+	}
+	return tokens
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func isLetterE(word string) bool {
+	return word == "e" || word == "е"
+}
+
+// onlyCodeSeparators - между E и номером только пробелы и дефисы
+func onlyCodeSeparators(s string) bool {
+	for _, r := range s {
+		if !unicode.IsSpace(r) && r != '-' && r != '‑' && r != '–' && r != '—' {
+			return false
+		}
+	}
+	return true
+}
+
+// additiveCode распознает E-номер: e621, е150а (кириллица), e322i.
+// Возвращает код латиницей в нижнем регистре.
+func additiveCode(word string) (string, bool) {
+	runes := []rune(word)
+	if len(runes) < 4 || (runes[0] != 'e' && runes[0] != 'е') {
+		return "", false
+	}
+
+	i := 1
+	for i < len(runes) && unicode.IsDigit(runes[i]) {
+		i++
+	}
+	digits := i - 1
+	if digits < 3 || digits > 4 {
+		return "", false
+	}
+
+	var code strings.Builder
+	code.WriteRune('e')
+	code.WriteString(string(runes[1:i]))
+	for _, r := range runes[i:] {
+		if lat, ok := cyrillicToLatin[r]; ok {
+			r = lat
+		}
+		if r < 'a' || r > 'z' {
+			return "", false
+		}
+		code.WriteRune(r)
+	}
+	if len(runes)-i > 3 {
+		return "", false
+	}
+	return code.String(), true
+}
+
+// normalizeWord приводит слово к нижнему регистру, заменяет ё на е и
+// исправляет смешение алфавитов: "caхар" с латинскими c и a становится "сахар"
+func normalizeWord(word string) string {
+	word = strings.ToLower(word)
+	word = strings.ReplaceAll(word, "ё", "е")
+
+	cyrillic, latin := 0, 0
+	for _, r := range word {
+		switch {
+		case unicode.Is(unicode.Cyrillic, r):
+			cyrillic++
+		case r >= 'a' && r <= 'z':
+			latin++
+		}
+	}
+	if cyrillic == 0 || latin == 0 {
+		return word
+	}
+
+	table := cyrillicToLatin
+	if cyrillic >= latin {
+		table = latinToCyrillic
+	}
+	return strings.Map(func(r rune) rune {
+		if mapped, ok := table[r]; ok {
+			return mapped
+		}
+		return r
+	}, word)
+}
+
+// stemWord приводит слово к основе: русское - стеммером Snowball,
+// английское - отбрасывая окончание множественного числа
+func stemWord(word string) string {
+	r, _ := utf8.DecodeRuneInString(word)
+	switch {
+	case unicode.Is(unicode.Cyrillic, r):
+		return StemRu(word)
+	case len(word) > 3 && strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss"):
+		return word[:len(word)-1]
+	}
+	return word
+}
+
+// containsTokens - встречается ли последовательность pattern подряд в tokens
+func containsTokens(tokens []Token, pattern []string) (Token, bool) {
+	if len(pattern) == 0 {
+		return Token{}, false
+	}
+	for i := 0; i+len(pattern) <= len(tokens); i++ {
+		matched := true
+		for j, word := range pattern {
+			if !tokenMatches(tokens[i+j].Text, word) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			last := tokens[i+len(pattern)-1]
+			return Token{Text: pattern[0], Start: tokens[i].Start, End: last.End}, true
+		}
+	}
+	return Token{}, false
+}
+
+// tokenMatches сравнивает токен с образцом. Код добавки с подвидом
+// (e322i, e472e) совпадает и с общим кодом (e322, e472).
+func tokenMatches(token, pattern string) bool {
+	if token == pattern {
+		return true
+	}
+	if _, ok := additiveCode(token); !ok {
+		return false
+	}
+	if _, ok := additiveCode(pattern); !ok {
+		return false
+	}
+	return strings.TrimRight(token, "abcdefghijklmnopqrstuvwxyz") == pattern
+}
@@ -0,0 +1,153 @@
+package services
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ajeanett/telbot/internal/models"
+)
+
+func tokenTexts(tokens []Token) []string {
+	texts := make([]string, len(tokens))
+	for i, token := range tokens {
+		texts[i] = token.Text
+	}
+	return texts
+}
+
+func TestTokenizeAdditiveCodes(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"E102", []string{"e102"}},
+		{"e1020", []string{"e1020"}},
+		{"Е 621", []string{"e621"}}, // кириллическая Е
+		{"E-621", []string{"e621"}},
+		{"Е–621", []string{"e621"}},  // тире
+		{"е150а", []string{"e150a"}}, // кириллица целиком
+		{"E322i", []string{"e322i"}},
+		{"E 12", []string{"e", "12"}}, // в E-номере три или четыре цифры
+		{"E, 621", []string{"e", "621"}},
+		{"caхар", []string{"сахар"}}, // латинские c и a
+	}
+	for _, tt := range tests {
+		if got := tokenTexts(Tokenize(tt.text)); !slices.Equal(got, tt.want) {
+			t.Errorf("Tokenize(%q) = %q, ожидалось %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestTokenizeSpans(t *testing.T) {
+	text := "соль, Е 621, сахар"
+	tokens := Tokenize(text)
+	want := []string{"соль", "Е 621", "сахар"}
+	if len(tokens) != len(want) {
+		t.Fatalf("токенов %d, ожидалось %d", len(tokens), len(want))
+	}
+	for i, token := range tokens {
+		if got := text[token.Start:token.End]; got != want[i] {
+			t.Errorf("токен %d указывает на %q, ожидалось %q", i, got, want[i])
+		}
+	}
+}
+
+func TestStemmerWordForms(t *testing.T) {
+	// Формы одного слова должны давать одну основу
+	tests := [][]string{
+		{"краситель", "красители", "красителя", "красителей"},
+		{"сахар", "сахара", "сахаром"},
+		{"пальмовое масло", "пальмового масла", "пальмовым маслом"},
+		{"нитрит натрия", "нитрита натрия", "нитритом натрия"},
+		{"сорбит", "сорбита", "сорбитом"},
+		{"крахмал", "крахмала", "крахмалом"},
+		{"смесь", "смеси", "смесью"},
+		{"ароматизатор", "ароматизаторы", "ароматизаторов"},
+		{"гидрогенизированный жир", "гидрогенизированного жира", "гидрогенизированные жиры"},
+	}
+	for _, forms := range tests {
+		want := tokenTexts(Tokenize(forms[0]))
+		for _, form := range forms[1:] {
+			if got := tokenTexts(Tokenize(form)); !slices.Equal(got, want) {
+				t.Errorf("%q -> %q, а %q -> %q", form, got, forms[0], want)
+			}
+		}
+	}
+}
+
+// formatTree записывает дерево ингредиентов как "шоколад{сахар, какао}, соль"
+func formatTree(nodes []*IngredientNode) string {
+	parts := make([]string, len(nodes))
+	for i, node := range nodes {
+		parts[i] = node.Text
+		if len(node.Children) > 0 {
+			parts[i] += "{" + formatTree(node.Children) + "}"
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func TestParseIngredientsNesting(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"сахар, соль; вода", "сахар, соль, вода"},
+		{"шоколад (сахар, какао-масло), соль", "шоколад{сахар, какао-масло}, соль"},
+		{
+			"глазурь (сахар, масло [пальмовое, кокосовое], эмульгатор {лецитин (соевый)}), орехи",
+			"глазурь{сахар, масло{пальмовое, кокосовое}, эмульгатор{лецитин{соевый}}}, орехи",
+		},
+		// Незакрытая скобка: вложенный список идет до конца текста
+		{"начинка (вишня, сахар", "начинка{вишня, сахар}"},
+		// Лишняя закрывающая скобка пропускается
+		{"мука), вода", "мука, вода"},
+	}
+	for _, tt := range tests {
+		if got := formatTree(ParseIngredients(tt.text)); got != tt.want {
+			t.Errorf("ParseIngredients(%q) = %q, ожидалось %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+// Корпус составов с этикеток: какие правила встроенной базы должны сработать
+func TestMatchLabelCorpus(t *testing.T) {
+	rules, err := DefaultRules()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"E-номер в скобках", "молоко, краситель тартразин (E102)", []string{"colourings", "e102"}},
+		{"четырехзначный номер не совпадает с трехзначным", "регулятор кислотности E1020", nil},
+		{"кириллическая Е через пробел", "усилитель вкуса Е 621", []string{"e621", "flavour-enhancers"}},
+		{"номер через дефис", "соль, E-621", []string{"e621"}},
+		{"подвид добавки", "эмульгатор E322i", []string{"e322"}},
+		{"кириллический подвид", "колер е150а", []string{"e150a"}},
+		{"название в родительном падеже", "без пальмового масла", []string{"palm-oil"}},
+		{"название в творительном падеже", "консервирован нитритом натрия", []string{"e250"}},
+		{"множественное число", "ароматизаторы, консерванты: сорбат калия", []string{"e202", "flavourings", "preservatives"}},
+		{
+			"вложенные скобки",
+			"шоколад (сахар, какао-масло, эмульгатор [лецитин соевый]), соль",
+			[]string{"e322"},
+		},
+		{"слово внутри другого слова не совпадает", "масло подсолнечное, мальтодекстрин", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, match := range matchProduct(rules, &models.Product{Composition: tt.text, ProductType: models.ProductTypeFood}) {
+				got = append(got, match.rule.ID)
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("%q: сработали %q, ожидались %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+// services/label.go
+package services
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/ajeanett/telbot/internal/models"
+)
+
+// ErrNoText - на фото не удалось распознать текст
+var ErrNoText = errors.New("текст на изображении не найден")
+
+// TextRecognizer распознает текст на фото этикетки
+type TextRecognizer interface {
+	RecognizeText(ctx context.Context, imageData []byte) (string, error)
+}
+
+// Источник продукта, собранного из фото этикетки
+const LabelSource = "фото этикетки"
+
+var (
+	// Начало списка ингредиентов на русских и английских этикетках
+	compositionStart = regexp.MustCompile(`(?i)(состав|ingredients)\s*:`)
+	// После состава обычно идут пищевая ценность, условия хранения и производитель
+	compositionEnd = regexp.MustCompile(`(?i)(пищевая ценность|энергетическая ценность|пищевая и энергетическая|` +
+		`условия хранения|хранить при|срок годности|годен до|изготовитель|производитель|` +
+		`nutrition|storage|best before)`)
+	// Перенос слова по слогам: "пальмо-\nвое"
+	wordBreak = regexp.MustCompile(`(\pL)-\s*\n\s*(\pL)`)
+	spaces    = regexp.MustCompile(`\s+`)
+)
+
+// ExtractComposition вырезает из распознанного текста этикетки список
+// ингредиентов. Если слова "Состав" нет, возвращается весь текст.
+func ExtractComposition(text string) string {
+	text = wordBreak.ReplaceAllString(text, "$1$2")
+
+	if loc := compositionStart.FindStringIndex(text); loc != nil {
+		text = text[loc[1]:]
+	}
+	if loc := compositionEnd.FindStringIndex(text); loc != nil {
+		text = text[:loc[0]]
+	}
+
+	text = spaces.ReplaceAllString(text, " ")
+	return strings.Trim(text, " .,;")
+}
+
+// AnalyzeLabel анализирует состав, распознанный на фото этикетки, как
+// продукт без штрих-кода. barcode может быть пустым.
+func (a *Analyzer) AnalyzeLabel(barcode, text string) *models.AnalysisResult {
+	product := &models.Product{
+		Barcode:     barcode,
+		Name:        "Продукт по фото этикетки",
+		Composition: ExtractComposition(text),
+		ProductType: models.ProductTypeFood,
+		Source:      LabelSource,
+	}
+	return a.AnalyzeProduct(product)
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/ajeanett/telbot/internal/models"
+)
+
+func TestExtractComposition(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{
+			name: "состав между заголовком и пищевой ценностью",
+			text: "Йогурт классический\nСостав: молоко нормализованное, сахар, закваска.\nПищевая ценность на 100 г: белки 3 г",
+			want: "молоко нормализованное, сахар, закваска",
+		},
+		{
+			name: "заголовок в другом регистре и без пробела",
+			text: "СОСТАВ:молоко, соль. Хранить при температуре от +2 до +6",
+			want: "молоко, соль",
+		},
+		{
+			name: "перенос слова по слогам",
+			text: "Состав: мука, масло пальмо-\nвое, сахар",
+			want: "мука, масло пальмовое, сахар",
+		},
+		{
+			name: "английская этикетка",
+			text: "Ingredients: water, sugar, citric acid. Best before: see cap",
+			want: "water, sugar, citric acid",
+		},
+		{
+			name: "без заголовка - весь текст",
+			text: "вода,\n  сахар,\tлимонная кислота.",
+			want: "вода, сахар, лимонная кислота",
+		},
+		{
+			name: "окончание состава раньше заголовка не учитывается",
+			text: "Изготовитель: ООО Ромашка\nСостав: вода, соль\nСрок годности 6 месяцев",
+			want: "вода, соль",
+		},
+		{name: "пустой текст", text: "", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExtractComposition(tt.text); got != tt.want {
+				t.Errorf("ExtractComposition(%q) = %q, ожидалось %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnalyzeLabel(t *testing.T) {
+	analyzer := NewAnalyzer()
+	text := "Колбаса вареная\nСостав: свинина, вода, соль, нитрит натрия.\nУсловия хранения: при +4"
+
+	result := analyzer.AnalyzeLabel("4600000000015", text)
+	product := result.Product
+	if product.Barcode != "4600000000015" || product.Source != LabelSource || product.ProductType != models.ProductTypeFood {
+		t.Errorf("продукт %+v: ожидались штрих-код, источник %q и тип food", product, LabelSource)
+	}
+	if product.Composition != "свинина, вода, соль, нитрит натрия" {
+		t.Errorf("состав %q", product.Composition)
+	}
+	if !hasFinding(result, "e250") {
+		t.Errorf("в составе не найден нитрит натрия: %+v", result.Findings)
+	}
+
+	if result := analyzer.AnalyzeLabel("", text); result.Product.Barcode != "" {
+		t.Errorf("штрих-код %q у этикетки без штрих-кода", result.Product.Barcode)
+	}
+}
+
+func hasFinding(result *models.AnalysisResult, code string) bool {
+	for _, f := range result.Findings {
+		if f.Code == code {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,157 @@
+// services/nutrition.go
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ajeanett/telbot/internal/models"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Threshold - границы "светофора" на 100 г: до Low включительно - зеленый,
+// выше High - красный, между ними - желтый
+type Threshold struct {
+	Low  float64 `json:"low"`
+	High float64 `json:"high"`
+}
+
+func (t Threshold) level(value float64) models.NutrientLevel {
+	switch {
+	case value <= t.Low:
+		return models.LevelLow
+	case value > t.High:
+		return models.LevelHigh
+	default:
+		return models.LevelMedium
+	}
+}
+
+// NutrientThresholds - пороги для жира, насыщенного жира, сахара и соли
+type NutrientThresholds struct {
+	Fat          Threshold `json:"fat"`
+	SaturatedFat Threshold `json:"saturated_fat"`
+	Sugars       Threshold `json:"sugars"`
+	Salt         Threshold `json:"salt"`
+}
+
+// NutritionThresholds - пороги для еды и для напитков (у напитков они строже)
+type NutritionThresholds struct {
+	Food      NutrientThresholds `json:"food"`
+	Beverages NutrientThresholds `json:"beverages"`
+	// Клетчатка от этого значения - "источник клетчатки"
+	HighFiber float64 `json:"high_fiber"`
+}
+
+// DefaultNutritionThresholds - пороги FSA (Food Standards Agency, Великобритания)
+var DefaultNutritionThresholds = NutritionThresholds{
+	Food: NutrientThresholds{
+		Fat:          Threshold{Low: 3, High: 17.5},
+		SaturatedFat: Threshold{Low: 1.5, High: 5},
+		Sugars:       Threshold{Low: 5, High: 22.5},
+		Salt:         Threshold{Low: 0.3, High: 1.5},
+	},
+	Beverages: NutrientThresholds{
+		Fat:          Threshold{Low: 1.5, High: 8.75},
+		SaturatedFat: Threshold{Low: 0.75, High: 2.5},
+		Sugars:       Threshold{Low: 2.5, High: 11.25},
+		Salt:         Threshold{Low: 0.3, High: 0.75},
+	},
+	HighFiber: 6,
+}
+
+// LoadNutritionThresholds читает пороги из JSON-файла.
+// Незаданные в файле значения берутся из DefaultNutritionThresholds.
+func LoadNutritionThresholds(path string) (NutritionThresholds, error) {
+	thresholds := DefaultNutritionThresholds
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return thresholds, fmt.Errorf("ошибка чтения порогов пищевой ценности: %w", err)
+	}
+	if err := json.Unmarshal(data, &thresholds); err != nil {
+		return thresholds, fmt.Errorf("ошибка парсинга порогов пищевой ценности: %w", err)
+	}
+	return thresholds, nil
+}
+
+// SetNutritionThresholds меняет пороги "светофора"
+func (a *Analyzer) SetNutritionThresholds(thresholds NutritionThresholds) {
+	a.thresholds = thresholds
+}
+
+// analyzeNutrition оценивает пищевую ценность, Nutri-Score и группу NOVA
+func (a *Analyzer) analyzeNutrition(product *models.Product, result *models.AnalysisResult) {
+	thresholds := a.thresholds.Food
+	if slices.Contains(product.CategoriesTags, "en:beverages") {
+		thresholds = a.thresholds.Beverages
+	}
+
+	n := product.Nutriments
+	addNutrient(result, "energy-kcal", "Энергия", "ккал", n.EnergyKcal, nil)
+	addNutrient(result, "fat", "Жиры", "г", n.Fat, &thresholds.Fat)
+	addNutrient(result, "saturated-fat", "Насыщенные жиры", "г", n.SaturatedFat, &thresholds.SaturatedFat)
+	addNutrient(result, "sugars", "Сахар", "г", n.Sugars, &thresholds.Sugars)
+	addNutrient(result, "salt", "Соль", "г", n.Salt, &thresholds.Salt)
+	addNutrient(result, "fiber", "Клетчатка", "г", n.Fiber, nil)
+	addNutrient(result, "proteins", "Белки", "г", n.Proteins, nil)
+
+	if grade := strings.ToLower(product.NutriScoreGrade); len(grade) == 1 && grade >= "a" && grade <= "e" {
+		result.NutriScore = grade
+	}
+	result.NovaGroup = product.NovaLevel()
+}
+
+func addNutrient(result *models.AnalysisResult, key, name, unit string, raw json.Number, threshold *Threshold) {
+	value, ok := models.NumberValue(raw)
+	if !ok {
+		return
+	}
+
+	info := models.NutrientInfo{
+		Key:     key,
+		Name:    name,
+		Per100g: value,
+		Unit:    unit,
+	}
+	if threshold != nil {
+		info.Level = threshold.level(value)
+	}
+	result.Nutrition = append(result.Nutrition, info)
+}
+
+// nutritionRecommendations дополняет рекомендации выводами о пищевой ценности
+func (a *Analyzer) nutritionRecommendations(result *models.AnalysisResult) {
+	if high := result.HighNutrients(); len(high) > 0 {
+		names := make([]string, 0, len(high))
+		for _, n := range high {
+			names = append(names, strings.ToLower(n.Name))
+		}
+		result.Recommendations = append(result.Recommendations,
+			"🍬 Высокое содержание: "+strings.Join(names, ", ")+" - употребляйте умеренно")
+	}
+
+	for _, n := range result.Nutrition {
+		if n.Key == "fiber" && n.Per100g >= a.thresholds.HighFiber {
+			result.Recommendations = append(result.Recommendations, "🌾 Хороший источник клетчатки")
+		}
+	}
+
+	if result.NovaGroup == 4 {
+		result.Recommendations = append(result.Recommendations,
+			"🏭 Ультраобработанный продукт (NOVA 4)")
+	}
+
+	if result.NutriScore == "d" || result.NutriScore == "e" {
+		result.Recommendations = append(result.Recommendations,
+			fmt.Sprintf("📉 Низкая пищевая ценность (Nutri-Score %s)", strings.ToUpper(result.NutriScore)))
+	}
+}
+
+// poorNutrition - продукт безопасен по составу, но нездоров по пищевой ценности
+func poorNutrition(result *models.AnalysisResult) bool {
+	return len(result.HighNutrients()) > 0 ||
+		result.NovaGroup == 4 ||
+		result.NutriScore == "d" || result.NutriScore == "e"
+}
@@ -0,0 +1,150 @@
+package services
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/ajeanett/telbot/internal/models"
+)
+
+// nutrientLevel анализирует продукт с одним нутриентом и возвращает его уровень
+func nutrientLevel(t *testing.T, analyzer *Analyzer, key string, value float64, categories ...string) models.NutrientLevel {
+	t.Helper()
+	number := json.Number(strconv.FormatFloat(value, 'f', -1, 64))
+	product := &models.Product{Barcode: "1", ProductType: models.ProductTypeFood, CategoriesTags: categories}
+	switch key {
+	case "fat":
+		product.Nutriments.Fat = number
+	case "saturated-fat":
+		product.Nutriments.SaturatedFat = number
+	case "sugars":
+		product.Nutriments.Sugars = number
+	case "salt":
+		product.Nutriments.Salt = number
+	}
+	for _, n := range analyzer.AnalyzeProduct(product).Nutrition {
+		if n.Key == key {
+			return n.Level
+		}
+	}
+	t.Fatalf("нутриент %s не попал в анализ", key)
+	return ""
+}
+
+func TestNutritionThresholdBoundaries(t *testing.T) {
+	analyzer := NewAnalyzer()
+	food, drinks := DefaultNutritionThresholds.Food, DefaultNutritionThresholds.Beverages
+	tables := []struct {
+		name       string
+		categories []string
+		thresholds map[string]Threshold
+	}{
+		// Напитки оцениваются на 100 мл по более строгим порогам
+		{"еда на 100 г", nil, map[string]Threshold{
+			"fat": food.Fat, "saturated-fat": food.SaturatedFat, "sugars": food.Sugars, "salt": food.Salt,
+		}},
+		{"напитки на 100 мл", []string{"en:beverages", "en:sodas"}, map[string]Threshold{
+			"fat": drinks.Fat, "saturated-fat": drinks.SaturatedFat, "sugars": drinks.Sugars, "salt": drinks.Salt,
+		}},
+	}
+	const step = 0.01
+	for _, table := range tables {
+		t.Run(table.name, func(t *testing.T) {
+			for key, threshold := range table.thresholds {
+				cases := []struct {
+					value float64
+					want  models.NutrientLevel
+				}{
+					{0, models.LevelLow},
+					{threshold.Low, models.LevelLow},
+					{threshold.Low + step, models.LevelMedium},
+					{threshold.High, models.LevelMedium},
+					{threshold.High + step, models.LevelHigh},
+				}
+				for _, c := range cases {
+					if got := nutrientLevel(t, analyzer, key, c.value, table.categories...); got != c.want {
+						t.Errorf("%s = %v: уровень %q, ожидался %q", key, c.value, got, c.want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestBeverageThresholdsAreStricter(t *testing.T) {
+	analyzer := NewAnalyzer()
+	// 12 г сахара на 100 г еды - средний уровень, а на 100 мл напитка - высокий
+	if got := nutrientLevel(t, analyzer, "sugars", 12, "en:beverages"); got != models.LevelHigh {
+		t.Errorf("12 г сахара в напитке: %q, ожидался high", got)
+	}
+	if got := nutrientLevel(t, analyzer, "sugars", 12); got != models.LevelMedium {
+		t.Errorf("12 г сахара в еде: %q, ожидался medium", got)
+	}
+}
+
+func TestHighFiberRecommendation(t *testing.T) {
+	analyzer := NewAnalyzer()
+	for _, tt := range []struct {
+		fiber string
+		want  bool
+	}{{"5.9", false}, {"6", true}, {"11", true}} {
+		product := &models.Product{Barcode: "1", ProductType: models.ProductTypeFood, Nutriments: models.Nutriments{Fiber: json.Number(tt.fiber)}}
+		result := analyzer.AnalyzeProduct(product)
+		got := false
+		for _, r := range result.Recommendations {
+			if r == "🌾 Хороший источник клетчатки" {
+				got = true
+			}
+		}
+		if got != tt.want {
+			t.Errorf("клетчатка %s г: рекомендация %v, ожидалось %v", tt.fiber, got, tt.want)
+		}
+	}
+}
+
+func TestMissingNutrientsAreNotZero(t *testing.T) {
+	result := NewAnalyzer().AnalyzeProduct(&models.Product{Barcode: "1", ProductType: models.ProductTypeFood})
+	if len(result.Nutrition) != 0 {
+		t.Errorf("без данных о пищевой ценности получено %+v", result.Nutrition)
+	}
+}
+
+func TestLoadNutritionThresholds(t *testing.T) {
+	dir := t.TempDir()
+	custom := filepath.Join(dir, "thresholds.json")
+	if err := os.WriteFile(custom, []byte(`{"food": {"sugars": {"low": 2, "high": 10}}, "high_fiber": 3}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	thresholds, err := LoadNutritionThresholds(custom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if thresholds.Food.Sugars != (Threshold{Low: 2, High: 10}) || thresholds.HighFiber != 3 {
+		t.Errorf("заданные в файле пороги не применились: %+v", thresholds)
+	}
+	// Незаданные значения остаются по умолчанию
+	if thresholds.Food.Salt != DefaultNutritionThresholds.Food.Salt || thresholds.Beverages != DefaultNutritionThresholds.Beverages {
+		t.Errorf("незаданные пороги изменились: %+v", thresholds)
+	}
+
+	analyzer := NewAnalyzer()
+	analyzer.SetNutritionThresholds(thresholds)
+	if got := nutrientLevel(t, analyzer, "sugars", 10.5); got != models.LevelHigh {
+		t.Errorf("10.5 г сахара с порогом 10: %q, ожидался high", got)
+	}
+
+	broken := filepath.Join(dir, "broken.json")
+	if err := os.WriteFile(broken, []byte(`{"food": `), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadNutritionThresholds(broken); err == nil {
+		t.Error("испорченный JSON принят")
+	}
+	if _, err := LoadNutritionThresholds(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("отсутствующий файл не дал ошибки")
+	}
+}
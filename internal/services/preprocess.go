@@ -0,0 +1,250 @@
+// services/preprocess.go
+package services
+
+import (
+	"image"
+	"image/draw"
+	"math"
+)
+
+// Фото больше этого по длинной стороне уменьшается до распознавания:
+// штрих-коду столько не нужно, а каждая попытка становится дороже
+const maxDecodeSide = 2048
+
+// preprocessStep - один способ подготовить фото к распознаванию.
+// apply возвращает nil, если способ к этому фото неприменим.
+type preprocessStep struct {
+	name  string
+	apply func(*image.Gray) *image.Gray
+}
+
+// Способы перебираются по порядку до первого успеха: сначала дешевые
+// и чаще всего помогающие, затем повороты
+var preprocessSteps = []preprocessStep{
+	{"original", func(g *image.Gray) *image.Gray { return g }},
+	{"contrast", stretchContrast},
+	{"downscale", func(g *image.Gray) *image.Gray {
+		// Крупный размытый штрих-код после уменьшения становится резче
+		if maxSide(g) < 800 {
+			return nil
+		}
+		return scaleGray(g, 0.5)
+	}},
+	{"upscale", func(g *image.Gray) *image.Gray {
+		if minSide(g) >= 600 {
+			return nil
+		}
+		return scaleGray(g, 2)
+	}},
+	{"crop80", func(g *image.Gray) *image.Gray { return centerCrop(g, 0.8) }},
+	{"crop60", func(g *image.Gray) *image.Gray { return centerCrop(g, 0.6) }},
+	{"crop60+upscale", func(g *image.Gray) *image.Gray {
+		// Маленький штрих-код посреди большого кадра
+		return scaleGray(stretchContrast(centerCrop(g, 0.6)), 2)
+	}},
+	{"rotate90", func(g *image.Gray) *image.Gray { return rotateRight(g, 1) }},
+	{"rotate270", func(g *image.Gray) *image.Gray { return rotateRight(g, 3) }},
+	{"rotate180", func(g *image.Gray) *image.Gray { return rotateRight(g, 2) }},
+	{"skew+5", func(g *image.Gray) *image.Gray { return rotateAngle(g, 5) }},
+	{"skew-5", func(g *image.Gray) *image.Gray { return rotateAngle(g, -5) }},
+	{"skew+10", func(g *image.Gray) *image.Gray { return rotateAngle(g, 10) }},
+	{"skew-10", func(g *image.Gray) *image.Gray { return rotateAngle(g, -10) }},
+	{"skew+15", func(g *image.Gray) *image.Gray { return rotateAngle(g, 15) }},
+	{"skew-15", func(g *image.Gray) *image.Gray { return rotateAngle(g, -15) }},
+}
+
+// toGray переводит изображение в оттенки серого и ограничивает его размер
+func toGray(img image.Image) *image.Gray {
+	gray, ok := img.(*image.Gray)
+	if !ok {
+		bounds := img.Bounds()
+		gray = image.NewGray(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+		draw.Draw(gray, gray.Bounds(), img, bounds.Min, draw.Src)
+	}
+	if side := maxSide(gray); side > maxDecodeSide {
+		gray = scaleGray(gray, float64(maxDecodeSide)/float64(side))
+	}
+	return gray
+}
+
+// stretchContrast растягивает яркость так, чтобы 2% самых темных точек
+// стали черными, а 2% самых светлых - белыми
+func stretchContrast(g *image.Gray) *image.Gray {
+	w, h := g.Bounds().Dx(), g.Bounds().Dy()
+	var histogram [256]int
+	for y := 0; y < h; y++ {
+		for _, v := range g.Pix[y*g.Stride : y*g.Stride+w] {
+			histogram[v]++
+		}
+	}
+
+	cut := w * h / 50
+	low, high := 0, 255
+	for sum := 0; low < 255 && sum+histogram[low] <= cut; low++ {
+		sum += histogram[low]
+	}
+	for sum := 0; high > 0 && sum+histogram[high] <= cut; high-- {
+		sum += histogram[high]
+	}
+	if high-low < 2 {
+		// Однотонное изображение растягивать нечего
+		return g
+	}
+
+	var table [256]uint8
+	for v := range table {
+		scaled := (v - low) * 255 / (high - low)
+		table[v] = uint8(max(0, min(255, scaled)))
+	}
+	// Фрагмент фото делит Pix с исходным изображением, поэтому идем по строкам
+	out := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x, v := range g.Pix[y*g.Stride : y*g.Stride+w] {
+			out.Pix[y*out.Stride+x] = table[v]
+		}
+	}
+	return out
+}
+
+// scaleGray масштабирует изображение билинейной интерполяцией
+func scaleGray(g *image.Gray, factor float64) *image.Gray {
+	bounds := g.Bounds()
+	w := max(1, int(float64(bounds.Dx())*factor))
+	h := max(1, int(float64(bounds.Dy())*factor))
+	out := image.NewGray(image.Rect(0, 0, w, h))
+
+	xRatio := float64(bounds.Dx()-1) / float64(max(w-1, 1))
+	yRatio := float64(bounds.Dy()-1) / float64(max(h-1, 1))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			out.Pix[y*out.Stride+x] = bilinearAt(g, float64(x)*xRatio, float64(y)*yRatio)
+		}
+	}
+	return out
+}
+
+// centerCrop оставляет центральную часть изображения
+func centerCrop(g *image.Gray, fraction float64) *image.Gray {
+	bounds := g.Bounds()
+	w := int(float64(bounds.Dx()) * fraction)
+	h := int(float64(bounds.Dy()) * fraction)
+	if w < 50 || h < 50 {
+		return nil
+	}
+	x0 := bounds.Min.X + (bounds.Dx()-w)/2
+	y0 := bounds.Min.Y + (bounds.Dy()-h)/2
+
+	out := image.NewGray(image.Rect(0, 0, w, h))
+	draw.Draw(out, out.Bounds(), g, image.Pt(x0, y0), draw.Src)
+	return out
+}
+
+// rotateRight поворачивает изображение на quarters * 90° по часовой стрелке
+func rotateRight(g *image.Gray, quarters int) *image.Gray {
+	bounds := g.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+
+	var out *image.Gray
+	if quarters%2 == 1 {
+		out = image.NewGray(image.Rect(0, 0, h, w))
+	} else {
+		out = image.NewGray(image.Rect(0, 0, w, h))
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := grayAt(g, x, y)
+			switch quarters % 4 {
+			case 1:
+				out.Pix[x*out.Stride+(h-1-y)] = v
+			case 2:
+				out.Pix[(h-1-y)*out.Stride+(w-1-x)] = v
+			case 3:
+				out.Pix[(w-1-x)*out.Stride+y] = v
+			default:
+				out.Pix[y*out.Stride+x] = v
+			}
+		}
+	}
+	return out
+}
+
+// rotateAngle поворачивает изображение на небольшой угол вокруг центра.
+// Интерполяция обязательна: без нее тонкие штрихи слипаются и декодер
+// уверенно читает неверный код.
+func rotateAngle(g *image.Gray, degrees float64) *image.Gray {
+	bounds := g.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	out := image.NewGray(image.Rect(0, 0, w, h))
+
+	sin, cos := math.Sincos(degrees * math.Pi / 180)
+	cx, cy := float64(w)/2, float64(h)/2
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			// Обратное преобразование: откуда взять точку исходного изображения
+			dx, dy := float64(x)-cx, float64(y)-cy
+			sx := cos*dx + sin*dy + cx
+			sy := -sin*dx + cos*dy + cy
+			out.Pix[y*out.Stride+x] = bilinearAt(g, sx, sy)
+		}
+	}
+	return out
+}
+
+// bilinearAt читает яркость между точками; за краем изображения - белый
+func bilinearAt(g *image.Gray, x, y float64) uint8 {
+	w, h := g.Bounds().Dx(), g.Bounds().Dy()
+	if x < 0 || y < 0 || x > float64(w-1) || y > float64(h-1) {
+		return 255
+	}
+	x0, y0 := int(x), int(y)
+	x1, y1 := min(x0+1, w-1), min(y0+1, h-1)
+	fx, fy := x-float64(x0), y-float64(y0)
+
+	top := float64(grayAt(g, x0, y0))*(1-fx) + float64(grayAt(g, x1, y0))*fx
+	bottom := float64(grayAt(g, x0, y1))*(1-fx) + float64(grayAt(g, x1, y1))*fx
+	return uint8(top*(1-fy) + bottom*fy + 0.5)
+}
+
+// grayAt читает точку по координатам относительно начала изображения
+func grayAt(g *image.Gray, x, y int) uint8 {
+	return g.Pix[y*g.Stride+x]
+}
+
+func maxSide(g *image.Gray) int {
+	return max(g.Bounds().Dx(), g.Bounds().Dy())
+}
+
+func minSide(g *image.Gray) int {
+	return min(g.Bounds().Dx(), g.Bounds().Dy())
+}
+
+// tile - фрагмент фото для поиска нескольких штрих-кодов
+type tile struct {
+	img   *image.Gray
+	whole bool // фото целиком
+}
+
+// Сетки фрагментов: 2x2 и 3x3 с перекрытием, чтобы штрих-код на границе
+// целиком попал хотя бы в один фрагмент
+var tileGrids = []int{2, 3}
+
+// imageTiles режет фото на перекрывающиеся фрагменты; первый - фото целиком
+func imageTiles(g *image.Gray) []tile {
+	tiles := []tile{{img: g, whole: true}}
+	bounds := g.Bounds()
+	for _, n := range tileGrids {
+		// Фрагмент на треть шире шага сетки
+		w, h := bounds.Dx()*4/(3*n+1), bounds.Dy()*4/(3*n+1)
+		stepX, stepY := (bounds.Dx()-w)/max(n-1, 1), (bounds.Dy()-h)/max(n-1, 1)
+		if w < 100 || h < 100 {
+			continue
+		}
+		for row := 0; row < n; row++ {
+			for col := 0; col < n; col++ {
+				rect := image.Rect(col*stepX, row*stepY, col*stepX+w, row*stepY+h).Add(bounds.Min)
+				tiles = append(tiles, tile{img: g.SubImage(rect).(*image.Gray)})
+			}
+		}
+	}
+	return tiles
+}
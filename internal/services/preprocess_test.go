@@ -0,0 +1,57 @@
+package services
+
+import (
+	"image"
+	"testing"
+)
+
+func TestImageTiles(t *testing.T) {
+	tests := []struct {
+		name   string
+		bounds image.Rectangle
+		tiles  int
+	}{
+		// Фрагменты меньше 100 px не режутся: штрих-код в них не поместится
+		{"маленькое фото", image.Rect(0, 0, 160, 120), 1},
+		{"только сетка 2x2", image.Rect(0, 0, 240, 240), 1 + 4},
+		{"обе сетки", image.Rect(0, 0, 1280, 960), 1 + 4 + 9},
+		{"фото со смещением", image.Rect(40, 30, 1320, 990), 1 + 4 + 9},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := image.NewGray(tt.bounds)
+			tiles := imageTiles(g)
+			if len(tiles) != tt.tiles {
+				t.Fatalf("фрагментов %d, ожидалось %d", len(tiles), tt.tiles)
+			}
+			if !tiles[0].whole || tiles[0].img.Bounds() != tt.bounds {
+				t.Errorf("первый фрагмент %v, ожидалось фото целиком", tiles[0].img.Bounds())
+			}
+
+			// Фрагменты не выходят за фото и вместе покрывают его
+			var union image.Rectangle
+			for _, tile := range tiles[1:] {
+				r := tile.img.Bounds()
+				if tile.whole || !r.In(tt.bounds) || r.Dx() < 100 || r.Dy() < 100 {
+					t.Errorf("фрагмент %v на фото %v", r, tt.bounds)
+				}
+				union = union.Union(r)
+			}
+			if len(tiles) > 1 && (tt.bounds.Max.X-union.Max.X > 2 || tt.bounds.Max.Y-union.Max.Y > 2 || union.Min != tt.bounds.Min) {
+				t.Errorf("фрагменты покрывают %v из %v", union, tt.bounds)
+			}
+		})
+	}
+}
+
+func TestImageTilesOverlap(t *testing.T) {
+	// Соседние фрагменты сетки перекрываются, чтобы код на стыке целиком попал в один из них
+	tiles := imageTiles(image.NewGray(image.Rect(0, 0, 1280, 960)))
+	grid := tiles[1:5] // 2x2
+	if overlap := grid[0].img.Bounds().Intersect(grid[1].img.Bounds()); overlap.Dx() <= 0 {
+		t.Errorf("соседние по горизонтали фрагменты %v и %v не перекрываются", grid[0].img.Bounds(), grid[1].img.Bounds())
+	}
+	if overlap := grid[0].img.Bounds().Intersect(grid[2].img.Bounds()); overlap.Dy() <= 0 {
+		t.Errorf("соседние по вертикали фрагменты %v и %v не перекрываются", grid[0].img.Bounds(), grid[2].img.Bounds())
+	}
+}
@@ -0,0 +1,385 @@
+// services/profile.go
+package services
+
+import (
+	"fmt"
+	"github.com/ajeanett/telbot/internal/models"
+	"github.com/ajeanett/telbot/internal/utils"
+	"slices"
+	"strings"
+)
+
+// Allergen - аллерген из списка Open Food Facts
+type Allergen struct {
+	Tag        string // тег Open Food Facts, например en:gluten
+	Name       string
+	aliases    []string   // как пользователь может назвать аллерген
+	keywords   [][]string // ингредиенты-источники, если в базе нет тегов
+	exclusions [][]string // фразы, в которых ключевое слово не означает аллерген
+}
+
+// Diet - тип питания
+type Diet struct {
+	ID         string
+	Name       string
+	aliases    []string
+	labels     []string   // метки Open Food Facts, подтверждающие соответствие
+	keywords   [][]string // ингредиенты, которые диете не соответствуют
+	exclusions [][]string // фразы, в которых ключевое слово не нарушает диету
+}
+
+// Типы питания
+const (
+	DietVegan       = "vegan"
+	DietVegetarian  = "vegetarian"
+	DietHalal       = "halal"
+	DietLactoseFree = "lactose_free"
+	DietDiabetic    = "diabetic"
+)
+
+// Порог сахара на 100 г, выше которого продукт не подходит при диабете
+const diabeticSugarLimit = 5.0
+
+var meatKeywords = []string{
+	"мясо", "говядина", "свинина", "баранина", "телятина", "курица", "куриный", "индейка", "утка",
+	"бекон", "ветчина", "сало", "колбаса", "фарш", "желатин", "рыба", "рыбный", "анчоус", "креветки",
+	"meat", "beef", "pork", "chicken", "turkey", "bacon", "ham", "lard", "gelatin", "gelatine", "fish", "anchovy",
+	"e120", "e441", "e542", "e904",
+}
+
+// Растительное "молоко" и молочная кислота не содержат молока
+var dairyExclusions = []string{
+	"кокосовое молоко", "кокосовые сливки", "миндальное молоко", "соевое молоко", "овсяное молоко",
+	"рисовое молоко", "молочная кислота", "coconut milk", "coconut cream", "almond milk", "soy milk",
+	"oat milk", "rice milk", "cocoa butter", "peanut butter", "shea butter",
+}
+
+// Allergens - основные аллергены, обязательные к указанию в ЕС и ЕАЭС
+var Allergens = []Allergen{
+	newAllergen("en:gluten", "Глютен", []string{"глютен", "gluten", "клейковина"},
+		"глютен", "клейковина", "пшеница", "пшеничная мука", "пшеничный", "рожь", "ржаной", "ячмень", "ячменный",
+		"овес", "овсяный", "солод", "манка", "булгур", "gluten", "wheat", "barley", "rye", "oat", "malt"),
+	newAllergen("en:milk", "Молоко", []string{"молоко", "молочные продукты", "milk", "dairy"},
+		"молоко", "молочный", "сливки", "сливочное масло", "сыворотка", "лактоза", "казеин", "сыр", "творог",
+		"йогурт", "кефир", "milk", "cream", "butter", "whey", "lactose", "casein", "cheese").
+		except(dairyExclusions...),
+	newAllergen("en:eggs", "Яйца", []string{"яйца", "яйцо", "eggs", "egg"},
+		"яйцо", "яйца", "яичный", "меланж", "яичный порошок", "egg", "e1105"),
+	newAllergen("en:nuts", "Орехи", []string{"орехи", "орех", "nuts"},
+		"орех", "орехи", "миндаль", "фундук", "лесной орех", "грецкий орех", "кешью", "фисташки", "пекан",
+		"макадамия", "бразильский орех", "nut", "almond", "hazelnut", "walnut", "cashew", "pistachio", "pecan", "macadamia").
+		// Мускатный и кокосовый орехи не относятся к аллергену, земляной орех - это арахис
+		except("мускатный орех", "кокосовый орех", "земляной орех"),
+	newAllergen("en:peanuts", "Арахис", []string{"арахис", "peanuts", "peanut"},
+		"арахис", "арахисовый", "peanut"),
+	newAllergen("en:soybeans", "Соя", []string{"соя", "soy", "soya"},
+		"соя", "соевый", "соевый лецитин", "тофу", "soy", "soya", "soybean", "tofu"),
+	newAllergen("en:fish", "Рыба", []string{"рыба", "fish"},
+		"рыба", "рыбный", "анчоус", "тунец", "лосось", "треска", "fish", "anchovy", "tuna", "salmon", "cod"),
+	newAllergen("en:crustaceans", "Ракообразные", []string{"ракообразные", "креветки", "crustaceans"},
+		"креветки", "краб", "лобстер", "омар", "раки", "shrimp", "prawn", "crab", "lobster"),
+	newAllergen("en:molluscs", "Моллюски", []string{"моллюски", "molluscs"},
+		"кальмар", "мидии", "устрицы", "осьминог", "гребешок", "squid", "mussel", "oyster", "octopus"),
+	newAllergen("en:celery", "Сельдерей", []string{"сельдерей", "celery"},
+		"сельдерей", "celery"),
+	newAllergen("en:mustard", "Горчица", []string{"горчица", "mustard"},
+		"горчица", "горчичный", "mustard"),
+	newAllergen("en:sesame-seeds", "Кунжут", []string{"кунжут", "sesame"},
+		"кунжут", "кунжутный", "тахини", "sesame", "tahini"),
+	newAllergen("en:sulphur-dioxide-and-sulphites", "Сульфиты", []string{"сульфиты", "sulphites", "sulfites"},
+		"сульфит", "диоксид серы", "e220", "e221", "e222", "e223", "e224", "e226", "e227", "e228", "sulphite", "sulfite"),
+	newAllergen("en:lupin", "Люпин", []string{"люпин", "lupin"},
+		"люпин", "lupin"),
+}
+
+// Diets - поддерживаемые типы питания
+var Diets = []Diet{
+	newDiet(DietVegan, "Веганство", []string{"веган", "веганство", "vegan"}, []string{"en:vegan"},
+		append(slices.Clone(meatKeywords), "молоко", "молочный", "сливки", "сливочное масло", "сыворотка", "сыр",
+			"яйцо", "яичный", "мед", "воск", "milk", "cream", "butter", "whey", "egg", "honey", "e901", "e966", "e1105")...).
+		except(dairyExclusions...),
+	newDiet(DietVegetarian, "Вегетарианство", []string{"вегетарианец", "вегетарианство", "vegetarian"}, []string{"en:vegetarian", "en:vegan"},
+		meatKeywords...),
+	newDiet(DietHalal, "Халяль", []string{"халяль", "халал", "halal"}, []string{"en:halal"},
+		"свинина", "свиной", "бекон", "ветчина", "сало", "желатин", "спирт", "алкоголь", "вино", "коньяк", "ром",
+		"пиво", "ликер", "pork", "bacon", "ham", "lard", "gelatin", "gelatine", "alcohol", "wine", "rum", "beer",
+		"e120", "e441", "e542").
+		// Винный уксус и винная кислота не содержат алкоголя, сахарные спирты - не алкоголь
+		except("винный уксус", "винная кислота", "винный камень", "сахарный спирт", "wine vinegar", "sugar alcohol"),
+	newDiet(DietLactoseFree, "Без лактозы", []string{"без лактозы", "лактоза", "непереносимость лактозы", "lactose free", "lactose"},
+		[]string{"en:no-lactose", "en:lactose-free"},
+		"лактоза", "молоко", "молочный", "сливки", "сливочное масло", "сыворотка", "сухое молоко", "lactose", "milk", "cream", "whey").
+		except(dairyExclusions...),
+	newDiet(DietDiabetic, "Диабет", []string{"диабет", "диабетик", "diabetes", "diabetic"}, []string{"en:no-added-sugar", "en:sugar-free"},
+		"сахар", "глюкоза", "фруктоза", "декстроза", "сироп", "глюкозный сироп", "патока", "мед", "sugar", "glucose",
+		"fructose", "dextrose", "syrup", "honey"),
+}
+
+func newAllergen(tag, name string, aliases []string, keywords ...string) Allergen {
+	return Allergen{
+		Tag:      tag,
+		Name:     name,
+		aliases:  normalizePhrases(aliases),
+		keywords: tokenizePhrases(keywords),
+	}
+}
+
+// except задает фразы, в которых ключевые слова аллергена не засчитываются
+func (a Allergen) except(phrases ...string) Allergen {
+	a.exclusions = tokenizePhrases(phrases)
+	return a
+}
+
+func newDiet(id, name string, aliases, labels []string, keywords ...string) Diet {
+	return Diet{
+		ID:       id,
+		Name:     name,
+		aliases:  normalizePhrases(aliases),
+		labels:   labels,
+		keywords: tokenizePhrases(keywords),
+	}
+}
+
+// except задает фразы, в которых ключевые слова диеты не засчитываются
+func (d Diet) except(phrases ...string) Diet {
+	d.exclusions = tokenizePhrases(phrases)
+	return d
+}
+
+// FindAllergen ищет аллерген по тегу или названию в любой форме ("орехов", "Глютен")
+func FindAllergen(input string) (Allergen, bool) {
+	phrase := normalizePhrase(input)
+	for _, allergen := range Allergens {
+		if allergen.Tag == strings.ToLower(strings.TrimSpace(input)) || slices.Contains(allergen.aliases, phrase) {
+			return allergen, true
+		}
+	}
+	return Allergen{}, false
+}
+
+// AllergenByTag возвращает аллерген по тегу Open Food Facts
+func AllergenByTag(tag string) (Allergen, bool) {
+	for _, allergen := range Allergens {
+		if allergen.Tag == tag {
+			return allergen, true
+		}
+	}
+	return Allergen{}, false
+}
+
+// FindDiet ищет тип питания по идентификатору или названию
+func FindDiet(input string) (Diet, bool) {
+	phrase := normalizePhrase(input)
+	for _, diet := range Diets {
+		if diet.ID == strings.ToLower(strings.TrimSpace(input)) || slices.Contains(diet.aliases, phrase) {
+			return diet, true
+		}
+	}
+	return Diet{}, false
+}
+
+// DietByID возвращает тип питания по идентификатору
+func DietByID(id string) (Diet, bool) {
+	for _, diet := range Diets {
+		if diet.ID == id {
+			return diet, true
+		}
+	}
+	return Diet{}, false
+}
+
+// Personalize дополняет результат анализа причинами, по которым продукт
+// не подходит пользователю с таким профилем
+func (a *Analyzer) Personalize(result *models.AnalysisResult, profile *models.UserProfile) {
+	if profile.IsEmpty() {
+		return
+	}
+
+	product := result.Product
+	ingredients := productIngredients(product)
+
+	for _, tag := range profile.Allergens {
+		allergen, ok := AllergenByTag(tag)
+		if !ok {
+			continue
+		}
+		switch {
+		case slices.Contains(product.AllergensTags, tag):
+			addUnsuitable(result, "содержит аллерген: "+strings.ToLower(allergen.Name))
+		case slices.Contains(product.TracesTags, tag):
+			addUnsuitable(result, "может содержать следы: "+strings.ToLower(allergen.Name))
+		case len(product.AllergensTags) == 0:
+			// Состав проверяется, только если в базе нет разметки аллергенов:
+			// по тегам Open Food Facts ошибок меньше, чем по словам
+			if found, ok := findKeyword(ingredients, allergen.keywords, allergen.exclusions); ok {
+				reason := "содержит аллерген: " + strings.ToLower(allergen.Name)
+				if found != strings.ToLower(allergen.Name) {
+					reason += " (" + found + ")"
+				}
+				addUnsuitable(result, reason)
+			}
+		}
+	}
+
+	for _, id := range profile.Diets {
+		diet, ok := DietByID(id)
+		if !ok {
+			continue
+		}
+		if reason := a.dietViolation(diet, product, ingredients); reason != "" {
+			addUnsuitable(result, fmt.Sprintf("%s: %s", strings.ToLower(diet.Name), reason))
+		}
+	}
+
+	for _, avoid := range profile.Avoid {
+		if found, ok := findKeyword(ingredients, tokenizePhrases([]string{avoid}), nil); ok {
+			addUnsuitable(result, "содержит то, чего вы избегаете: "+found)
+		}
+	}
+}
+
+// dietViolation возвращает причину несоответствия диете или пустую строку
+func (a *Analyzer) dietViolation(diet Diet, product *models.Product, ingredients []*IngredientNode) string {
+	for _, label := range diet.labels {
+		if slices.Contains(product.LabelsTags, label) {
+			return ""
+		}
+	}
+
+	switch diet.ID {
+	case DietVegan, DietVegetarian:
+		// Open Food Facts сам размечает ингредиенты, если знает их
+		for _, ingredient := range product.Ingredients {
+			status := ingredient.Vegan
+			if diet.ID == DietVegetarian {
+				status = ingredient.Vegetarian
+			}
+			if status == "no" {
+				return "содержит " + ingredientName(ingredient)
+			}
+		}
+	case DietLactoseFree:
+		if slices.Contains(product.AllergensTags, "en:milk") {
+			return "содержит молочные ингредиенты"
+		}
+	case DietDiabetic:
+		if sugars, ok := models.NumberValue(product.Nutriments.Sugars); ok {
+			if sugars > diabeticSugarLimit {
+				return fmt.Sprintf("много сахара (%s г на 100 г)", formatAmount(sugars))
+			}
+			return ""
+		}
+	}
+
+	if found, ok := findKeyword(ingredients, diet.keywords, diet.exclusions); ok {
+		return "содержит " + found
+	}
+	return ""
+}
+
+// productIngredients разбирает состав продукта для поиска ключевых слов
+func productIngredients(product *models.Product) []*IngredientNode {
+	nodes := ParseIngredients(product.DisplayComposition())
+	for _, ingredient := range product.Ingredients {
+		nodes = append(nodes, ParseIngredients(ingredient.Text)...)
+	}
+	return nodes
+}
+
+// findKeyword возвращает исходный текст первого ингредиента с ключевым словом.
+// Слово внутри фразы-исключения ("кокосовое молоко" для "молоко") не считается.
+func findKeyword(nodes []*IngredientNode, keywords [][]string, exclusions [][]string) (string, bool) {
+	var found string
+	for _, root := range nodes {
+		root.Walk(func(node *IngredientNode) {
+			if found != "" {
+				return
+			}
+			for _, keyword := range keywords {
+				if containsOutside(node.Tokens, keyword, exclusions) {
+					found = strings.ToLower(node.Text)
+					return
+				}
+			}
+		})
+		if found != "" {
+			return found, true
+		}
+	}
+	return "", false
+}
+
+// containsOutside - встречается ли pattern в tokens вне фраз-исключений
+func containsOutside(tokens []Token, pattern []string, exclusions [][]string) bool {
+	for i := 0; i+len(pattern) <= len(tokens); i++ {
+		if matchesAt(tokens, i, pattern) && !excludedAt(tokens, i, len(pattern), exclusions) {
+			return true
+		}
+	}
+	return false
+}
+
+// excludedAt - покрывает ли какая-нибудь фраза-исключение токены [i, i+n)
+func excludedAt(tokens []Token, i, n int, exclusions [][]string) bool {
+	for _, exclusion := range exclusions {
+		for start := max(0, i+n-len(exclusion)); start <= i && start+len(exclusion) <= len(tokens); start++ {
+			if matchesAt(tokens, start, exclusion) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func matchesAt(tokens []Token, i int, pattern []string) bool {
+	for j, word := range pattern {
+		if !tokenMatches(tokens[i+j].Text, word) {
+			return false
+		}
+	}
+	return true
+}
+
+func ingredientName(ingredient models.Ingredient) string {
+	if ingredient.Text != "" {
+		return strings.ToLower(ingredient.Text)
+	}
+	_, name, _ := strings.Cut(ingredient.ID, ":")
+	return strings.ReplaceAll(name, "-", " ")
+}
+
+func addUnsuitable(result *models.AnalysisResult, reason string) {
+	result.Unsuitable = utils.AppendIfNotExists(result.Unsuitable, reason)
+}
+
+func formatAmount(value float64) string {
+	return strings.TrimSuffix(strings.TrimRight(fmt.Sprintf("%.1f", value), "0"), ".")
+}
+
+// normalizePhrase приводит фразу к основам слов: "Орехов" -> "орех"
+func normalizePhrase(phrase string) string {
+	tokens := Tokenize(phrase)
+	words := make([]string, len(tokens))
+	for i, tok := range tokens {
+		words[i] = tok.Text
+	}
+	return strings.Join(words, " ")
+}
+
+func normalizePhrases(phrases []string) []string {
+	normalized := make([]string, 0, len(phrases))
+	for _, phrase := range phrases {
+		normalized = append(normalized, normalizePhrase(phrase))
+	}
+	return normalized
+}
+
+func tokenizePhrases(phrases []string) [][]string {
+	patterns := make([][]string, 0, len(phrases))
+	for _, phrase := range phrases {
+		if normalized := normalizePhrase(phrase); normalized != "" {
+			patterns = append(patterns, strings.Fields(normalized))
+		}
+	}
+	return patterns
+}
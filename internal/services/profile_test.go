@@ -0,0 +1,200 @@
+package services
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ajeanett/telbot/internal/models"
+)
+
+func TestFindAllergenAndDiet(t *testing.T) {
+	for input, want := range map[string]string{
+		"глютен":   "en:gluten",
+		"Орехов":   "en:nuts",
+		"en:milk":  "en:milk",
+		" EN:EGGS": "en:eggs",
+		"кунжут":   "en:sesame-seeds",
+		"сульфиты": "en:sulphur-dioxide-and-sulphites",
+	} {
+		if allergen, ok := FindAllergen(input); !ok || allergen.Tag != want {
+			t.Errorf("FindAllergen(%q) = %q, %v; ожидался %s", input, allergen.Tag, ok, want)
+		}
+	}
+	if allergen, ok := FindAllergen("шоколад"); ok {
+		t.Errorf("FindAllergen(шоколад) = %s, ожидалось не найдено", allergen.Tag)
+	}
+
+	for input, want := range map[string]string{
+		"веган":          DietVegan,
+		"Вегетарианство": DietVegetarian,
+		"халал":          DietHalal,
+		"без лактозы":    DietLactoseFree,
+		"diabetic":       DietDiabetic,
+		"lactose_free":   DietLactoseFree,
+		"непереносимость лактозы": DietLactoseFree,
+	} {
+		if diet, ok := FindDiet(input); !ok || diet.ID != want {
+			t.Errorf("FindDiet(%q) = %q, %v; ожидался %s", input, diet.ID, ok, want)
+		}
+	}
+	if diet, ok := FindDiet("кето"); ok {
+		t.Errorf("FindDiet(кето) = %s, ожидалось не найдено", diet.ID)
+	}
+}
+
+func TestPersonalize(t *testing.T) {
+	tests := []struct {
+		name    string
+		product models.Product
+		profile models.UserProfile
+		want    []string // ожидаемые причины; пусто - продукт подходит
+	}{
+		{
+			"аллерген по тегу",
+			models.Product{CompositionRu: "мука, вода", AllergensTags: []string{"en:gluten"}},
+			models.UserProfile{Allergens: []string{"en:gluten"}},
+			[]string{"содержит аллерген: глютен"},
+		},
+		{
+			"следы аллергена",
+			models.Product{CompositionRu: "какао, сахар", AllergensTags: []string{"en:milk"}, TracesTags: []string{"en:nuts"}},
+			models.UserProfile{Allergens: []string{"en:nuts"}},
+			[]string{"может содержать следы: орехи"},
+		},
+		{
+			"аллерген по составу без тегов",
+			models.Product{CompositionRu: "сахар, сухое молоко, какао"},
+			models.UserProfile{Allergens: []string{"en:milk"}},
+			[]string{"содержит аллерген: молоко (сухое молоко)"},
+		},
+		{
+			"теги важнее состава",
+			models.Product{CompositionRu: "сахар, сыворотка", AllergensTags: []string{"en:soybeans"}},
+			models.UserProfile{Allergens: []string{"en:milk"}},
+			nil,
+		},
+		{
+			"кокосовое молоко - не молоко",
+			models.Product{CompositionRu: "кокосовое молоко 60%, вода, гуаровая камедь"},
+			models.UserProfile{Allergens: []string{"en:milk"}, Diets: []string{DietVegan, DietLactoseFree}},
+			nil,
+		},
+		{
+			"молочная кислота - не молоко",
+			models.Product{CompositionRu: "огурцы, вода, соль, регулятор кислотности молочная кислота"},
+			models.UserProfile{Allergens: []string{"en:milk"}, Diets: []string{DietVegan}},
+			nil,
+		},
+		{
+			"кокосовое молоко рядом с коровьим",
+			models.Product{CompositionRu: "кокосовое молоко, молоко цельное"},
+			models.UserProfile{Allergens: []string{"en:milk"}},
+			[]string{"содержит аллерген: молоко (молоко цельное)"},
+		},
+		{
+			"мускатный орех - не орехи",
+			models.Product{CompositionRu: "картофель, соль, специи (перец, мускатный орех)"},
+			models.UserProfile{Allergens: []string{"en:nuts"}},
+			nil,
+		},
+		{
+			"фундук - орехи",
+			models.Product{CompositionRu: "шоколад, фундук дробленый"},
+			models.UserProfile{Allergens: []string{"en:nuts"}},
+			[]string{"содержит аллерген: орехи (фундук дробленый)"},
+		},
+		{
+			"винный уксус - халяль",
+			models.Product{CompositionRu: "вода, горчичное семя, винный уксус, соль"},
+			models.UserProfile{Diets: []string{DietHalal}},
+			nil,
+		},
+		{
+			"вино - не халяль",
+			models.Product{CompositionRu: "говядина, вино красное, лук"},
+			models.UserProfile{Diets: []string{DietHalal}},
+			[]string{"халяль: содержит вино красное"},
+		},
+		{
+			"метка халяль",
+			models.Product{CompositionRu: "говядина, вино красное", LabelsTags: []string{"en:halal"}},
+			models.UserProfile{Diets: []string{DietHalal}},
+			nil,
+		},
+		{
+			"разметка веганства от Open Food Facts",
+			models.Product{CompositionRu: "E120", Ingredients: []models.Ingredient{{ID: "en:cochineal", Vegan: "no"}}},
+			models.UserProfile{Diets: []string{DietVegan}},
+			[]string{"веганство: содержит cochineal"},
+		},
+		{
+			"диабет по сахару в пищевой ценности",
+			models.Product{CompositionRu: "мед, орехи", Nutriments: models.Nutriments{Sugars: json.Number("12.5")}},
+			models.UserProfile{Diets: []string{DietDiabetic}},
+			[]string{"диабет: много сахара (12.5 г на 100 г)"},
+		},
+		{
+			"диабет: сахара мало, состав не проверяется",
+			models.Product{CompositionRu: "вода, сахар", Nutriments: models.Nutriments{Sugars: json.Number("2")}},
+			models.UserProfile{Diets: []string{DietDiabetic}},
+			nil,
+		},
+		{
+			"свои исключения",
+			models.Product{CompositionRu: "мука, сахар-песок"},
+			models.UserProfile{Avoid: []string{"сахар"}},
+			[]string{"содержит то, чего вы избегаете: сахар-песок"},
+		},
+		{
+			"свои исключения во множественном числе",
+			models.Product{CompositionRu: "мука, пальмовые масла, сахар"},
+			models.UserProfile{Avoid: []string{"пальмовое масло"}},
+			[]string{"содержит то, чего вы избегаете: пальмовые масла"},
+		},
+	}
+
+	analyzer := NewAnalyzer()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			product := tt.product
+			product.Barcode = "1"
+			product.ProductType = models.ProductTypeFood
+			result := analyzer.AnalyzeProduct(&product)
+			analyzer.Personalize(result, &tt.profile)
+			if !slices.Equal(result.Unsuitable, tt.want) {
+				t.Errorf("причины %q, ожидались %q", result.Unsuitable, tt.want)
+			}
+		})
+	}
+}
+
+func TestPersonalizeEmptyProfile(t *testing.T) {
+	analyzer := NewAnalyzer()
+	result := analyzer.AnalyzeProduct(&models.Product{Barcode: "1", CompositionRu: "молоко, орехи", ProductType: models.ProductTypeFood})
+	analyzer.Personalize(result, nil)
+	analyzer.Personalize(result, &models.UserProfile{UserID: 1})
+	if len(result.Unsuitable) != 0 {
+		t.Errorf("без профиля получено %q", result.Unsuitable)
+	}
+}
+
+func TestProfileKeywordsAreNotExcludedByThemselves(t *testing.T) {
+	// Фраза-исключение, совпадающая с ключевым словом, отключила бы его целиком
+	check := func(name string, keywords, exclusions [][]string) {
+		for _, exclusion := range exclusions {
+			for _, keyword := range keywords {
+				if slices.Equal(keyword, exclusion) {
+					t.Errorf("%s: исключение %q совпадает с ключевым словом", name, strings.Join(exclusion, " "))
+				}
+			}
+		}
+	}
+	for _, allergen := range Allergens {
+		check(allergen.Name, allergen.keywords, allergen.exclusions)
+	}
+	for _, diet := range Diets {
+		check(diet.Name, diet.keywords, diet.exclusions)
+	}
+}
@@ -0,0 +1,108 @@
+// services/quota.go
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ErrQuotaExceeded - месячный лимит платного API исчерпан
+var ErrQuotaExceeded = errors.New("месячный лимит запросов исчерпан")
+
+// MonthlyQuota ограничивает число запросов к API за календарный месяц (UTC).
+// Счетчик сохраняется в файл, чтобы перезапуск не обнулял его.
+type MonthlyQuota struct {
+	limit int
+	path  string // пусто - счетчик только в памяти
+
+	mu    sync.Mutex
+	month string // "2006-01"
+	used  int
+}
+
+type quotaState struct {
+	Month string `json:"month"`
+	Used  int    `json:"used"`
+}
+
+// NewMonthlyQuota загружает счетчик из path; отсутствующий файл - ноль запросов
+func NewMonthlyQuota(limit int, path string) (*MonthlyQuota, error) {
+	q := &MonthlyQuota{limit: limit, path: path, month: currentMonth()}
+	if path == "" {
+		return q, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return q, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения счетчика квоты: %w", err)
+	}
+	var state quotaState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("ошибка разбора счетчика квоты %s: %w", path, err)
+	}
+	if state.Month == q.month {
+		q.used = state.Used
+	}
+	return q, nil
+}
+
+// Take расходует один запрос; ErrQuotaExceeded - лимит на этот месяц исчерпан
+func (q *MonthlyQuota) Take() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if month := currentMonth(); month != q.month {
+		q.month, q.used = month, 0
+	}
+	if q.limit > 0 && q.used >= q.limit {
+		return ErrQuotaExceeded
+	}
+	q.used++
+	if err := q.save(); err != nil {
+		// Запрос все равно разрешаем: счетчик в памяти верен
+		log.Printf("⚠️ %v", err)
+	}
+	return nil
+}
+
+// Used возвращает число запросов в текущем месяце и лимит
+func (q *MonthlyQuota) Used() (used, limit int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if currentMonth() != q.month {
+		return 0, q.limit
+	}
+	return q.used, q.limit
+}
+
+// save записывает счетчик через временный файл, чтобы не оставить его обрезанным
+func (q *MonthlyQuota) save() error {
+	if q.path == "" {
+		return nil
+	}
+	data, err := json.Marshal(quotaState{Month: q.month, Used: q.used})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(q.path), 0o755); err != nil {
+		return fmt.Errorf("ошибка сохранения счетчика квоты: %w", err)
+	}
+	tmp := q.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("ошибка сохранения счетчика квоты: %w", err)
+	}
+	return os.Rename(tmp, q.path)
+}
+
+func currentMonth() string {
+	return time.Now().UTC().Format("2006-01")
+}
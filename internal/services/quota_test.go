@@ -0,0 +1,70 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMonthlyQuota(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quota", "vision.json")
+	quota, err := NewMonthlyQuota(2, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 2 {
+		if err := quota.Take(); err != nil {
+			t.Fatalf("запрос %d: %v", i+1, err)
+		}
+	}
+	if err := quota.Take(); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("сверх лимита: ошибка %v, ожидалась ErrQuotaExceeded", err)
+	}
+	if used, limit := quota.Used(); used != 2 || limit != 2 {
+		t.Errorf("израсходовано %d из %d, ожидалось 2 из 2", used, limit)
+	}
+
+	// Перезапуск не обнуляет счетчик
+	reloaded, err := NewMonthlyQuota(2, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := reloaded.Take(); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("после перезапуска: ошибка %v, ожидалась ErrQuotaExceeded", err)
+	}
+}
+
+func TestMonthlyQuotaNewMonth(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vision.json")
+	if err := os.WriteFile(path, []byte(`{"month":"2000-01","used":100}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	quota, err := NewMonthlyQuota(1, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := quota.Take(); err != nil {
+		t.Errorf("счетчик прошлого месяца не сброшен: %v", err)
+	}
+}
+
+func TestMonthlyQuotaErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vision.json")
+	if err := os.WriteFile(path, []byte("not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewMonthlyQuota(1, path); err == nil {
+		t.Error("испорченный файл счетчика принят")
+	}
+
+	unlimited, err := NewMonthlyQuota(0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 5 {
+		if err := unlimited.Take(); err != nil {
+			t.Fatalf("без лимита: %v", err)
+		}
+	}
+}
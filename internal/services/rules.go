@@ -0,0 +1,335 @@
+// services/rules.go
+package services
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ajeanett/telbot/internal/models"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Встроенная база правил: пищевые добавки E100-E1521, ингредиенты, косметика, корма
+//
+//go:embed rules/*.yaml
+var defaultRulesFS embed.FS
+
+const ruleFileVersion = 1
+
+// Уровни опасности правил
+const (
+	SeverityHigh   = "high"   // опасный ингредиент
+	SeverityMedium = "medium" // сомнительный ингредиент
+	SeverityLow    = "low"    // разрешенная добавка, о которой стоит знать
+	SeverityNone   = "none"   // безопасно, пользователю не показывается
+)
+
+var (
+	validSeverities   = []string{SeverityHigh, SeverityMedium, SeverityLow, SeverityNone}
+	validProductTypes = []string{models.ProductTypeFood, models.ProductTypeBeauty, models.ProductTypePetFood}
+	additiveCodeRe    = regexp.MustCompile(`^e\d{3,4}[a-z]?$`)
+)
+
+// Rule - правило проверки одного ингредиента или пищевой добавки
+type Rule struct {
+	ID    string `yaml:"id" json:"id"`
+	Code  string `yaml:"code" json:"code"` // E-номер в нижнем регистре, например e150a
+	Title string `yaml:"title" json:"title"`
+	// Названия и синонимы по языкам: {"ru": [...], "en": [...]}
+	Names        map[string][]string `yaml:"names" json:"names"`
+	Severity     string              `yaml:"severity" json:"severity"`
+	TypeSeverity map[string]string   `yaml:"type_severity" json:"type_severity"` // уровень для отдельных типов продуктов
+	Category     string              `yaml:"category" json:"category"`
+	Explanation  string              `yaml:"explanation" json:"explanation"`
+	Source       string              `yaml:"source" json:"source"`
+	// Типы продуктов, к которым применяется правило; пусто - тип из файла или все
+	ProductTypes []string `yaml:"product_types" json:"product_types"`
+
+	patterns [][]string // код и названия, разбитые на токены для поиска в составе
+}
+
+// SeverityFor возвращает уровень опасности для конкретного типа продукта
+func (r *Rule) SeverityFor(productType string) string {
+	if severity, ok := r.TypeSeverity[productType]; ok {
+		return severity
+	}
+	return r.Severity
+}
+
+// AppliesTo - применяется ли правило к продуктам этого типа
+func (r *Rule) AppliesTo(productType string) bool {
+	return len(r.ProductTypes) == 0 || slices.Contains(r.ProductTypes, productType)
+}
+
+type ruleFile struct {
+	Version      int      `yaml:"version" json:"version"`
+	ProductTypes []string `yaml:"product_types" json:"product_types"`
+	Rules        []Rule   `yaml:"rules" json:"rules"`
+}
+
+// RuleSet - проверенный набор правил. После загрузки не меняется,
+// поэтому безопасен для одновременного чтения из нескольких горутин.
+type RuleSet struct {
+	rules  []*Rule
+	byCode map[string]*Rule
+	byType map[string][]*Rule
+}
+
+// Len возвращает количество правил
+func (s *RuleSet) Len() int {
+	return len(s.rules)
+}
+
+// ForType возвращает правила для типа продукта; неизвестный тип считается едой
+func (s *RuleSet) ForType(productType string) []*Rule {
+	if rules, ok := s.byType[productType]; ok {
+		return rules
+	}
+	return s.byType[models.ProductTypeFood]
+}
+
+// ByCode ищет правило по E-номеру. Понимает теги Open Food Facts с
+// подвидами добавок: en:e322i -> e322, en:e472e -> e472e.
+func (s *RuleSet) ByCode(code string) (*Rule, bool) {
+	code = strings.ToLower(code)
+	if _, after, ok := strings.Cut(code, ":"); ok {
+		code = after
+	}
+
+	for _, candidate := range []string{code, strings.TrimRight(code, "ivx"), strings.TrimRight(code, "abcdefghijklmnopqrstuvwxyz")} {
+		if rule, ok := s.byCode[candidate]; ok {
+			return rule, true
+		}
+	}
+	return nil, false
+}
+
+// DefaultRules возвращает встроенную базу правил
+func DefaultRules() (*RuleSet, error) {
+	return loadRulesFS(defaultRulesFS, "rules")
+}
+
+// LoadRules загружает правила из файла или из всех .yaml/.yml/.json файлов каталога
+func LoadRules(rulesPath string) (*RuleSet, error) {
+	info, err := os.Stat(rulesPath)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения правил: %w", err)
+	}
+	if info.IsDir() {
+		return loadRulesFS(os.DirFS(rulesPath), ".")
+	}
+	return loadRulesFS(os.DirFS(filepath.Dir(rulesPath)), ".", filepath.Base(rulesPath))
+}
+
+// loadRulesFS читает перечисленные файлы, а если их нет - все файлы правил в dir
+func loadRulesFS(fsys fs.FS, dir string, files ...string) (*RuleSet, error) {
+	if len(files) == 0 {
+		entries, err := fs.ReadDir(fsys, dir)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка чтения каталога правил: %w", err)
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() && isRuleFile(entry.Name()) {
+				files = append(files, path.Join(dir, entry.Name()))
+			}
+		}
+		sort.Strings(files)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("в каталоге нет файлов правил")
+	}
+
+	var parsed []ruleFile
+	for _, name := range files {
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка чтения %s: %w", name, err)
+		}
+		file, err := parseRuleFile(name, data)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, file)
+	}
+	return buildRuleSet(files, parsed)
+}
+
+func isRuleFile(name string) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".yaml", ".yml", ".json":
+		return true
+	}
+	return false
+}
+
+// parseRuleFile разбирает файл строго: неизвестные поля считаются ошибкой,
+// чтобы опечатка в имени поля не превратилась в молча пропущенное значение
+func parseRuleFile(name string, data []byte) (ruleFile, error) {
+	var file ruleFile
+	if strings.EqualFold(path.Ext(name), ".json") {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&file); err != nil {
+			return file, fmt.Errorf("ошибка парсинга %s: %w", name, err)
+		}
+		return file, nil
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil {
+		return file, fmt.Errorf("ошибка парсинга %s: %w", name, err)
+	}
+	return file, nil
+}
+
+// buildRuleSet проверяет правила и строит индексы. Все ошибки собираются
+// вместе, чтобы за один раз увидеть все проблемы файла.
+func buildRuleSet(names []string, files []ruleFile) (*RuleSet, error) {
+	set := &RuleSet{
+		byCode: make(map[string]*Rule),
+		byType: make(map[string][]*Rule),
+	}
+	seenIDs := make(map[string]string)
+
+	var errs []error
+	for i, file := range files {
+		name := names[i]
+		if file.Version != ruleFileVersion {
+			errs = append(errs, fmt.Errorf("%s: неподдерживаемая версия %d (ожидается %d)", name, file.Version, ruleFileVersion))
+			continue
+		}
+		if err := validateProductTypes(file.ProductTypes); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+
+		for j := range file.Rules {
+			rule := file.Rules[j]
+			if len(rule.ProductTypes) == 0 {
+				rule.ProductTypes = file.ProductTypes
+			}
+			rule.Code = strings.ToLower(rule.Code)
+
+			where := fmt.Sprintf("%s: правило #%d (%s)", name, j+1, rule.ID)
+			if ruleErrs := validateRule(&rule); len(ruleErrs) > 0 {
+				for _, err := range ruleErrs {
+					errs = append(errs, fmt.Errorf("%s: %w", where, err))
+				}
+				continue
+			}
+			if prev, ok := seenIDs[rule.ID]; ok {
+				errs = append(errs, fmt.Errorf("%s: id уже используется в %s", where, prev))
+				continue
+			}
+			seenIDs[rule.ID] = name
+
+			if rule.Code != "" {
+				if prev, ok := set.byCode[rule.Code]; ok {
+					errs = append(errs, fmt.Errorf("%s: код %s уже описан в правиле %s", where, rule.Code, prev.ID))
+					continue
+				}
+				set.byCode[rule.Code] = &rule
+			}
+
+			rule.patterns = rulePatterns(&rule)
+			set.rules = append(set.rules, &rule)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	if len(set.rules) == 0 {
+		return nil, fmt.Errorf("набор правил пуст")
+	}
+
+	for _, productType := range validProductTypes {
+		for _, rule := range set.rules {
+			if rule.AppliesTo(productType) {
+				set.byType[productType] = append(set.byType[productType], rule)
+			}
+		}
+	}
+	return set, nil
+}
+
+func validateRule(rule *Rule) []error {
+	var errs []error
+	if rule.ID == "" {
+		errs = append(errs, fmt.Errorf("не задан id"))
+	}
+	if rule.Title == "" {
+		errs = append(errs, fmt.Errorf("не задан title"))
+	}
+	if rule.Code != "" && !additiveCodeRe.MatchString(rule.Code) {
+		errs = append(errs, fmt.Errorf("некорректный код добавки %q", rule.Code))
+	}
+	if rule.Code == "" && countNames(rule) == 0 {
+		errs = append(errs, fmt.Errorf("нужен code или хотя бы одно название в names"))
+	}
+	if !slices.Contains(validSeverities, rule.Severity) {
+		errs = append(errs, fmt.Errorf("некорректный severity %q, допустимо: %s", rule.Severity, strings.Join(validSeverities, ", ")))
+	}
+	for productType, severity := range rule.TypeSeverity {
+		if !slices.Contains(validProductTypes, productType) {
+			errs = append(errs, fmt.Errorf("неизвестный тип продукта %q в type_severity", productType))
+		}
+		if !slices.Contains(validSeverities, severity) {
+			errs = append(errs, fmt.Errorf("некорректный severity %q в type_severity", severity))
+		}
+	}
+	if err := validateProductTypes(rule.ProductTypes); err != nil {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+func validateProductTypes(productTypes []string) error {
+	for _, productType := range productTypes {
+		if !slices.Contains(validProductTypes, productType) {
+			return fmt.Errorf("неизвестный тип продукта %q, допустимо: %s", productType, strings.Join(validProductTypes, ", "))
+		}
+	}
+	return nil
+}
+
+func countNames(rule *Rule) int {
+	count := 0
+	for _, names := range rule.Names {
+		count += len(names)
+	}
+	return count
+}
+
+// rulePatterns разбивает код и названия правила на токены тем же
+// токенизатором, что и состав, чтобы формы слов совпадали
+func rulePatterns(rule *Rule) [][]string {
+	var patterns [][]string
+	if rule.Code != "" {
+		patterns = append(patterns, []string{rule.Code})
+	}
+	for _, names := range rule.Names {
+		for _, name := range names {
+			tokens := Tokenize(name)
+			if len(tokens) == 0 {
+				continue
+			}
+			pattern := make([]string, len(tokens))
+			for i, tok := range tokens {
+				pattern[i] = tok.Text
+			}
+			patterns = append(patterns, pattern)
+		}
+	}
+	return patterns
+}
@@ -0,0 +1,215 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+const validRules = `version: 1
+product_types: [food]
+rules:
+  - id: e250
+    code: E250
+    title: "Нитрит натрия"
+    names:
+      ru: ["нитрит натрия"]
+    severity: high
+    category: preservative
+    explanation: "Консервант мяса."
+`
+
+func writeRules(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDefaultRules(t *testing.T) {
+	rules, err := DefaultRules()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rules.Len() < 300 {
+		t.Errorf("встроенных правил %d, ожидалось больше 300", rules.Len())
+	}
+	for _, rule := range rules.rules {
+		// Пользователю показываются правила с уровнем выше none, и у них должно быть объяснение
+		if rule.Severity != SeverityNone && rule.Explanation == "" {
+			t.Errorf("у показываемого правила %s нет explanation", rule.ID)
+		}
+	}
+
+	for code, want := range map[string]string{"e250": "e250", "en:e322i": "e322", "E472E": "e472e", "en:e150d": "e150d"} {
+		if rule, ok := rules.ByCode(code); !ok || rule.Code != want {
+			t.Errorf("ByCode(%q) = %v, ожидалось правило %s", code, rule, want)
+		}
+	}
+}
+
+func TestLoadRules(t *testing.T) {
+	dir := t.TempDir()
+	writeRules(t, dir, "additives.yaml", validRules)
+	writeRules(t, dir, "ingredients.json", `{"version": 1, "rules": [{"id": "palm", "title": "Пальмовое масло", "names": {"ru": ["пальмовое масло"]}, "severity": "medium"}]}`)
+	writeRules(t, dir, "README.md", "не файл правил")
+
+	rules, err := LoadRules(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rules.Len() != 2 {
+		t.Errorf("загружено %d правил, ожидалось 2", rules.Len())
+	}
+	if rule, ok := rules.ByCode("e250"); !ok || rule.Code != "e250" {
+		t.Error("код правила не приведен к нижнему регистру")
+	}
+	// Правило без product_types применяется ко всем типам, правило файла - только к еде
+	if got := len(rules.ForType("beauty")); got != 1 {
+		t.Errorf("правил для косметики %d, ожидалось 1", got)
+	}
+
+	single, err := LoadRules(filepath.Join(dir, "additives.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if single.Len() != 1 {
+		t.Errorf("из одного файла загружено %d правил, ожидалось 1", single.Len())
+	}
+}
+
+func TestLoadRulesInvalid(t *testing.T) {
+	rule := func(fields string) string {
+		return "version: 1\nrules:\n  - id: r1\n    title: \"Правило\"\n    names:\n      ru: [\"слово\"]\n" + fields
+	}
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  []string // фрагменты текста ошибки
+	}{
+		{"неизвестное поле YAML", map[string]string{"a.yaml": rule("    severity: high\n    severty: low\n")}, []string{"severty"}},
+		{"неизвестное поле JSON", map[string]string{"a.json": `{"version": 1, "rules": [{"id": "r1", "title": "x", "code": "e100", "severity": "low", "level": 1}]}`}, []string{"level"}},
+		{"неподдерживаемая версия", map[string]string{"a.yaml": strings.Replace(validRules, "version: 1", "version: 2", 1)}, []string{"версия 2"}},
+		{"неверный severity", map[string]string{"a.yaml": rule("    severity: critical\n")}, []string{`severity "critical"`}},
+		{"неверный код добавки", map[string]string{"a.yaml": rule("    code: x250\n    severity: high\n")}, []string{`"x250"`}},
+		{"нет кода и названий", map[string]string{"a.yaml": "version: 1\nrules:\n  - id: r1\n    title: x\n    severity: low\n"}, []string{"нужен code"}},
+		{"неизвестный тип продукта", map[string]string{"a.yaml": rule("    severity: low\n    product_types: [toys]\n")}, []string{`"toys"`}},
+		{"неверный type_severity", map[string]string{"a.yaml": rule("    severity: low\n    type_severity: {petfood: fatal}\n")}, []string{`"fatal"`}},
+		{"повтор id в разных файлах", map[string]string{"a.yaml": validRules, "b.yaml": validRules}, []string{"id уже используется"}},
+		{"повтор кода", map[string]string{"a.yaml": validRules, "b.yaml": strings.Replace(validRules, "id: e250", "id: nitrite", 1)}, []string{"код e250 уже описан"}},
+		{"пустой набор", map[string]string{"a.yaml": "version: 1\nrules: []\n"}, []string{"набор правил пуст"}},
+		{"нет файлов правил", map[string]string{"notes.txt": "x"}, []string{"нет файлов правил"}},
+		{
+			"все ошибки сразу",
+			map[string]string{"a.yaml": "version: 1\nrules:\n  - id: r1\n    severity: bad\n    code: e1\n"},
+			[]string{"не задан title", `severity "bad"`, `"e1"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				writeRules(t, dir, name, content)
+			}
+			rules, err := LoadRules(dir)
+			if err == nil {
+				t.Fatalf("правила загружены (%d), ожидалась ошибка", rules.Len())
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("в ошибке нет %q: %v", want, err)
+				}
+			}
+		})
+	}
+
+	if _, err := LoadRules(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("отсутствующий путь не дал ошибки")
+	}
+}
+
+// waitForRules ждет, пока в анализаторе окажется n правил
+func waitForRules(t *testing.T, analyzer *Analyzer, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for analyzer.Rules().Len() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("правил %d, ожидалось %d", analyzer.Rules().Len(), n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestRuleWatcherReloadsChangedFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeRules(t, dir, "rules.yaml", validRules)
+	analyzer := NewAnalyzer()
+	rules, err := LoadRules(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	analyzer.SetRules(rules)
+
+	watcher := WatchRules(analyzer, dir, 10*time.Millisecond)
+	defer watcher.Stop()
+
+	second := strings.Replace(validRules, "id: e250", "id: e251", 1)
+	second = strings.Replace(second, "code: E250", "code: E251", 1)
+	writeRules(t, dir, "more.yaml", second)
+	waitForRules(t, analyzer, 2)
+
+	// Испорченный файл не загружается, остается прежний набор
+	writeRules(t, dir, "more.yaml", second+"    oops: true\n")
+	time.Sleep(100 * time.Millisecond)
+	if analyzer.Rules().Len() != 2 {
+		t.Errorf("после ошибочной перезагрузки правил %d, ожидался прежний набор из 2", analyzer.Rules().Len())
+	}
+	if _, ok := analyzer.Rules().ByCode("e251"); !ok {
+		t.Error("после ошибочной перезагрузки пропало правило e251")
+	}
+
+	// Без испорченного файла набор снова загружается
+	if err := os.Remove(filepath.Join(dir, "more.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	waitForRules(t, analyzer, 1)
+}
+
+func TestRuleWatcherReloadsOnSIGHUP(t *testing.T) {
+	dir := t.TempDir()
+	path := writeRules(t, dir, "rules.yaml", validRules)
+	analyzer := NewAnalyzer()
+	if analyzer.Rules().Len() == 1 {
+		t.Fatal("встроенный набор не должен совпадать по размеру с тестовым")
+	}
+
+	// Без опроса файлов правила меняются только по SIGHUP
+	watcher := WatchRules(analyzer, path, 0)
+	defer watcher.Stop()
+
+	if err := syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatal(err)
+	}
+	waitForRules(t, analyzer, 1)
+}
+
+func TestRuleWatcherReload(t *testing.T) {
+	dir := t.TempDir()
+	path := writeRules(t, dir, "rules.yaml", "version: 1\nrules: [")
+	analyzer := NewAnalyzer()
+	before := analyzer.Rules()
+
+	watcher := WatchRules(analyzer, path, 0)
+	defer watcher.Stop()
+	if err := watcher.Reload(); err == nil {
+		t.Error("испорченный файл загружен")
+	}
+	if analyzer.Rules() != before {
+		t.Error("после ошибки загрузки правила анализатора заменены")
+	}
+}
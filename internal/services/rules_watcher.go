@@ -0,0 +1,145 @@
+// services/rules_watcher.go
+package services
+
+import (
+	"io/fs"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// RuleWatcher перезагружает правила анализатора по SIGHUP и при изменении
+// файлов. Если новые правила не проходят проверку, остается прежний набор.
+type RuleWatcher struct {
+	analyzer *Analyzer
+	path     string
+	interval time.Duration
+
+	snapshot map[string]fileState
+	hup      chan os.Signal
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// WatchRules запускает наблюдение за файлом или каталогом правил.
+// interval == 0 отключает опрос файлов, остается только SIGHUP.
+func WatchRules(analyzer *Analyzer, path string, interval time.Duration) *RuleWatcher {
+	w := &RuleWatcher{
+		analyzer: analyzer,
+		path:     path,
+		interval: interval,
+		snapshot: snapshotRules(path),
+		hup:      make(chan os.Signal, 1),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	// Подписка до запуска горутины: SIGHUP сразу после старта не должен
+	// завершить процесс обработчиком по умолчанию
+	signal.Notify(w.hup, syscall.SIGHUP)
+	go w.run()
+	return w
+}
+
+// Reload загружает правила и подменяет их в анализаторе
+func (w *RuleWatcher) Reload() error {
+	rules, err := LoadRules(w.path)
+	if err != nil {
+		return err
+	}
+	w.analyzer.SetRules(rules)
+	return nil
+}
+
+// Stop останавливает наблюдение
+func (w *RuleWatcher) Stop() {
+	close(w.stop)
+	<-w.done
+}
+
+func (w *RuleWatcher) run() {
+	defer close(w.done)
+	defer signal.Stop(w.hup)
+
+	var tick <-chan time.Time
+	if w.interval > 0 {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-w.hup:
+			log.Println("Получен SIGHUP, перезагружаю правила анализа")
+			w.snapshot = snapshotRules(w.path)
+			w.reload()
+		case <-tick:
+			current := snapshotRules(w.path)
+			if sameSnapshot(w.snapshot, current) {
+				continue
+			}
+			w.snapshot = current
+			log.Println("Файлы правил изменились, перезагружаю")
+			w.reload()
+		}
+	}
+}
+
+func (w *RuleWatcher) reload() {
+	if err := w.Reload(); err != nil {
+		log.Printf("❌ Правила не загружены, остается прежний набор: %v", err)
+		return
+	}
+	log.Printf("✅ Правила анализа перезагружены: %d правил", w.analyzer.Rules().Len())
+}
+
+// snapshotRules запоминает время изменения и размер файлов правил
+func snapshotRules(path string) map[string]fileState {
+	snapshot := make(map[string]fileState)
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return snapshot
+	}
+	if !info.IsDir() {
+		snapshot[path] = fileState{modTime: info.ModTime(), size: info.Size()}
+		return snapshot
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return snapshot
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !isRuleFile(entry.Name()) {
+			continue
+		}
+		var info fs.FileInfo
+		if info, err = entry.Info(); err == nil {
+			snapshot[filepath.Join(path, entry.Name())] = fileState{modTime: info.ModTime(), size: info.Size()}
+		}
+	}
+	return snapshot
+}
+
+func sameSnapshot(a, b map[string]fileState) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, state := range a {
+		if other, ok := b[name]; !ok || !other.modTime.Equal(state.modTime) || other.size != state.size {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ajeanett/telbot/internal/models"
+)
+
+// findingWeights - сколько баллов из 100 снимает находка в зависимости от
+// категории и уровня. Опасная добавка весит больше, чем весь "светофор"
+// пищевой ценности, заявленный аллерген - меньше всего: он важен только
+// тем, у кого на него аллергия, и это учитывает профиль.
+var findingWeights = map[string]map[string]int{
+	models.FindingAdditives:  {SeverityHigh: 30, SeverityMedium: 10, SeverityLow: 4},
+	models.FindingNutrition:  {SeverityHigh: 10, SeverityMedium: 3},
+	models.FindingProcessing: {SeverityHigh: 15, SeverityMedium: 6, SeverityLow: 2},
+	models.FindingAllergens:  {SeverityLow: 3},
+}
+
+// Nutri-Score весит отдельно от нутриентов: он учитывает и полезное (клетчатку, белок)
+var nutriScoreWeights = map[string]int{SeverityHigh: 22, SeverityMedium: 14, SeverityLow: 6}
+
+// Уровень Nutri-Score и группы NOVA; A, B и NOVA 1 не штрафуются
+var (
+	nutriScoreSeverity = map[string]string{"c": SeverityLow, "d": SeverityMedium, "e": SeverityHigh}
+	novaSeverity       = map[int]string{2: SeverityLow, 3: SeverityMedium, 4: SeverityHigh}
+)
+
+var novaTitles = map[int]string{
+	2: "Кулинарный ингредиент",
+	3: "Обработанный продукт",
+	4: "Ультраобработанный продукт",
+}
+
+// score дополняет находки по составу находками по пищевой ценности,
+// обработке и аллергенам, назначает каждой баллы по весам и считает оценку
+func score(product *models.Product, result *models.AnalysisResult) {
+	for _, n := range result.Nutrition {
+		if n.Level != models.LevelHigh && n.Level != models.LevelMedium {
+			continue
+		}
+		level := "среднее"
+		if n.Level == models.LevelHigh {
+			level = "высокое"
+		}
+		result.Findings = append(result.Findings, models.Finding{
+			Code:     n.Key,
+			Title:    fmt.Sprintf("%s: %s содержание", n.Name, level),
+			Category: models.FindingNutrition,
+			Severity: string(n.Level),
+			Text:     strconv.FormatFloat(n.Per100g, 'f', -1, 64) + " " + n.Unit,
+			Source:   models.SourceNutriments,
+		})
+	}
+
+	if severity, ok := nutriScoreSeverity[result.NutriScore]; ok {
+		grade := strings.ToUpper(result.NutriScore)
+		result.Findings = append(result.Findings, models.Finding{
+			Code:     "nutriscore",
+			Title:    "Nutri-Score " + grade,
+			Category: models.FindingNutrition,
+			Severity: severity,
+			Text:     grade,
+			Source:   models.SourceNutriScore,
+		})
+	}
+
+	if severity, ok := novaSeverity[result.NovaGroup]; ok {
+		result.Findings = append(result.Findings, models.Finding{
+			Code:     "nova",
+			Title:    fmt.Sprintf("%s (NOVA %d)", novaTitles[result.NovaGroup], result.NovaGroup),
+			Category: models.FindingProcessing,
+			Severity: severity,
+			Text:     fmt.Sprint(result.NovaGroup),
+			Source:   models.SourceNova,
+		})
+	}
+
+	for _, tag := range product.AllergensTags {
+		if allergen, ok := AllergenByTag(tag); ok {
+			result.Findings = append(result.Findings, models.Finding{
+				Code:     allergen.Tag,
+				Title:    "Аллерген: " + strings.ToLower(allergen.Name),
+				Category: models.FindingAllergens,
+				Severity: SeverityLow,
+				Text:     tag,
+				Source:   models.SourceAllergens,
+			})
+		}
+	}
+
+	points := 100
+	for i := range result.Findings {
+		f := &result.Findings[i]
+		f.Points = findingPoints(*f)
+		points -= f.Points
+	}
+	result.Score = max(0, points)
+}
+
+func findingPoints(f models.Finding) int {
+	if f.Source == models.SourceNutriScore {
+		return nutriScoreWeights[f.Severity]
+	}
+	return findingWeights[f.Category][f.Severity]
+}
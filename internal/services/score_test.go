@@ -0,0 +1,155 @@
+package services
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/ajeanett/telbot/internal/models"
+)
+
+// offProducts читает продукты Open Food Facts из ответа поиска API,
+// сокращенного до полей, которые нужны анализу
+func offProducts(t *testing.T) map[string]*models.Product {
+	t.Helper()
+	data, err := os.ReadFile("testdata/score_products.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var response struct {
+		Products []models.Product `json:"products"`
+	}
+	if err := json.Unmarshal(data, &response); err != nil {
+		t.Fatal(err)
+	}
+	products := make(map[string]*models.Product)
+	for i := range response.Products {
+		products[response.Products[i].Barcode] = &response.Products[i]
+	}
+	return products
+}
+
+// findingByCode возвращает находку с кодом code
+func findingByCode(result *models.AnalysisResult, code string) (models.Finding, bool) {
+	for _, f := range result.Findings {
+		if f.Code == code {
+			return f, true
+		}
+	}
+	return models.Finding{}, false
+}
+
+// TestScoreOfficialGrades сверяет оценку с официальными Nutri-Score и NOVA
+// продуктов в Open Food Facts: у продуктов A и NOVA 1 оценка высокая,
+// у E и NOVA 4 - низкая
+func TestScoreOfficialGrades(t *testing.T) {
+	products := offProducts(t)
+	tests := []struct {
+		barcode    string
+		name       string
+		nutriScore string
+		nova       int
+		minScore   int
+		maxScore   int
+	}{
+		{"3274080005003", "вода Cristaline", "a", 1, 95, 100},
+		{"8076800195057", "спагетти Barilla", "a", 1, 90, 100},
+		{"5449000000996", "Coca-Cola", "e", 4, 0, 30},
+		{"3017620422003", "Nutella", "e", 4, 0, 30},
+	}
+
+	analyzer := NewAnalyzer()
+	scores := make(map[string]int)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			product, ok := products[tt.barcode]
+			if !ok {
+				t.Fatalf("продукта %s нет в testdata", tt.barcode)
+			}
+			result := analyzer.AnalyzeProduct(product)
+			scores[tt.barcode] = result.Score
+
+			if result.NutriScore != tt.nutriScore || result.NovaGroup != tt.nova {
+				t.Errorf("Nutri-Score %q, NOVA %d; в Open Food Facts %q, %d", result.NutriScore, result.NovaGroup, tt.nutriScore, tt.nova)
+			}
+			if result.Score < tt.minScore || result.Score > tt.maxScore {
+				t.Errorf("оценка %d, ожидалась от %d до %d; находки: %+v", result.Score, tt.minScore, tt.maxScore, result.Findings)
+			}
+
+			// A и NOVA 1 не штрафуются, E и NOVA 4 - по самому высокому уровню
+			nutri, hasNutri := findingByCode(result, "nutriscore")
+			nova, hasNova := findingByCode(result, "nova")
+			if tt.nutriScore == "a" && hasNutri || tt.nova == 1 && hasNova {
+				t.Errorf("хорошие оценки дали находки: %+v %+v", nutri, nova)
+			}
+			if tt.nutriScore == "e" && (!hasNutri || nutri.Severity != SeverityHigh || nutri.Points != nutriScoreWeights[SeverityHigh]) {
+				t.Errorf("находка Nutri-Score E: %+v", nutri)
+			}
+			if tt.nova == 4 && (!hasNova || nova.Severity != SeverityHigh || nova.Points != findingWeights[models.FindingProcessing][SeverityHigh]) {
+				t.Errorf("находка NOVA 4: %+v", nova)
+			}
+		})
+	}
+
+	// Любой продукт A/NOVA 1 оценивается выше любого E/NOVA 4
+	for _, good := range []string{"3274080005003", "8076800195057"} {
+		for _, bad := range []string{"5449000000996", "3017620422003"} {
+			if scores[good] <= scores[bad] {
+				t.Errorf("оценка %s (%d) не выше, чем у %s (%d)", good, scores[good], bad, scores[bad])
+			}
+		}
+	}
+}
+
+func TestScorePoints(t *testing.T) {
+	tests := []struct {
+		name       string
+		nutriScore string
+		nova       int
+		nutrition  []models.NutrientInfo
+		allergens  []string
+		dangerous  int // опасных добавок, найденных по составу
+		want       int
+	}{
+		{"нет данных", "", 0, nil, nil, 0, 100},
+		{"A и NOVA 1", "a", 1, nil, nil, 0, 100},
+		{"B и NOVA 2", "b", 2, nil, nil, 0, 98},
+		{"C и NOVA 3", "c", 3, nil, nil, 0, 88},
+		{"D и NOVA 4", "d", 4, nil, nil, 0, 71},
+		{"E и NOVA 4", "e", 4, nil, nil, 0, 63},
+		{"светофор", "", 0, []models.NutrientInfo{
+			{Key: "sugars", Name: "Сахар", Level: models.LevelHigh},
+			{Key: "salt", Name: "Соль", Level: models.LevelMedium},
+			{Key: "fat", Name: "Жиры", Level: models.LevelLow},
+		}, nil, 0, 87},
+		{"аллергены", "", 0, nil, []string{"en:milk", "en:gluten", "en:unknown-tag"}, 0, 94},
+		{"опасная добавка", "c", 0, nil, nil, 1, 64},
+		{"не ниже нуля", "e", 4, []models.NutrientInfo{
+			{Key: "fat", Level: models.LevelHigh},
+			{Key: "saturated-fat", Level: models.LevelHigh},
+			{Key: "sugars", Level: models.LevelHigh},
+			{Key: "salt", Level: models.LevelHigh},
+		}, []string{"en:milk"}, 2, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			product := &models.Product{AllergensTags: tt.allergens}
+			result := &models.AnalysisResult{Product: product, NutriScore: tt.nutriScore, NovaGroup: tt.nova, Nutrition: tt.nutrition}
+			for range tt.dangerous {
+				result.Findings = append(result.Findings, models.Finding{Category: models.FindingAdditives, Severity: SeverityHigh})
+			}
+			score(product, result)
+			if result.Score != tt.want {
+				t.Errorf("оценка %d, ожидалась %d; находки: %+v", result.Score, tt.want, result.Findings)
+			}
+			// Оценка складывается ровно из баллов находок
+			points := 0
+			for _, f := range result.Findings {
+				points += f.Points
+			}
+			if want := max(0, 100-points); result.Score != want {
+				t.Errorf("оценка %d не равна 100 минус баллы находок (%d)", result.Score, points)
+			}
+		})
+	}
+}
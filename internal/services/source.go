@@ -0,0 +1,62 @@
+// services/source.go
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/ajeanett/telbot/internal/models"
+	"log"
+)
+
+// ProductSource - база данных, в которой можно найти продукт по штрих-коду.
+// Lookup возвращает ErrProductNotFound, если продукта в базе нет.
+type ProductSource interface {
+	Name() string
+	Lookup(ctx context.Context, barcode string) (*models.Product, error)
+}
+
+// SourceChain опрашивает источники по порядку и возвращает первый найденный продукт
+type SourceChain struct {
+	sources []ProductSource
+}
+
+func NewSourceChain(sources ...ProductSource) *SourceChain {
+	return &SourceChain{sources: sources}
+}
+
+func (c *SourceChain) Name() string {
+	return "chain"
+}
+
+// Lookup возвращает ErrProductNotFound только если все источники ответили "не найден".
+// Если какой-то источник был недоступен, возвращается его ошибка, чтобы
+// отрицательный ответ не попал в кэш.
+func (c *SourceChain) Lookup(ctx context.Context, barcode string) (*models.Product, error) {
+	var sourceErr error
+	for _, source := range c.sources {
+		product, err := source.Lookup(ctx, barcode)
+		if err == nil {
+			product.Source = source.Name()
+			if product.ProductType == "" {
+				product.ProductType = models.ProductTypeFood
+			}
+			return product, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if errors.Is(err, ErrProductNotFound) {
+			continue
+		}
+
+		log.Printf("Источник %s недоступен для %s: %v", source.Name(), barcode, err)
+		if sourceErr == nil {
+			sourceErr = err
+		}
+	}
+
+	if sourceErr != nil {
+		return nil, sourceErr
+	}
+	return nil, ErrProductNotFound
+}
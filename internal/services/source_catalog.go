@@ -0,0 +1,113 @@
+// services/source_catalog.go
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ajeanett/telbot/internal/models"
+	"github.com/ajeanett/telbot/internal/storage"
+	"os"
+	"strings"
+)
+
+// OpenLocalCatalog открывает каталог по LOCAL_CATALOG_PATH: sqlite://путь -
+// таблица catalog_products в базе SQLite, иначе путь к JSON-файлу.
+// close освобождает базу; у JSON-каталога закрывать нечего.
+func OpenLocalCatalog(path string) (source ProductSource, count int, close func() error, err error) {
+	dbPath, isSQLite := strings.CutPrefix(path, "sqlite://")
+	if !isSQLite {
+		catalog, err := NewLocalCatalogSource(path)
+		if err != nil {
+			return nil, 0, nil, err
+		}
+		return catalog, catalog.Len(), func() error { return nil }, nil
+	}
+
+	store, err := storage.OpenSQLite(dbPath)
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("ошибка открытия каталога: %w", err)
+	}
+	count, err = store.Catalog().Count(context.Background())
+	if err != nil {
+		store.Close()
+		return nil, 0, nil, err
+	}
+	return NewSQLiteCatalogSource(store.Catalog()), count, store.Close, nil
+}
+
+// LocalCatalogSource - собственный каталог региональных продуктов,
+// которых нет в Open Food Facts. Файл - JSON-массив продуктов в формате
+// Open Food Facts (code, product_name, ingredients_text, ...).
+// Каталог целиком загружается в память; большой каталог удобнее вести
+// в SQLite (SQLiteCatalogSource).
+type LocalCatalogSource struct {
+	products map[string]models.Product
+}
+
+// NewLocalCatalogSource загружает каталог из файла.
+// Отсутствующий файл не ошибка - каталог просто пустой.
+func NewLocalCatalogSource(path string) (*LocalCatalogSource, error) {
+	source := &LocalCatalogSource{products: make(map[string]models.Product)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return source, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения каталога: %w", err)
+	}
+
+	var products []models.Product
+	if err := json.Unmarshal(data, &products); err != nil {
+		return nil, fmt.Errorf("ошибка парсинга каталога %s: %w", path, err)
+	}
+
+	for _, product := range products {
+		if product.Barcode == "" {
+			return nil, fmt.Errorf("в каталоге %s есть продукт без штрих-кода", path)
+		}
+		source.products[product.Barcode] = product
+	}
+	return source, nil
+}
+
+func (s *LocalCatalogSource) Name() string {
+	return "Локальный каталог"
+}
+
+func (s *LocalCatalogSource) Len() int {
+	return len(s.products)
+}
+
+func (s *LocalCatalogSource) Lookup(_ context.Context, barcode string) (*models.Product, error) {
+	product, ok := s.products[barcode]
+	if !ok {
+		return nil, ErrProductNotFound
+	}
+	return &product, nil
+}
+
+// SQLiteCatalogSource - тот же каталог в таблице catalog_products базы SQLite.
+// Продукты читаются по запросу, а не загружаются в память, и каталог можно
+// пополнять без перезапуска бота.
+type SQLiteCatalogSource struct {
+	catalog storage.CatalogRepository
+}
+
+func NewSQLiteCatalogSource(catalog storage.CatalogRepository) *SQLiteCatalogSource {
+	return &SQLiteCatalogSource{catalog: catalog}
+}
+
+func (s *SQLiteCatalogSource) Name() string {
+	return "Локальный каталог"
+}
+
+func (s *SQLiteCatalogSource) Lookup(ctx context.Context, barcode string) (*models.Product, error) {
+	product, err := s.catalog.Get(ctx, barcode)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, ErrProductNotFound
+	}
+	return product, err
+}
@@ -0,0 +1,51 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ajeanett/telbot/internal/models"
+	"github.com/ajeanett/telbot/internal/storage"
+)
+
+func TestOpenLocalCatalog(t *testing.T) {
+	dir := t.TempDir()
+	jsonPath := filepath.Join(dir, "catalog.json")
+	if err := os.WriteFile(jsonPath, []byte(`[{"code":"4600000000015","product_name":"Кефир"}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	dbPath := filepath.Join(dir, "catalog.db")
+	store, err := storage.OpenSQLite(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Catalog().Put(context.Background(), &models.Product{Barcode: "4600000000015", Name: "Кефир"}); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	for _, path := range []string{jsonPath, "sqlite://" + dbPath} {
+		source, count, closeCatalog, err := OpenLocalCatalog(path)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		if count != 1 {
+			t.Errorf("%s: продуктов %d, ожидался 1", path, count)
+		}
+
+		product, err := source.Lookup(context.Background(), "4600000000015")
+		if err != nil || product.Name != "Кефир" || product.Barcode != "4600000000015" {
+			t.Errorf("%s: Lookup = %+v, %v", path, product, err)
+		}
+		if _, err := source.Lookup(context.Background(), "4600000000022"); !errors.Is(err, ErrProductNotFound) {
+			t.Errorf("%s: Lookup отсутствующего продукта: %v, ожидалась ErrProductNotFound", path, err)
+		}
+		if err := closeCatalog(); err != nil {
+			t.Errorf("%s: %v", path, err)
+		}
+	}
+}
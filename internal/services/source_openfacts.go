@@ -0,0 +1,145 @@
+// services/source_openfacts.go
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/ajeanett/telbot/internal/models"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Версии API Open Food Facts
+const (
+	APIVersionV2 = "v2" // /api/v2 с выборкой полей (по умолчанию)
+	APIVersionV0 = "v0" // устаревший /api/v0, отдает весь документ продукта
+)
+
+// Базы проекта Open Food Facts с одинаковым API
+const (
+	OpenFoodFactsURL    = "https://world.openfoodfacts.org"
+	OpenBeautyFactsURL  = "https://world.openbeautyfacts.org"
+	OpenPetFoodFactsURL = "https://world.openpetfoodfacts.org"
+)
+
+// OpenFactsSource - источник на базе API Open Food Facts и родственных баз
+// (Open Beauty Facts, Open Pet Food Facts)
+type OpenFactsSource struct {
+	name        string
+	productType string // тип продукта по умолчанию, если база его не указала
+	apiURL      string // корень сайта, например https://world.openfoodfacts.org
+	apiVersion  string
+	fields      string // значение fields= для v2
+	client      *http.Client
+	retry       RetryPolicy
+}
+
+func NewOpenFactsSource(
+	name, productType, apiURL, apiVersion string,
+	client *http.Client,
+	retry RetryPolicy,
+) *OpenFactsSource {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if apiVersion != APIVersionV0 {
+		apiVersion = APIVersionV2
+	}
+	return &OpenFactsSource{
+		name:        name,
+		productType: productType,
+		apiURL:      strings.TrimRight(apiURL, "/"),
+		apiVersion:  apiVersion,
+		fields:      strings.Join(models.APIFields(), ","),
+		client:      client,
+		retry:       retry,
+	}
+}
+
+func (s *OpenFactsSource) Name() string {
+	return s.name
+}
+
+func (s *OpenFactsSource) Lookup(ctx context.Context, barcode string) (*models.Product, error) {
+	resp, err := getWithRetry(ctx, s.client, s.retry, s.productURL(barcode))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrProductNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: неожиданный ответ API (статус: %d)", ErrUpstreamUnavailable, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения ответа: %w", err)
+	}
+
+	var response models.APIResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("ошибка парсинга JSON: %w", err)
+	}
+
+	if response.Status != 1 {
+		return nil, ErrProductNotFound
+	}
+
+	product := &response.Product
+	if product.ProductType == "" {
+		product.ProductType = s.productType
+	}
+	return product, nil
+}
+
+func (s *OpenFactsSource) productURL(barcode string) string {
+	if s.apiVersion == APIVersionV0 {
+		return fmt.Sprintf("%s/api/v0/product/%s.json", s.apiURL, url.PathEscape(barcode))
+	}
+	// Имена полей состоят из латиницы, цифр, "_" и "-" - экранировать нечего
+	return fmt.Sprintf("%s/api/v2/product/%s?fields=%s", s.apiURL, url.PathEscape(barcode), s.fields)
+}
+
+// SearchByCategory ищет продукты категории, которые продаются в стране;
+// самые популярные первыми. Поиск есть только в API v2. Пустая страна - без
+// ограничения.
+func (s *OpenFactsSource) SearchByCategory(ctx context.Context, category, country string, limit int) ([]models.Product, error) {
+	query := url.Values{}
+	query.Set("categories_tags", category)
+	if country != "" {
+		query.Set("countries_tags", country)
+	}
+	query.Set("fields", s.fields)
+	query.Set("page_size", strconv.Itoa(limit))
+	query.Set("sort_by", "popularity_key")
+
+	resp, err := getWithRetry(ctx, s.client, s.retry, s.apiURL+"/api/v2/search?"+query.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: неожиданный ответ поиска (статус: %d)", ErrUpstreamUnavailable, resp.StatusCode)
+	}
+
+	var response models.SearchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("ошибка парсинга JSON: %w", err)
+	}
+	for i := range response.Products {
+		product := &response.Products[i]
+		product.Source = s.name
+		if product.ProductType == "" {
+			product.ProductType = s.productType
+		}
+	}
+	return response.Products, nil
+}
@@ -0,0 +1,142 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ajeanett/telbot/internal/models"
+)
+
+// openFactsServer отдает fixture из testdata со статусом status и запоминает запросы
+func openFactsServer(t *testing.T, status int, fixture string) (*httptest.Server, *[]*url.URL) {
+	t.Helper()
+	body, err := os.ReadFile(filepath.Join("testdata", fixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var requests []*url.URL
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(body)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+var noRetry = RetryPolicy{MaxAttempts: 1}
+
+func TestOpenFactsSourceLookup(t *testing.T) {
+	tests := []struct {
+		name       string
+		apiVersion string
+		fixture    string
+		path       string
+		fields     bool // запрашивается ли выборка полей
+	}{
+		{"v2", APIVersionV2, "product_v2.json", "/api/v2/product/4607001771234", true},
+		{"v0", APIVersionV0, "product_v0.json", "/api/v0/product/4607001771234.json", false},
+		{"неизвестная версия", "v3", "product_v2.json", "/api/v2/product/4607001771234", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, requests := openFactsServer(t, http.StatusOK, tt.fixture)
+			source := NewOpenFactsSource("Open Food Facts", models.ProductTypeFood, server.URL+"/", tt.apiVersion, server.Client(), noRetry)
+
+			product, err := source.Lookup(context.Background(), "4607001771234")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(*requests) != 1 {
+				t.Fatalf("запросов %d, ожидался 1", len(*requests))
+			}
+			request := (*requests)[0]
+			if request.Path != tt.path {
+				t.Errorf("путь %s, ожидался %s", request.Path, tt.path)
+			}
+
+			fields := request.Query().Get("fields")
+			if !tt.fields {
+				if request.RawQuery != "" {
+					t.Errorf("v0 не поддерживает выборку полей, запрос: %s", request.RawQuery)
+				}
+			} else {
+				requested := strings.Split(fields, ",")
+				if !slices.Equal(requested, models.APIFields()) {
+					t.Errorf("fields=%s, ожидались поля Product", fields)
+				}
+				// Поля, которые заполняет бот, у API не запрашиваются
+				if slices.Contains(requested, "source") {
+					t.Error("в fields= попало поле source")
+				}
+			}
+
+			if product.Barcode != "4607001771234" || product.DisplayName() != "Йогурт клубничный 2,5%" || product.Brand != "Простоквашино" {
+				t.Errorf("продукт разобран неверно: %+v", product)
+			}
+			if !strings.Contains(product.DisplayComposition(), "краситель кармин") {
+				t.Errorf("состав: %q", product.DisplayComposition())
+			}
+			if sugars, ok := models.NumberValue(product.Nutriments.Sugars); !ok || sugars != 13.1 {
+				t.Errorf("сахар на 100 г: %v, %v", sugars, ok)
+			}
+			// В v0 nova_group приходит строкой, в v2 - числом
+			if product.NovaLevel() != 4 || product.NutriScoreGrade != "c" {
+				t.Errorf("NOVA %d, Nutri-Score %q", product.NovaLevel(), product.NutriScoreGrade)
+			}
+			if !slices.Equal(product.CategoriesTags, []string{"en:dairies", "en:fermented-foods", "en:yogurts", "en:fruit-yogurts"}) {
+				t.Errorf("категории: %v", product.CategoriesTags)
+			}
+			if product.ProductType != models.ProductTypeFood {
+				t.Errorf("тип продукта %q", product.ProductType)
+			}
+		})
+	}
+}
+
+func TestOpenFactsSourceDefaultsProductType(t *testing.T) {
+	// В документе v0 нет product_type: берется тип базы
+	server, _ := openFactsServer(t, http.StatusOK, "product_v0.json")
+	source := NewOpenFactsSource("Open Pet Food Facts", models.ProductTypePetFood, server.URL, APIVersionV0, server.Client(), noRetry)
+
+	product, err := source.Lookup(context.Background(), "4607001771234")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if product.ProductType != models.ProductTypePetFood {
+		t.Errorf("тип продукта %q, ожидался тип базы %q", product.ProductType, models.ProductTypePetFood)
+	}
+}
+
+func TestOpenFactsSourceErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		fixture string
+		want    error
+	}{
+		// v0 отвечает на отсутствующий продукт 200 со status 0, v2 - 404
+		{"status 0", http.StatusOK, "product_not_found.json", ErrProductNotFound},
+		{"404", http.StatusNotFound, "product_not_found.json", ErrProductNotFound},
+		{"сбой API", http.StatusServiceUnavailable, "product_not_found.json", ErrUpstreamUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, _ := openFactsServer(t, tt.status, tt.fixture)
+			source := NewOpenFactsSource("Open Food Facts", models.ProductTypeFood, server.URL, APIVersionV2, server.Client(), noRetry)
+
+			if _, err := source.Lookup(context.Background(), "4600000000015"); !errors.Is(err, tt.want) {
+				t.Errorf("ошибка %v, ожидалась %v", err, tt.want)
+			}
+		})
+	}
+}
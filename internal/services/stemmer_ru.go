@@ -0,0 +1,142 @@
+// services/stemmer_ru.go
+package services
+
+// Стеммер для русского языка по алгоритму Snowball (Портер).
+// Нужен, чтобы "красители", "красителя" и "краситель" совпадали с одним правилом.
+// В составах нет глаголов, поэтому шаги для деепричастий, возвратных и
+// глагольных окончаний опущены: они отрезали "ит" у "нитрит" и "сорбит",
+// "л" у "крахмал" и "сь" у "смесь", и основа расходилась с косвенными падежами.
+
+var (
+	ruAdjective = []string{
+		"ими", "ыми", "его", "ого", "ему", "ому",
+		"ее", "ие", "ые", "ое", "ей", "ий", "ый", "ой", "ем", "им", "ым", "ом",
+		"их", "ых", "ую", "юю", "ая", "яя", "ою", "ею",
+	}
+	ruParticiple1 = []string{"ем", "нн", "вш", "ющ", "щ"}
+	ruParticiple2 = []string{"ивш", "ывш", "ующ"}
+	ruNoun        = []string{
+		"иями", "ями", "ами", "ией", "иям", "ием", "иях",
+		"ев", "ов", "ие", "ье", "еи", "ии", "ей", "ой", "ий", "ям", "ем", "ам", "ом", "ах", "ях", "ию", "ью", "ия", "ья",
+		"а", "е", "и", "й", "о", "у", "ы", "ь", "ю", "я",
+	}
+	ruSuperlative    = []string{"ейше", "ейш"}
+	ruDerivational   = []string{"ость", "ост"}
+	ruGroup1Preceder = []rune{'а', 'я'}
+)
+
+func isRuVowel(r rune) bool {
+	switch r {
+	case 'а', 'е', 'и', 'о', 'у', 'ы', 'э', 'ю', 'я':
+		return true
+	}
+	return false
+}
+
+// StemRu возвращает основу русского слова в нижнем регистре
+func StemRu(word string) string {
+	w := []rune(word)
+
+	// RV - часть слова после первой гласной
+	rv := len(w)
+	for i, r := range w {
+		if isRuVowel(r) {
+			rv = i + 1
+			break
+		}
+	}
+	// R2 - по правилам Snowball, нужен только для словообразовательных суффиксов
+	r2 := ruRegion(w, ruRegion(w, 0))
+
+	// Шаг 1: окончания прилагательных и причастий, иначе существительных
+	if n := ruEnding(w, rv, ruAdjective); n > 0 {
+		w = w[:len(w)-n]
+		// Причастие перед окончанием прилагательного
+		if n := ruEndingGroup1(w, rv, ruParticiple1); n > 0 {
+			w = w[:len(w)-n]
+		} else if n := ruEnding(w, rv, ruParticiple2); n > 0 {
+			w = w[:len(w)-n]
+		}
+	} else if n := ruEnding(w, rv, ruNoun); n > 0 {
+		w = w[:len(w)-n]
+	}
+
+	// Шаг 2
+	if len(w) > rv && w[len(w)-1] == 'и' {
+		w = w[:len(w)-1]
+	}
+
+	// Шаг 3
+	if n := ruEnding(w, r2, ruDerivational); n > 0 {
+		w = w[:len(w)-n]
+	}
+
+	// Шаг 4
+	if n := ruEnding(w, rv, ruSuperlative); n > 0 {
+		w = w[:len(w)-n]
+	}
+	switch {
+	case len(w)-2 >= rv && len(w) >= 2 && w[len(w)-1] == 'н' && w[len(w)-2] == 'н':
+		w = w[:len(w)-1]
+	case len(w) > rv && w[len(w)-1] == 'ь':
+		w = w[:len(w)-1]
+	}
+
+	return string(w)
+}
+
+// ruRegion возвращает начало региона R1 (от start) по правилам Snowball:
+// позиция после первой согласной, идущей за гласной
+func ruRegion(w []rune, start int) int {
+	for i := start + 1; i < len(w); i++ {
+		if !isRuVowel(w[i]) && isRuVowel(w[i-1]) {
+			return i + 1
+		}
+	}
+	return len(w)
+}
+
+// ruEnding возвращает длину самого длинного окончания из списка,
+// целиком лежащего в регионе, начинающемся с from; 0 если не найдено
+func ruEnding(w []rune, from int, endings []string) int {
+	best := 0
+	for _, ending := range endings {
+		e := []rune(ending)
+		if len(e) <= best || len(w)-len(e) < from || !hasRuneSuffix(w, e) {
+			continue
+		}
+		best = len(e)
+	}
+	return best
+}
+
+// ruEndingGroup1 - то же, но окончание должно идти после "а" или "я",
+// которые сами остаются в слове
+func ruEndingGroup1(w []rune, from int, endings []string) int {
+	best := 0
+	for _, ending := range endings {
+		e := []rune(ending)
+		pos := len(w) - len(e)
+		if len(e) <= best || pos < from || pos < 1 || !hasRuneSuffix(w, e) {
+			continue
+		}
+		if w[pos-1] != ruGroup1Preceder[0] && w[pos-1] != ruGroup1Preceder[1] {
+			continue
+		}
+		best = len(e)
+	}
+	return best
+}
+
+func hasRuneSuffix(w, suffix []rune) bool {
+	if len(suffix) > len(w) {
+		return false
+	}
+	offset := len(w) - len(suffix)
+	for i, r := range suffix {
+		if w[offset+i] != r {
+			return false
+		}
+	}
+	return true
+}
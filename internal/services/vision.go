@@ -6,17 +6,20 @@ import (
 	"fmt"
 	"log"
 	"regexp"
-	"strconv"
+	"slices"
+
+	"github.com/ajeanett/telbot/internal/barcode"
 
 	vision "cloud.google.com/go/vision/apiv1"
 	"cloud.google.com/go/vision/v2/apiv1/visionpb"
 )
 
-var barCodeRegExp = regexp.MustCompile(`\b\d{8,13}\b`)
+var barCodeRegExp = regexp.MustCompile(`\b\d{8,14}\b`)
 
 type VisionService struct {
 	// в Google Vision API бесплатно только первые 1000 запросов в месяц
 	client *vision.ImageAnnotatorClient
+	quota  *MonthlyQuota // nil - без ограничения
 }
 
 func NewVisionService(ctx context.Context) (*VisionService, error) {
@@ -27,8 +30,58 @@ func NewVisionService(ctx context.Context) (*VisionService, error) {
 	return &VisionService{client: client}, nil
 }
 
-func (s *VisionService) DetectBarcodeViaText(imageData []byte) (string, error) {
-	ctx := context.Background()
+// SetQuota ограничивает число запросов к Vision в месяц; квота общая
+// для поиска штрих-кодов и разбора этикеток
+func (s *VisionService) SetQuota(quota *MonthlyQuota) {
+	s.quota = quota
+}
+
+func (s *VisionService) Name() string {
+	return "vision"
+}
+
+// Decode ищет цифры штрих-кода в тексте на фото (BarcodeDecoder)
+func (s *VisionService) Decode(ctx context.Context, imageData []byte) (string, error) {
+	return s.DetectBarcodeViaText(ctx, imageData)
+}
+
+func (s *VisionService) DetectBarcodeViaText(ctx context.Context, imageData []byte) (string, error) {
+	detectedText, err := s.RecognizeText(ctx, imageData)
+	if err != nil {
+		return "", err
+	}
+	log.Printf("Распознанный текст: %s", detectedText)
+
+	// Ищем штрих-код в тексте
+	barcode := extractBarcodeFromText(detectedText)
+	if barcode == "" {
+		return "", fmt.Errorf("штрих-код не найден в распознанном тексте")
+	}
+
+	return barcode, nil
+}
+
+// DetectAll возвращает все штрих-коды, найденные в тексте на фото (MultiBarcodeDecoder)
+func (s *VisionService) DetectAll(ctx context.Context, imageData []byte) ([]string, error) {
+	detectedText, err := s.RecognizeText(ctx, imageData)
+	if err != nil {
+		return nil, err
+	}
+
+	barcodes := extractBarcodesFromText(detectedText)
+	if len(barcodes) == 0 {
+		return nil, fmt.Errorf("штрих-код не найден в распознанном тексте")
+	}
+	return barcodes, nil
+}
+
+// RecognizeText распознает весь текст на изображении (TextRecognizer)
+func (s *VisionService) RecognizeText(ctx context.Context, imageData []byte) (string, error) {
+	if s.quota != nil {
+		if err := s.quota.Take(); err != nil {
+			return "", err
+		}
+	}
 
 	img := &visionpb.Image{
 		Content: imageData,
@@ -40,7 +93,8 @@ func (s *VisionService) DetectBarcodeViaText(imageData []byte) (string, error) {
 				Image: img,
 				Features: []*visionpb.Feature{
 					{
-						Type:       visionpb.Feature_DOCUMENT_TEXT_DETECTION, // Лучше для штрих-кодов
+						// Лучше TEXT_DETECTION для плотного текста: составов и штрих-кодов
+						Type:       visionpb.Feature_DOCUMENT_TEXT_DETECTION,
 						MaxResults: 1,
 					},
 				},
@@ -63,19 +117,22 @@ func (s *VisionService) DetectBarcodeViaText(imageData []byte) (string, error) {
 	}
 
 	// Извлекаем весь распознанный текст
-	var detectedText string
-	if response.FullTextAnnotation != nil {
-		detectedText = response.FullTextAnnotation.GetText()
+	if response.FullTextAnnotation == nil || response.FullTextAnnotation.GetText() == "" {
+		return "", ErrNoText
 	}
-	log.Printf("Распознанный текст: %s", detectedText)
+	return response.FullTextAnnotation.GetText(), nil
+}
 
-	// Ищем штрих-код в тексте
-	barcode := extractBarcodeFromText(detectedText)
-	if barcode == "" {
-		return "", fmt.Errorf("штрих-код не найден в распознанном тексте")
+// extractBarcodesFromText возвращает все валидные штрих-коды без повторов
+func extractBarcodesFromText(text string) []string {
+	var barcodes []string
+	for _, match := range barCodeRegExp.FindAllString(text, -1) {
+		code, err := barcode.Normalize(match)
+		if err == nil && !slices.Contains(barcodes, code.LookupCode()) {
+			barcodes = append(barcodes, code.LookupCode())
+		}
 	}
-
-	return barcode, nil
+	return barcodes
 }
 
 func extractBarcodeFromText(text string) string {
@@ -83,67 +140,17 @@ func extractBarcodeFromText(text string) string {
 		return ""
 	}
 
-	// Ищем последовательности из 8-13 цифр
+	// Ищем последовательности из 8-14 цифр
 	matches := barCodeRegExp.FindAllString(text, -1)
 
 	for _, match := range matches {
-		if IsValidBarcode(match) {
-			return match
+		if code, err := barcode.Normalize(match); err == nil {
+			return code.LookupCode()
 		}
 	}
 	return ""
 }
 
-// isValidBarcode проверяет валидность штрих-кода
-func IsValidBarcode(barcode string) bool {
-	// Проверяем базовые условия
-	if len(barcode) < 8 || len(barcode) > 13 {
-		return false
-	}
-
-	// Проверяем что это только цифры
-	matched, _ := regexp.MatchString(`^\d+$`, barcode)
-	if !matched {
-		return false
-	}
-
-	// Дополнительная проверка контрольной суммы для EAN-13
-	if len(barcode) == 13 {
-		return validateEAN13(barcode)
-	}
-
-	// TODO: для других форматов можно добавить дополнительные проверки
-	return true
-}
-
-// validateEAN13 проверяет контрольную сумму EAN-13
-func validateEAN13(barcode string) bool {
-	if len(barcode) != 13 {
-		return false
-	}
-
-	sum := 0
-	for i, char := range barcode[:12] {
-		digit, err := strconv.Atoi(string(char))
-		if err != nil {
-			return false
-		}
-		if i%2 == 0 {
-			sum += digit * 1
-		} else {
-			sum += digit * 3
-		}
-	}
-
-	checkDigit, err := strconv.Atoi(string(barcode[12]))
-	if err != nil {
-		return false
-	}
-	calculatedCheckDigit := (10 - (sum % 10)) % 10
-
-	return checkDigit == calculatedCheckDigit
-}
-
 func (s *VisionService) Close() error {
 	if s.client != nil {
 		return s.client.Close()
@@ -0,0 +1,261 @@
+// storage/memory.go
+package storage
+
+import (
+	"context"
+	"github.com/ajeanett/telbot/internal/models"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore - хранилище в памяти для разработки и тестов.
+// Все методы возвращают копии, чтобы вызывающий код не менял данные хранилища.
+type MemoryStore struct {
+	mu        sync.RWMutex
+	users     map[int64]models.User
+	profiles  map[int64]*models.UserProfile
+	scans     []models.Scan // в порядке добавления
+	favorites map[int64]map[string]models.Favorite
+	feedback  []models.Feedback
+	nextScan  int64
+	nextFB    int64
+}
+
+// NewMemoryStore создает пустое хранилище в памяти
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		users:     make(map[int64]models.User),
+		profiles:  make(map[int64]*models.UserProfile),
+		favorites: make(map[int64]map[string]models.Favorite),
+	}
+}
+
+func (s *MemoryStore) Users() UserRepository         { return memoryUsers{s} }
+func (s *MemoryStore) Scans() ScanRepository         { return memoryScans{s} }
+func (s *MemoryStore) Profiles() ProfileRepository   { return memoryProfiles{s} }
+func (s *MemoryStore) Favorites() FavoriteRepository { return memoryFavorites{s} }
+func (s *MemoryStore) Feedback() FeedbackRepository  { return memoryFeedback{s} }
+func (s *MemoryStore) Close() error                  { return nil }
+
+type memoryUsers struct{ s *MemoryStore }
+
+func (r memoryUsers) Upsert(ctx context.Context, user *models.User) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	now := time.Now()
+	saved := *user
+	saved.LastSeenAt = now
+	if prev, ok := r.s.users[user.ID]; ok {
+		saved.CreatedAt = prev.CreatedAt
+	} else {
+		saved.CreatedAt = now
+	}
+	r.s.users[user.ID] = saved
+	*user = saved
+	return nil
+}
+
+func (r memoryUsers) Get(ctx context.Context, id int64) (*models.User, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	user, ok := r.s.users[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &user, nil
+}
+
+type memoryScans struct{ s *MemoryStore }
+
+func (r memoryScans) Add(ctx context.Context, scan *models.Scan) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	r.s.nextScan++
+	scan.ID = r.s.nextScan
+	if scan.CreatedAt.IsZero() {
+		scan.CreatedAt = time.Now()
+	}
+	r.s.scans = append(r.s.scans, *scan)
+	return nil
+}
+
+func (r memoryScans) Get(ctx context.Context, id int64) (*models.Scan, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	for _, scan := range r.s.scans {
+		if scan.ID == id {
+			return &scan, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r memoryScans) ListByChat(ctx context.Context, chatID int64, offset, limit int) ([]models.Scan, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	var scans []models.Scan
+	skipped := 0
+	for i := len(r.s.scans) - 1; i >= 0 && len(scans) < limit; i-- {
+		if r.s.scans[i].ChatID != chatID {
+			continue
+		}
+		if skipped < offset {
+			skipped++
+			continue
+		}
+		scans = append(scans, r.s.scans[i])
+	}
+	return scans, nil
+}
+
+func (r memoryScans) CountByChat(ctx context.Context, chatID int64) (int, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	count := 0
+	for _, scan := range r.s.scans {
+		if scan.ChatID == chatID {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r memoryScans) DeleteByChat(ctx context.Context, chatID int64) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	r.s.scans = slices.DeleteFunc(r.s.scans, func(scan models.Scan) bool {
+		return scan.ChatID == chatID
+	})
+	return nil
+}
+
+type memoryProfiles struct{ s *MemoryStore }
+
+func (r memoryProfiles) Get(ctx context.Context, userID int64) (*models.UserProfile, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	if profile, ok := r.s.profiles[userID]; ok {
+		return copyProfile(profile), nil
+	}
+	return &models.UserProfile{UserID: userID}, nil
+}
+
+func (r memoryProfiles) Save(ctx context.Context, profile *models.UserProfile) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if profile.IsEmpty() {
+		delete(r.s.profiles, profile.UserID)
+		return nil
+	}
+	r.s.profiles[profile.UserID] = copyProfile(profile)
+	return nil
+}
+
+func (r memoryProfiles) Delete(ctx context.Context, userID int64) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	delete(r.s.profiles, userID)
+	return nil
+}
+
+type memoryFavorites struct{ s *MemoryStore }
+
+func (r memoryFavorites) Add(ctx context.Context, favorite *models.Favorite) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	byBarcode, ok := r.s.favorites[favorite.UserID]
+	if !ok {
+		byBarcode = make(map[string]models.Favorite)
+		r.s.favorites[favorite.UserID] = byBarcode
+	}
+	if prev, ok := byBarcode[favorite.Barcode]; ok {
+		favorite.CreatedAt = prev.CreatedAt
+	} else if favorite.CreatedAt.IsZero() {
+		favorite.CreatedAt = time.Now()
+	}
+	byBarcode[favorite.Barcode] = *favorite
+	return nil
+}
+
+func (r memoryFavorites) Remove(ctx context.Context, userID int64, barcode string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	delete(r.s.favorites[userID], barcode)
+	return nil
+}
+
+func (r memoryFavorites) Has(ctx context.Context, userID int64, barcode string) (bool, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	_, ok := r.s.favorites[userID][barcode]
+	return ok, nil
+}
+
+func (r memoryFavorites) List(ctx context.Context, userID int64) ([]models.Favorite, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	favorites := make([]models.Favorite, 0, len(r.s.favorites[userID]))
+	for _, favorite := range r.s.favorites[userID] {
+		favorites = append(favorites, favorite)
+	}
+	sortFavorites(favorites)
+	return favorites, nil
+}
+
+type memoryFeedback struct{ s *MemoryStore }
+
+func (r memoryFeedback) Add(ctx context.Context, feedback *models.Feedback) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	r.s.nextFB++
+	feedback.ID = r.s.nextFB
+	if feedback.CreatedAt.IsZero() {
+		feedback.CreatedAt = time.Now()
+	}
+	r.s.feedback = append(r.s.feedback, *feedback)
+	return nil
+}
+
+func (r memoryFeedback) List(ctx context.Context, limit int) ([]models.Feedback, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	var list []models.Feedback
+	for i := len(r.s.feedback) - 1; i >= 0 && len(list) < limit; i-- {
+		list = append(list, r.s.feedback[i])
+	}
+	return list, nil
+}
+
+// sortFavorites - новые первыми
+func sortFavorites(favorites []models.Favorite) {
+	sort.SliceStable(favorites, func(i, j int) bool {
+		return favorites[i].CreatedAt.After(favorites[j].CreatedAt)
+	})
+}
+
+func copyProfile(profile *models.UserProfile) *models.UserProfile {
+	return &models.UserProfile{
+		UserID:    profile.UserID,
+		Allergens: slices.Clone(profile.Allergens),
+		Diets:     slices.Clone(profile.Diets),
+		Avoid:     slices.Clone(profile.Avoid),
+	}
+}
@@ -0,0 +1,48 @@
+-- Пользователи, профили, история проверок, избранное и сообщения об ошибках
+
+CREATE TABLE users (
+    id            INTEGER PRIMARY KEY,
+    chat_id       INTEGER NOT NULL,
+    username      TEXT    NOT NULL DEFAULT '',
+    first_name    TEXT    NOT NULL DEFAULT '',
+    language_code TEXT    NOT NULL DEFAULT '',
+    created_at    INTEGER NOT NULL,
+    last_seen_at  INTEGER NOT NULL
+);
+
+CREATE TABLE profiles (
+    user_id    INTEGER PRIMARY KEY,
+    allergens  TEXT    NOT NULL DEFAULT '[]',
+    diets      TEXT    NOT NULL DEFAULT '[]',
+    avoid      TEXT    NOT NULL DEFAULT '[]',
+    updated_at INTEGER NOT NULL
+);
+
+CREATE TABLE scans (
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id      INTEGER NOT NULL,
+    chat_id      INTEGER NOT NULL,
+    barcode      TEXT    NOT NULL,
+    product_name TEXT    NOT NULL DEFAULT '',
+    verdict      TEXT    NOT NULL DEFAULT '',
+    created_at   INTEGER NOT NULL
+);
+
+CREATE INDEX scans_chat_id ON scans (chat_id, id);
+
+CREATE TABLE favorites (
+    user_id      INTEGER NOT NULL,
+    barcode      TEXT    NOT NULL,
+    product_name TEXT    NOT NULL DEFAULT '',
+    created_at   INTEGER NOT NULL,
+    PRIMARY KEY (user_id, barcode)
+);
+
+CREATE TABLE feedback (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id    INTEGER NOT NULL,
+    chat_id    INTEGER NOT NULL,
+    barcode    TEXT    NOT NULL DEFAULT '',
+    message    TEXT    NOT NULL,
+    created_at INTEGER NOT NULL
+);
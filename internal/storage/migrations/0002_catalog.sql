@@ -0,0 +1,8 @@
+-- Собственный каталог региональных продуктов, которых нет в Open Food Facts.
+-- product - продукт в JSON в формате Open Food Facts, как в JSON-каталоге.
+
+CREATE TABLE catalog_products (
+    barcode    TEXT    PRIMARY KEY,
+    product    TEXT    NOT NULL,
+    updated_at INTEGER NOT NULL
+);
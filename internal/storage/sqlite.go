@@ -0,0 +1,481 @@
+// storage/sqlite.go
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ajeanett/telbot/internal/models"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// Миграции схемы: NNNN_описание.sql, применяются по возрастанию номера
+//
+//go:embed migrations/*.sql
+var migrationsFS embed.FS
+
+// SQLiteStore - хранилище в файле SQLite (драйвер на чистом Go, без cgo).
+// Время хранится в миллисекундах Unix.
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// OpenSQLite открывает или создает базу и применяет недостающие миграции
+func OpenSQLite(path string) (*SQLiteStore, error) {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("ошибка создания каталога базы: %w", err)
+		}
+	}
+
+	dsn := "file:" + path + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка открытия SQLite: %w", err)
+	}
+	// SQLite все равно пишет в один поток, а одно соединение
+	// избавляет от ошибок SQLITE_BUSY между своими же запросами
+	db.SetMaxOpenConns(1)
+
+	if err := migrate(context.Background(), db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &SQLiteStore{db: db}, nil
+}
+
+func (s *SQLiteStore) Users() UserRepository         { return sqliteUsers{s.db} }
+func (s *SQLiteStore) Scans() ScanRepository         { return sqliteScans{s.db} }
+func (s *SQLiteStore) Profiles() ProfileRepository   { return sqliteProfiles{s.db} }
+func (s *SQLiteStore) Favorites() FavoriteRepository { return sqliteFavorites{s.db} }
+func (s *SQLiteStore) Feedback() FeedbackRepository  { return sqliteFeedback{s.db} }
+func (s *SQLiteStore) Catalog() CatalogRepository    { return sqliteCatalog{s.db} }
+
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// migrate применяет миграции, которых еще нет в schema_migrations.
+// Каждая миграция выполняется в своей транзакции.
+func migrate(ctx context.Context, db *sql.DB) error {
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at INTEGER NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("ошибка создания таблицы миграций: %w", err)
+	}
+
+	var current int
+	if err := db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return fmt.Errorf("ошибка чтения версии схемы: %w", err)
+	}
+
+	migrations, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		if err := applyMigration(ctx, db, m); err != nil {
+			return fmt.Errorf("миграция %s: %w", m.name, err)
+		}
+	}
+	return nil
+}
+
+type migration struct {
+	version int
+	name    string
+	sql     string
+}
+
+func loadMigrations() ([]migration, error) {
+	entries, err := fs.ReadDir(migrationsFS, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения миграций: %w", err)
+	}
+
+	var migrations []migration
+	for _, entry := range entries {
+		prefix, _, ok := strings.Cut(entry.Name(), "_")
+		version, err := strconv.Atoi(prefix)
+		if !ok || err != nil {
+			return nil, fmt.Errorf("некорректное имя миграции %s, ожидается NNNN_описание.sql", entry.Name())
+		}
+		data, err := migrationsFS.ReadFile("migrations/" + entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("ошибка чтения миграции %s: %w", entry.Name(), err)
+		}
+		migrations = append(migrations, migration{version: version, name: entry.Name(), sql: string(data)})
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].version < migrations[j].version })
+	return migrations, nil
+}
+
+func applyMigration(ctx context.Context, db *sql.DB, m migration) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, m.sql); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`,
+		m.version, toMillis(time.Now())); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+type sqliteUsers struct{ db *sql.DB }
+
+func (r sqliteUsers) Upsert(ctx context.Context, user *models.User) error {
+	now := time.Now()
+	var createdAt int64
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO users (id, chat_id, username, first_name, language_code, created_at, last_seen_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			chat_id = excluded.chat_id,
+			username = excluded.username,
+			first_name = excluded.first_name,
+			language_code = excluded.language_code,
+			last_seen_at = excluded.last_seen_at
+		RETURNING created_at`,
+		user.ID, user.ChatID, user.Username, user.FirstName, user.LanguageCode, toMillis(now), toMillis(now),
+	).Scan(&createdAt)
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения пользователя: %w", err)
+	}
+	user.CreatedAt = fromMillis(createdAt)
+	user.LastSeenAt = fromMillis(toMillis(now))
+	return nil
+}
+
+func (r sqliteUsers) Get(ctx context.Context, id int64) (*models.User, error) {
+	var user models.User
+	var createdAt, lastSeenAt int64
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id, chat_id, username, first_name, language_code, created_at, last_seen_at
+		FROM users WHERE id = ?`, id,
+	).Scan(&user.ID, &user.ChatID, &user.Username, &user.FirstName, &user.LanguageCode, &createdAt, &lastSeenAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения пользователя: %w", err)
+	}
+	user.CreatedAt = fromMillis(createdAt)
+	user.LastSeenAt = fromMillis(lastSeenAt)
+	return &user, nil
+}
+
+type sqliteScans struct{ db *sql.DB }
+
+const scanColumns = `id, user_id, chat_id, barcode, product_name, verdict, created_at`
+
+func (r sqliteScans) Add(ctx context.Context, scan *models.Scan) error {
+	if scan.CreatedAt.IsZero() {
+		scan.CreatedAt = time.Now()
+	}
+	res, err := r.db.ExecContext(ctx, `
+		INSERT INTO scans (user_id, chat_id, barcode, product_name, verdict, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		scan.UserID, scan.ChatID, scan.Barcode, scan.ProductName, scan.Verdict, toMillis(scan.CreatedAt))
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения проверки: %w", err)
+	}
+	if scan.ID, err = res.LastInsertId(); err != nil {
+		return fmt.Errorf("ошибка сохранения проверки: %w", err)
+	}
+	return nil
+}
+
+func (r sqliteScans) Get(ctx context.Context, id int64) (*models.Scan, error) {
+	scan, err := scanScan(r.db.QueryRowContext(ctx, `SELECT `+scanColumns+` FROM scans WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения проверки: %w", err)
+	}
+	return &scan, nil
+}
+
+func (r sqliteScans) ListByChat(ctx context.Context, chatID int64, offset, limit int) ([]models.Scan, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+scanColumns+` FROM scans
+		WHERE chat_id = ? ORDER BY id DESC LIMIT ? OFFSET ?`, chatID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения истории: %w", err)
+	}
+	defer rows.Close()
+
+	var scans []models.Scan
+	for rows.Next() {
+		scan, err := scanScan(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка чтения истории: %w", err)
+		}
+		scans = append(scans, scan)
+	}
+	return scans, rows.Err()
+}
+
+func (r sqliteScans) CountByChat(ctx context.Context, chatID int64) (int, error) {
+	var count int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM scans WHERE chat_id = ?`, chatID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("ошибка чтения истории: %w", err)
+	}
+	return count, nil
+}
+
+func (r sqliteScans) DeleteByChat(ctx context.Context, chatID int64) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM scans WHERE chat_id = ?`, chatID); err != nil {
+		return fmt.Errorf("ошибка удаления истории: %w", err)
+	}
+	return nil
+}
+
+// rowScanner - общее у *sql.Row и *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanScan(row rowScanner) (models.Scan, error) {
+	var scan models.Scan
+	var createdAt int64
+	err := row.Scan(&scan.ID, &scan.UserID, &scan.ChatID, &scan.Barcode, &scan.ProductName, &scan.Verdict, &createdAt)
+	scan.CreatedAt = fromMillis(createdAt)
+	return scan, err
+}
+
+type sqliteProfiles struct{ db *sql.DB }
+
+func (r sqliteProfiles) Get(ctx context.Context, userID int64) (*models.UserProfile, error) {
+	var allergens, diets, avoid string
+	err := r.db.QueryRowContext(ctx, `SELECT allergens, diets, avoid FROM profiles WHERE user_id = ?`, userID).
+		Scan(&allergens, &diets, &avoid)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &models.UserProfile{UserID: userID}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения профиля: %w", err)
+	}
+
+	profile := &models.UserProfile{UserID: userID}
+	for _, field := range []struct {
+		data string
+		dst  *[]string
+	}{{allergens, &profile.Allergens}, {diets, &profile.Diets}, {avoid, &profile.Avoid}} {
+		if err := json.Unmarshal([]byte(field.data), field.dst); err != nil {
+			return nil, fmt.Errorf("ошибка парсинга профиля %d: %w", userID, err)
+		}
+	}
+	return profile, nil
+}
+
+func (r sqliteProfiles) Save(ctx context.Context, profile *models.UserProfile) error {
+	if profile.IsEmpty() {
+		return r.Delete(ctx, profile.UserID)
+	}
+
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO profiles (user_id, allergens, diets, avoid, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (user_id) DO UPDATE SET
+			allergens = excluded.allergens,
+			diets = excluded.diets,
+			avoid = excluded.avoid,
+			updated_at = excluded.updated_at`,
+		profile.UserID, jsonList(profile.Allergens), jsonList(profile.Diets), jsonList(profile.Avoid), toMillis(time.Now()))
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения профиля: %w", err)
+	}
+	return nil
+}
+
+func (r sqliteProfiles) Delete(ctx context.Context, userID int64) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM profiles WHERE user_id = ?`, userID); err != nil {
+		return fmt.Errorf("ошибка удаления профиля: %w", err)
+	}
+	return nil
+}
+
+type sqliteFavorites struct{ db *sql.DB }
+
+func (r sqliteFavorites) Add(ctx context.Context, favorite *models.Favorite) error {
+	if favorite.CreatedAt.IsZero() {
+		favorite.CreatedAt = time.Now()
+	}
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO favorites (user_id, barcode, product_name, created_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT (user_id, barcode) DO UPDATE SET product_name = excluded.product_name`,
+		favorite.UserID, favorite.Barcode, favorite.ProductName, toMillis(favorite.CreatedAt))
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения избранного: %w", err)
+	}
+	return nil
+}
+
+func (r sqliteFavorites) Remove(ctx context.Context, userID int64, barcode string) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM favorites WHERE user_id = ? AND barcode = ?`, userID, barcode); err != nil {
+		return fmt.Errorf("ошибка удаления из избранного: %w", err)
+	}
+	return nil
+}
+
+func (r sqliteFavorites) Has(ctx context.Context, userID int64, barcode string) (bool, error) {
+	var exists bool
+	err := r.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM favorites WHERE user_id = ? AND barcode = ?)`,
+		userID, barcode).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("ошибка чтения избранного: %w", err)
+	}
+	return exists, nil
+}
+
+func (r sqliteFavorites) List(ctx context.Context, userID int64) ([]models.Favorite, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT user_id, barcode, product_name, created_at FROM favorites
+		WHERE user_id = ? ORDER BY created_at DESC`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения избранного: %w", err)
+	}
+	defer rows.Close()
+
+	var favorites []models.Favorite
+	for rows.Next() {
+		var favorite models.Favorite
+		var createdAt int64
+		if err := rows.Scan(&favorite.UserID, &favorite.Barcode, &favorite.ProductName, &createdAt); err != nil {
+			return nil, fmt.Errorf("ошибка чтения избранного: %w", err)
+		}
+		favorite.CreatedAt = fromMillis(createdAt)
+		favorites = append(favorites, favorite)
+	}
+	return favorites, rows.Err()
+}
+
+type sqliteFeedback struct{ db *sql.DB }
+
+func (r sqliteFeedback) Add(ctx context.Context, feedback *models.Feedback) error {
+	if feedback.CreatedAt.IsZero() {
+		feedback.CreatedAt = time.Now()
+	}
+	res, err := r.db.ExecContext(ctx, `
+		INSERT INTO feedback (user_id, chat_id, barcode, message, created_at)
+		VALUES (?, ?, ?, ?, ?)`,
+		feedback.UserID, feedback.ChatID, feedback.Barcode, feedback.Message, toMillis(feedback.CreatedAt))
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения сообщения: %w", err)
+	}
+	if feedback.ID, err = res.LastInsertId(); err != nil {
+		return fmt.Errorf("ошибка сохранения сообщения: %w", err)
+	}
+	return nil
+}
+
+func (r sqliteFeedback) List(ctx context.Context, limit int) ([]models.Feedback, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, user_id, chat_id, barcode, message, created_at FROM feedback
+		ORDER BY id DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения сообщений: %w", err)
+	}
+	defer rows.Close()
+
+	var list []models.Feedback
+	for rows.Next() {
+		var feedback models.Feedback
+		var createdAt int64
+		if err := rows.Scan(&feedback.ID, &feedback.UserID, &feedback.ChatID, &feedback.Barcode, &feedback.Message, &createdAt); err != nil {
+			return nil, fmt.Errorf("ошибка чтения сообщений: %w", err)
+		}
+		feedback.CreatedAt = fromMillis(createdAt)
+		list = append(list, feedback)
+	}
+	return list, rows.Err()
+}
+
+type sqliteCatalog struct{ db *sql.DB }
+
+func (r sqliteCatalog) Get(ctx context.Context, barcode string) (*models.Product, error) {
+	var data string
+	err := r.db.QueryRowContext(ctx, `SELECT product FROM catalog_products WHERE barcode = ?`, barcode).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения каталога: %w", err)
+	}
+
+	var product models.Product
+	if err := json.Unmarshal([]byte(data), &product); err != nil {
+		return nil, fmt.Errorf("ошибка парсинга продукта %s в каталоге: %w", barcode, err)
+	}
+	product.Barcode = barcode
+	return &product, nil
+}
+
+func (r sqliteCatalog) Put(ctx context.Context, product *models.Product) error {
+	if product.Barcode == "" {
+		return fmt.Errorf("у продукта нет штрих-кода")
+	}
+	data, err := json.Marshal(product)
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации продукта: %w", err)
+	}
+	_, err = r.db.ExecContext(ctx, `
+		INSERT INTO catalog_products (barcode, product, updated_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT (barcode) DO UPDATE SET
+			product = excluded.product,
+			updated_at = excluded.updated_at`,
+		product.Barcode, string(data), toMillis(time.Now()))
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения продукта в каталог: %w", err)
+	}
+	return nil
+}
+
+func (r sqliteCatalog) Count(ctx context.Context) (int, error) {
+	var count int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM catalog_products`).Scan(&count); err != nil {
+		return 0, fmt.Errorf("ошибка подсчета продуктов каталога: %w", err)
+	}
+	return count, nil
+}
+
+func jsonList(items []string) string {
+	if items == nil {
+		items = []string{}
+	}
+	data, _ := json.Marshal(items)
+	return string(data)
+}
+
+func toMillis(t time.Time) int64 {
+	return t.UnixMilli()
+}
+
+func fromMillis(ms int64) time.Time {
+	return time.UnixMilli(ms)
+}
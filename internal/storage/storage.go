@@ -0,0 +1,111 @@
+// storage/storage.go
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ajeanett/telbot/internal/models"
+	"strings"
+)
+
+// ErrNotFound - записи нет в хранилище
+var ErrNotFound = errors.New("запись не найдена")
+
+// UserRepository - пользователи бота
+type UserRepository interface {
+	// Upsert создает пользователя или обновляет его данные и время последнего визита
+	Upsert(ctx context.Context, user *models.User) error
+	Get(ctx context.Context, id int64) (*models.User, error)
+}
+
+// ScanRepository - история проверок продуктов по чатам, новые записи первыми
+type ScanRepository interface {
+	// Add сохраняет проверку и заполняет ее ID
+	Add(ctx context.Context, scan *models.Scan) error
+	Get(ctx context.Context, id int64) (*models.Scan, error)
+	ListByChat(ctx context.Context, chatID int64, offset, limit int) ([]models.Scan, error)
+	CountByChat(ctx context.Context, chatID int64) (int, error)
+	DeleteByChat(ctx context.Context, chatID int64) error
+}
+
+// ProfileRepository - личные профили пользователей
+type ProfileRepository interface {
+	// Get возвращает профиль пользователя; если его нет - пустой профиль
+	Get(ctx context.Context, userID int64) (*models.UserProfile, error)
+	// Save сохраняет профиль; пустой профиль удаляется
+	Save(ctx context.Context, profile *models.UserProfile) error
+	Delete(ctx context.Context, userID int64) error
+}
+
+// FavoriteRepository - избранные продукты пользователей
+type FavoriteRepository interface {
+	// Add добавляет продукт в избранное; повторное добавление не ошибка
+	Add(ctx context.Context, favorite *models.Favorite) error
+	Remove(ctx context.Context, userID int64, barcode string) error
+	Has(ctx context.Context, userID int64, barcode string) (bool, error)
+	List(ctx context.Context, userID int64) ([]models.Favorite, error)
+}
+
+// FeedbackRepository - сообщения об ошибках в данных
+type FeedbackRepository interface {
+	// Add сохраняет сообщение и заполняет его ID
+	Add(ctx context.Context, feedback *models.Feedback) error
+	// List возвращает последние сообщения, новые первыми
+	List(ctx context.Context, limit int) ([]models.Feedback, error)
+}
+
+// CatalogRepository - собственный каталог продуктов. Есть только у SQLiteStore:
+// каталог ведется вручную, и держать его в Redis или памяти незачем.
+type CatalogRepository interface {
+	// Get возвращает ErrNotFound, если продукта в каталоге нет
+	Get(ctx context.Context, barcode string) (*models.Product, error)
+	// Put добавляет продукт или заменяет его по штрих-коду
+	Put(ctx context.Context, product *models.Product) error
+	Count(ctx context.Context) (int, error)
+}
+
+// Store - хранилище со всеми репозиториями
+type Store interface {
+	Users() UserRepository
+	Scans() ScanRepository
+	Profiles() ProfileRepository
+	Favorites() FavoriteRepository
+	Feedback() FeedbackRepository
+	Close() error
+}
+
+// Open открывает хранилище по адресу:
+//
+//	memory                  - в памяти, данные теряются при перезапуске
+//	sqlite://data/telbot.db - файл SQLite (просто путь к файлу тоже подходит)
+//	redis://host:6379/0     - Redis
+func Open(dsn string) (Store, error) {
+	scheme, rest, hasScheme := strings.Cut(dsn, "://")
+	if !hasScheme {
+		scheme, rest = "", dsn
+	}
+
+	switch {
+	case dsn == "memory" || scheme == "memory":
+		return NewMemoryStore(), nil
+	case scheme == "redis" || scheme == "rediss":
+		// Без явного nil при ошибке вернулся бы не-nil Store с nil *RedisStore внутри
+		store, err := OpenRedis(dsn)
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	case scheme == "sqlite" || scheme == "":
+		if rest == "" {
+			return nil, fmt.Errorf("не задан путь к файлу SQLite")
+		}
+		store, err := OpenSQLite(rest)
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	default:
+		return nil, fmt.Errorf("неизвестный тип хранилища: %q", scheme)
+	}
+}
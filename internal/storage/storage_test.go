@@ -0,0 +1,440 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ajeanett/telbot/internal/models"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// testStores - все реализации хранилища; каждый тест получает пустое хранилище
+var testStores = map[string]func(t *testing.T) Store{
+	"memory": func(t *testing.T) Store {
+		return NewMemoryStore()
+	},
+	"sqlite": func(t *testing.T) Store {
+		store, err := OpenSQLite(filepath.Join(t.TempDir(), "telbot.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return store
+	},
+	"redis": func(t *testing.T) Store {
+		server := miniredis.RunT(t)
+		return NewRedisStore(redis.NewClient(&redis.Options{Addr: server.Addr()}))
+	},
+}
+
+// forEachStore запускает контрактный тест на каждой реализации хранилища
+func forEachStore(t *testing.T, test func(t *testing.T, store Store)) {
+	for name, open := range testStores {
+		t.Run(name, func(t *testing.T) {
+			store := open(t)
+			t.Cleanup(func() {
+				if err := store.Close(); err != nil {
+					t.Error(err)
+				}
+			})
+			test(t, store)
+		})
+	}
+}
+
+func TestUsers(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		users := store.Users()
+
+		if _, err := users.Get(ctx, 1); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("ошибка %v, ожидалась ErrNotFound", err)
+		}
+
+		user := &models.User{ID: 1, ChatID: 10, Username: "ivan", FirstName: "Иван", LanguageCode: "ru"}
+		if err := users.Upsert(ctx, user); err != nil {
+			t.Fatal(err)
+		}
+		created := user.CreatedAt
+		if created.IsZero() || user.LastSeenAt.IsZero() {
+			t.Errorf("Upsert не заполнил время: %+v", user)
+		}
+
+		time.Sleep(5 * time.Millisecond)
+		update := &models.User{ID: 1, ChatID: 20, FirstName: "Иван"}
+		if err := users.Upsert(ctx, update); err != nil {
+			t.Fatal(err)
+		}
+		got, err := users.Get(ctx, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.ChatID != 20 || got.Username != "" || got.LanguageCode != "" {
+			t.Errorf("данные пользователя не обновились: %+v", got)
+		}
+		if !got.CreatedAt.Equal(created) {
+			t.Errorf("время создания изменилось: %v -> %v", created, got.CreatedAt)
+		}
+		if !got.LastSeenAt.After(created) {
+			t.Errorf("время визита %v не позже создания %v", got.LastSeenAt, created)
+		}
+	})
+}
+
+func TestScans(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		scans := store.Scans()
+
+		if _, err := scans.Get(ctx, 1); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("ошибка %v, ожидалась ErrNotFound", err)
+		}
+
+		var ids []int64
+		for i, barcode := range []string{"1", "2", "3", "4", "5"} {
+			scan := &models.Scan{UserID: 1, ChatID: 10, Barcode: barcode, ProductName: "Продукт " + barcode, Verdict: "ok"}
+			if i == 2 {
+				// В другом чате
+				scan.ChatID = 20
+			}
+			if err := scans.Add(ctx, scan); err != nil {
+				t.Fatal(err)
+			}
+			if scan.ID == 0 || scan.CreatedAt.IsZero() || slices.Contains(ids, scan.ID) {
+				t.Fatalf("Add не заполнил ID или время: %+v", scan)
+			}
+			ids = append(ids, scan.ID)
+		}
+
+		got, err := scans.Get(ctx, ids[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Barcode != "2" || got.ChatID != 10 || got.ProductName != "Продукт 2" || got.Verdict != "ok" {
+			t.Errorf("Get вернул %+v", got)
+		}
+
+		pages := []struct {
+			offset, limit int
+			want          []string
+		}{
+			{0, 10, []string{"5", "4", "2", "1"}},
+			{0, 2, []string{"5", "4"}},
+			{2, 2, []string{"2", "1"}},
+			{3, 5, []string{"1"}},
+			{4, 5, nil},
+			{0, 0, nil},
+		}
+		for _, page := range pages {
+			list, err := scans.ListByChat(ctx, 10, page.offset, page.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var barcodes []string
+			for _, scan := range list {
+				barcodes = append(barcodes, scan.Barcode)
+			}
+			if !slices.Equal(barcodes, page.want) {
+				t.Errorf("ListByChat(%d, %d) = %q, ожидалось %q", page.offset, page.limit, barcodes, page.want)
+			}
+		}
+
+		for chatID, want := range map[int64]int{10: 4, 20: 1, 30: 0} {
+			if count, err := scans.CountByChat(ctx, chatID); err != nil || count != want {
+				t.Errorf("CountByChat(%d) = %d, %v; ожидалось %d", chatID, count, err, want)
+			}
+		}
+
+		if err := scans.DeleteByChat(ctx, 10); err != nil {
+			t.Fatal(err)
+		}
+		if count, _ := scans.CountByChat(ctx, 10); count != 0 {
+			t.Errorf("после удаления в чате %d проверок", count)
+		}
+		if _, err := scans.Get(ctx, ids[0]); !errors.Is(err, ErrNotFound) {
+			t.Errorf("удаленная проверка доступна: %v", err)
+		}
+		if count, _ := scans.CountByChat(ctx, 20); count != 1 {
+			t.Error("удалилась история другого чата")
+		}
+
+		// Номера не переиспользуются после удаления
+		scan := &models.Scan{UserID: 1, ChatID: 10, Barcode: "6"}
+		if err := scans.Add(ctx, scan); err != nil {
+			t.Fatal(err)
+		}
+		if scan.ID <= ids[len(ids)-1] {
+			t.Errorf("новая проверка получила ID %d, уже выданы до %d", scan.ID, ids[len(ids)-1])
+		}
+	})
+}
+
+func TestProfiles(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		profiles := store.Profiles()
+
+		empty, err := profiles.Get(ctx, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if empty.UserID != 1 || !empty.IsEmpty() {
+			t.Errorf("профиль нового пользователя %+v", empty)
+		}
+
+		profile := &models.UserProfile{UserID: 1, Allergens: []string{"en:gluten", "en:nuts"}, Diets: []string{"vegan"}}
+		if err := profiles.Save(ctx, profile); err != nil {
+			t.Fatal(err)
+		}
+		// Хранилище не зависит от изменений сохраненного значения
+		profile.Allergens[0] = "en:milk"
+
+		got, err := profiles.Get(ctx, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.UserID != 1 || !slices.Equal(got.Allergens, []string{"en:gluten", "en:nuts"}) ||
+			!slices.Equal(got.Diets, []string{"vegan"}) || len(got.Avoid) != 0 {
+			t.Errorf("прочитан профиль %+v", got)
+		}
+
+		got.Allergens = nil
+		got.Avoid = []string{"сахар"}
+		if err := profiles.Save(ctx, got); err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := profiles.Get(ctx, 1); len(got.Allergens) != 0 || !slices.Equal(got.Avoid, []string{"сахар"}) {
+			t.Errorf("после обновления профиль %+v", got)
+		}
+
+		// Пустой профиль при сохранении удаляется
+		if err := profiles.Save(ctx, &models.UserProfile{UserID: 1}); err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := profiles.Get(ctx, 1); !got.IsEmpty() {
+			t.Errorf("пустой профиль не удален: %+v", got)
+		}
+
+		if err := profiles.Save(ctx, &models.UserProfile{UserID: 2, Diets: []string{"halal"}}); err != nil {
+			t.Fatal(err)
+		}
+		if err := profiles.Delete(ctx, 2); err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := profiles.Get(ctx, 2); !got.IsEmpty() {
+			t.Errorf("удаленный профиль %+v", got)
+		}
+		if err := profiles.Delete(ctx, 3); err != nil {
+			t.Errorf("удаление несуществующего профиля: %v", err)
+		}
+	})
+}
+
+func TestFavorites(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		favorites := store.Favorites()
+		base := time.UnixMilli(1_700_000_000_000)
+
+		for i, barcode := range []string{"1", "2", "3"} {
+			favorite := &models.Favorite{UserID: 1, Barcode: barcode, ProductName: "Продукт " + barcode, CreatedAt: base.Add(time.Duration(i) * time.Minute)}
+			if err := favorites.Add(ctx, favorite); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := favorites.Add(ctx, &models.Favorite{UserID: 2, Barcode: "1"}); err != nil {
+			t.Fatal(err)
+		}
+
+		// Повторное добавление обновляет название, но не поднимает продукт в списке
+		if err := favorites.Add(ctx, &models.Favorite{UserID: 1, Barcode: "1", ProductName: "Новое название", CreatedAt: base.Add(time.Hour)}); err != nil {
+			t.Fatal(err)
+		}
+
+		list, err := favorites.List(ctx, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var barcodes []string
+		for _, favorite := range list {
+			barcodes = append(barcodes, favorite.Barcode)
+		}
+		if !slices.Equal(barcodes, []string{"3", "2", "1"}) {
+			t.Errorf("избранное %q, ожидалось новые первыми", barcodes)
+		}
+		if last := list[len(list)-1]; last.ProductName != "Новое название" || !last.CreatedAt.Equal(base) {
+			t.Errorf("после повторного добавления %+v", last)
+		}
+
+		for _, tt := range []struct {
+			userID  int64
+			barcode string
+			want    bool
+		}{{1, "2", true}, {2, "1", true}, {2, "2", false}, {3, "1", false}} {
+			if ok, err := favorites.Has(ctx, tt.userID, tt.barcode); err != nil || ok != tt.want {
+				t.Errorf("Has(%d, %s) = %v, %v; ожидалось %v", tt.userID, tt.barcode, ok, err, tt.want)
+			}
+		}
+
+		if err := favorites.Remove(ctx, 1, "2"); err != nil {
+			t.Fatal(err)
+		}
+		if err := favorites.Remove(ctx, 1, "404"); err != nil {
+			t.Errorf("удаление отсутствующего продукта: %v", err)
+		}
+		if ok, _ := favorites.Has(ctx, 1, "2"); ok {
+			t.Error("продукт остался в избранном после удаления")
+		}
+		if list, _ := favorites.List(ctx, 1); len(list) != 2 {
+			t.Errorf("после удаления в избранном %d продуктов", len(list))
+		}
+		if list, err := favorites.List(ctx, 3); err != nil || len(list) != 0 {
+			t.Errorf("избранное нового пользователя %+v, %v", list, err)
+		}
+	})
+}
+
+func TestFeedback(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		feedback := store.Feedback()
+
+		for _, message := range []string{"первое", "второе", "третье"} {
+			item := &models.Feedback{UserID: 1, ChatID: 10, Barcode: "1", Message: message}
+			if err := feedback.Add(ctx, item); err != nil {
+				t.Fatal(err)
+			}
+			if item.ID == 0 || item.CreatedAt.IsZero() {
+				t.Fatalf("Add не заполнил ID или время: %+v", item)
+			}
+		}
+
+		for limit, want := range map[int][]string{
+			0:  nil,
+			2:  {"третье", "второе"},
+			10: {"третье", "второе", "первое"},
+		} {
+			list, err := feedback.List(ctx, limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var messages []string
+			for _, item := range list {
+				messages = append(messages, item.Message)
+			}
+			if !slices.Equal(messages, want) {
+				t.Errorf("List(%d) = %q, ожидалось %q", limit, messages, want)
+			}
+		}
+	})
+}
+
+func TestSQLiteMigrationsAreIdempotent(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "data", "telbot.db")
+
+	store, err := OpenSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Scans().Add(ctx, &models.Scan{UserID: 1, ChatID: 10, Barcode: "1"}); err != nil {
+		t.Fatal(err)
+	}
+	// Повторный прогон на той же базе ничего не применяет
+	if err := migrate(ctx, store.db); err != nil {
+		t.Fatalf("повторная миграция: %v", err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Как и повторное открытие: данные на месте, версии не задвоены
+	store, err = OpenSQLite(path)
+	if err != nil {
+		t.Fatalf("повторное открытие: %v", err)
+	}
+	defer store.Close()
+	if count, err := store.Scans().CountByChat(ctx, 10); err != nil || count != 1 {
+		t.Errorf("после повторного открытия проверок %d, %v", count, err)
+	}
+
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var applied, latest int
+	if err := store.db.QueryRowContext(ctx, `SELECT COUNT(*), MAX(version) FROM schema_migrations`).Scan(&applied, &latest); err != nil {
+		t.Fatal(err)
+	}
+	if applied != len(migrations) || latest != migrations[len(migrations)-1].version {
+		t.Errorf("применено миграций %d до версии %d, всего их %d", applied, latest, len(migrations))
+	}
+}
+
+func TestSQLiteCatalog(t *testing.T) {
+	ctx := context.Background()
+	store, err := OpenSQLite(filepath.Join(t.TempDir(), "telbot.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	catalog := store.Catalog()
+
+	if _, err := catalog.Get(ctx, "1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("ошибка %v, ожидалась ErrNotFound", err)
+	}
+	if err := catalog.Put(ctx, &models.Product{Name: "Без штрих-кода"}); err == nil {
+		t.Error("продукт без штрих-кода сохранен")
+	}
+	for _, name := range []string{"Кефир", "Кефир 2,5%"} {
+		if err := catalog.Put(ctx, &models.Product{Barcode: "1", Name: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	product, err := catalog.Get(ctx, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if product.Barcode != "1" || product.Name != "Кефир 2,5%" {
+		t.Errorf("из каталога прочитан %+v", product)
+	}
+	if count, err := catalog.Count(ctx); err != nil || count != 1 {
+		t.Errorf("в каталоге %d продуктов, %v", count, err)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	server := miniredis.RunT(t)
+	dir := t.TempDir()
+	tests := []struct {
+		dsn     string
+		wantErr bool
+	}{
+		{"memory", false},
+		{"memory://", false},
+		{"sqlite://" + filepath.Join(dir, "a.db"), false},
+		{filepath.Join(dir, "b.db"), false},
+		{"redis://" + server.Addr() + "/0", false},
+		{"sqlite://", true},
+		{"postgres://localhost/telbot", true},
+		{"redis://%zz", true},
+	}
+	for _, tt := range tests {
+		store, err := Open(tt.dsn)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Open(%q): ошибка %v, ожидалась ошибка: %v", tt.dsn, err, tt.wantErr)
+		}
+		if tt.wantErr && store != nil {
+			t.Errorf("Open(%q) при ошибке вернул хранилище %T", tt.dsn, store)
+			continue
+		}
+		if store != nil {
+			store.Close()
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package testutil
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// FakeTextRecognizer - распознавание текста без Google Vision: возвращает
+// заданный текст или ошибку и считает вызовы
+type FakeTextRecognizer struct {
+	Text  string
+	Err   error
+	calls atomic.Int64
+}
+
+func (r *FakeTextRecognizer) RecognizeText(ctx context.Context, imageData []byte) (string, error) {
+	r.calls.Add(1)
+	return r.Text, r.Err
+}
+
+// Calls - сколько раз распознавали текст
+func (r *FakeTextRecognizer) Calls() int {
+	return int(r.calls.Load())
+}
@@ -0,0 +1,123 @@
+package testutil
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ajeanett/telbot/internal/models"
+)
+
+// FakeProductDB - поддельная база продуктов с API Open Food Facts
+// (/api/v0/product/<код>.json, /api/v2/product/<код> и поиск /api/v2/search
+// по categories_tags и countries_tags). Ее URL передается в
+// services.NewOpenFactsSource вместо адреса настоящей базы.
+type FakeProductDB struct {
+	server *httptest.Server
+
+	mu       sync.Mutex
+	products map[string]models.Product
+	requests int
+}
+
+// NewFakeProductDB запускает базу с заданными продуктами
+func NewFakeProductDB(products ...models.Product) *FakeProductDB {
+	db := &FakeProductDB{products: make(map[string]models.Product)}
+	for _, product := range products {
+		db.Add(product)
+	}
+	db.server = httptest.NewServer(http.HandlerFunc(db.serveHTTP))
+	return db
+}
+
+// URL - корень "сайта" базы
+func (db *FakeProductDB) URL() string {
+	return db.server.URL
+}
+
+// Close останавливает сервер
+func (db *FakeProductDB) Close() {
+	db.server.Close()
+}
+
+// Add добавляет или заменяет продукт
+func (db *FakeProductDB) Add(product models.Product) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.products[product.Barcode] = product
+}
+
+// Requests - сколько запросов получила база; помогает проверять кэширование
+func (db *FakeProductDB) Requests() int {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.requests
+}
+
+func (db *FakeProductDB) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/api/v2/search" {
+		db.search(w, r)
+		return
+	}
+
+	var barcode string
+	if rest, ok := strings.CutPrefix(r.URL.Path, "/api/v2/product/"); ok {
+		barcode = rest
+	} else if rest, ok := strings.CutPrefix(r.URL.Path, "/api/v0/product/"); ok {
+		barcode = strings.TrimSuffix(rest, ".json")
+	} else {
+		http.NotFound(w, r)
+		return
+	}
+
+	db.mu.Lock()
+	db.requests++
+	product, ok := db.products[barcode]
+	db.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if !ok {
+		// Как и настоящая база: неизвестный код - 404 со status 0
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(models.APIResponse{})
+		return
+	}
+	json.NewEncoder(w).Encode(models.APIResponse{Status: 1, Product: product})
+}
+
+// search отдает продукты, у которых есть все теги из запроса, по порядку штрих-кодов
+func (db *FakeProductDB) search(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	pageSize, err := strconv.Atoi(query.Get("page_size"))
+	if err != nil || pageSize <= 0 {
+		pageSize = 24
+	}
+
+	db.mu.Lock()
+	db.requests++
+	var products []models.Product
+	for _, product := range db.products {
+		if hasTag(product.CategoriesTags, query.Get("categories_tags")) &&
+			hasTag(product.CountriesTags, query.Get("countries_tags")) {
+			products = append(products, product)
+		}
+	}
+	db.mu.Unlock()
+
+	sort.Slice(products, func(i, j int) bool { return products[i].Barcode < products[j].Barcode })
+	count := len(products)
+	products = products[:min(pageSize, count)]
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.SearchResponse{Count: count, Products: products})
+}
+
+// hasTag - пустой тег в запросе не ограничивает выборку
+func hasTag(tags []string, tag string) bool {
+	return tag == "" || slices.Contains(tags, tag)
+}
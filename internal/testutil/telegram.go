@@ -0,0 +1,279 @@
+// Package testutil содержит поддельные внешние сервисы для тестов бота:
+// Telegram Bot API и базу продуктов в формате Open Food Facts.
+package testutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Имя и ID бота, которые возвращает getMe поддельного сервера
+const (
+	BotUsername = "test_bot"
+	BotID       = 1
+)
+
+// Call - запрос, полученный поддельным Bot API
+type Call struct {
+	Method string
+	Params url.Values
+}
+
+// ChatID возвращает chat_id запроса или 0
+func (c Call) ChatID() int64 {
+	id, _ := strconv.ParseInt(c.Params.Get("chat_id"), 10, 64)
+	return id
+}
+
+// FakeTelegram - поддельный сервер Bot API. Отвечает успехом на любой метод,
+// запоминает запросы, раздает добавленные файлы и отдает обновления из очереди
+// через getUpdates.
+type FakeTelegram struct {
+	server *httptest.Server
+
+	mu            sync.Mutex
+	calls         []Call
+	files         map[string][]byte
+	updates       []tgbotapi.Update
+	nextUpdateID  int
+	nextMessageID int
+}
+
+// NewFakeTelegram запускает сервер; его нужно остановить через Close
+func NewFakeTelegram() *FakeTelegram {
+	f := &FakeTelegram{
+		files:         make(map[string][]byte),
+		nextUpdateID:  1,
+		nextMessageID: 1,
+	}
+	f.server = httptest.NewServer(http.HandlerFunc(f.serveHTTP))
+	return f
+}
+
+// URL - адрес сервера
+func (f *FakeTelegram) URL() string {
+	return f.server.URL
+}
+
+// Close останавливает сервер
+func (f *FakeTelegram) Close() {
+	f.server.Close()
+}
+
+// Client создает клиент Bot API, направленный на поддельный сервер
+func (f *FakeTelegram) Client(token string) (*Client, error) {
+	api, err := tgbotapi.NewBotAPIWithAPIEndpoint(token, f.server.URL+"/bot%s/%s")
+	if err != nil {
+		return nil, err
+	}
+	return &Client{BotAPI: api, fileEndpoint: f.server.URL + "/file/bot%s/%s"}, nil
+}
+
+// AddFile делает файл доступным через getFile, например фото штрих-кода
+func (f *FakeTelegram) AddFile(fileID string, data []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.files[fileID] = data
+}
+
+// PushUpdate ставит обновление в очередь getUpdates и возвращает его update_id
+func (f *FakeTelegram) PushUpdate(update tgbotapi.Update) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	update.UpdateID = f.nextUpdateID
+	f.nextUpdateID++
+	f.updates = append(f.updates, update)
+	return update.UpdateID
+}
+
+// Calls возвращает запросы к методу; пустой method - все запросы
+func (f *FakeTelegram) Calls(method string) []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var calls []Call
+	for _, call := range f.calls {
+		if method == "" || call.Method == method {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// SentTexts возвращает тексты сообщений, отправленных в чат, по порядку
+func (f *FakeTelegram) SentTexts(chatID int64) []string {
+	var texts []string
+	for _, call := range f.Calls("sendMessage") {
+		if call.ChatID() == chatID {
+			texts = append(texts, call.Params.Get("text"))
+		}
+	}
+	return texts
+}
+
+// WaitForCalls ждет, пока к методу придет хотя бы n запросов
+func (f *FakeTelegram) WaitForCalls(method string, n int, timeout time.Duration) ([]Call, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		calls := f.Calls(method)
+		if len(calls) >= n {
+			return calls, nil
+		}
+		if time.Now().After(deadline) {
+			return calls, fmt.Errorf("за %v получено %d запросов %s из %d", timeout, len(calls), method, n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// serveHTTP разбирает пути /bot<token>/<метод> и /file/bot<token>/<путь>
+func (f *FakeTelegram) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if rest, ok := strings.CutPrefix(r.URL.Path, "/file/bot"); ok {
+		if _, path, ok := strings.Cut(rest, "/"); ok {
+			f.handleFile(w, r, path)
+			return
+		}
+	} else if rest, ok := strings.CutPrefix(r.URL.Path, "/bot"); ok {
+		if _, method, ok := strings.Cut(rest, "/"); ok {
+			f.handleMethod(w, r, method)
+			return
+		}
+	}
+	http.NotFound(w, r)
+}
+
+func (f *FakeTelegram) handleMethod(w http.ResponseWriter, r *http.Request, method string) {
+	// Библиотека шлет form-urlencoded, а при загрузке файлов - multipart
+	if err := r.ParseMultipartForm(32 << 20); err != nil && err != http.ErrNotMultipart {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	f.mu.Lock()
+	f.calls = append(f.calls, Call{Method: method, Params: r.Form})
+	f.mu.Unlock()
+
+	switch method {
+	case "getMe":
+		writeResult(w, tgbotapi.User{ID: BotID, IsBot: true, FirstName: "Test", UserName: BotUsername})
+	case "getUpdates":
+		writeResult(w, f.takeUpdates(r.Form))
+	case "getFile":
+		f.getFile(w, r.Form.Get("file_id"))
+	case "sendMessage", "sendPhoto", "editMessageText", "editMessageReplyMarkup":
+		writeResult(w, f.message(r.Form))
+	default:
+		writeResult(w, true)
+	}
+}
+
+// takeUpdates отдает обновления начиная с offset. Long polling не
+// имитируется: при пустой очереди ответ приходит после короткой паузы.
+func (f *FakeTelegram) takeUpdates(form url.Values) []tgbotapi.Update {
+	offset, _ := strconv.Atoi(form.Get("offset"))
+	for range 10 {
+		f.mu.Lock()
+		var updates []tgbotapi.Update
+		for _, update := range f.updates {
+			if update.UpdateID >= offset {
+				updates = append(updates, update)
+			}
+		}
+		f.mu.Unlock()
+		if len(updates) > 0 {
+			return updates
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return []tgbotapi.Update{}
+}
+
+func (f *FakeTelegram) getFile(w http.ResponseWriter, fileID string) {
+	f.mu.Lock()
+	data, ok := f.files[fileID]
+	f.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Bad Request: invalid file_id")
+		return
+	}
+	writeResult(w, tgbotapi.File{
+		FileID:   fileID,
+		FileSize: len(data),
+		FilePath: "files/" + url.PathEscape(fileID),
+	})
+}
+
+// message собирает ответ на отправку или редактирование сообщения
+func (f *FakeTelegram) message(form url.Values) tgbotapi.Message {
+	chatID, _ := strconv.ParseInt(form.Get("chat_id"), 10, 64)
+	messageID, _ := strconv.Atoi(form.Get("message_id"))
+	if messageID == 0 {
+		f.mu.Lock()
+		messageID = f.nextMessageID
+		f.nextMessageID++
+		f.mu.Unlock()
+	}
+	return tgbotapi.Message{
+		MessageID: messageID,
+		From:      &tgbotapi.User{ID: BotID, IsBot: true, UserName: BotUsername},
+		Date:      int(time.Now().Unix()),
+		Chat:      &tgbotapi.Chat{ID: chatID},
+		Text:      form.Get("text"),
+	}
+}
+
+func (f *FakeTelegram) handleFile(w http.ResponseWriter, r *http.Request, path string) {
+	fileID, err := url.PathUnescape(strings.TrimPrefix(path, "files/"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	f.mu.Lock()
+	data, ok := f.files[fileID]
+	f.mu.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Write(data)
+}
+
+func writeResult(w http.ResponseWriter, result any) {
+	raw, err := json.Marshal(result)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tgbotapi.APIResponse{Ok: true, Result: raw})
+}
+
+func writeError(w http.ResponseWriter, status int, description string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(tgbotapi.APIResponse{Ok: false, ErrorCode: status, Description: description})
+}
+
+// Client - клиент Bot API для поддельного сервера. Библиотека строит ссылки
+// на файлы только к api.telegram.org, поэтому GetFileDirectURL переопределен.
+type Client struct {
+	*tgbotapi.BotAPI
+	fileEndpoint string
+}
+
+// GetFileDirectURL возвращает ссылку на файл на поддельном сервере
+func (c *Client) GetFileDirectURL(fileID string) (string, error) {
+	file, err := c.GetFile(tgbotapi.FileConfig{FileID: fileID})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(c.fileEndpoint, c.Token, file.FilePath), nil
+}